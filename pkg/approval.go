@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// Environment variables holding the approval token of a non-interactive
+// deploy to a protected environment, the private key approvers sign tokens
+// with, and the public key deploys verify them with.
+const (
+	approvalTokenEnv     = "DOCKWRIGHT_APPROVAL_TOKEN"
+	approvalKeyEnv       = "DOCKWRIGHT_APPROVAL_KEY"
+	approvalPublicKeyEnv = "DOCKWRIGHT_APPROVAL_PUBLIC_KEY"
+)
+
+// commitAnnotationPattern matches the commit annotation of deployed resources.
+var commitAnnotationPattern = regexp.MustCompile(`dockwright\.io/git-commit:\s*["']?([0-9a-f]+)`)
+
+// releaseCommit returns the commit a release was deployed from, as recorded
+// by helm.metadata in the release's labels, or in the annotations of its
+// resources by older versions. It is empty if no commit was recorded.
+func releaseCommit(rel *release.Release) string {
+	if commit := rel.Labels["dockwright.io/git-commit"]; commit != "" {
+		return commit
+	}
+	if match := commitAnnotationPattern.FindStringSubmatch(rel.Manifest); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// ApprovalToken returns the token approving deploys of commit to env, an
+// ed25519 signature by the approvers' private key. Only the holders of the
+// private key can approve, and a token approves nothing but that commit in
+// that environment.
+func ApprovalToken(key ed25519.PrivateKey, env, commit string) string {
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, approvalMessage(env, commit)))
+}
+
+// GenerateApprovalKey returns a new key pair for approval tokens, base64
+// encoded.
+func GenerateApprovalKey() (privateKey, publicKey string, err error) {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate approval key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(private.Seed()), base64.StdEncoding.EncodeToString(public), nil
+}
+
+// approvalMessage is what an approval token signs.
+func approvalMessage(env, commit string) []byte {
+	return []byte("dockwright-approval\n" + env + "\n" + commit)
+}
+
+// approvalPrivateKey reads the private key from DOCKWRIGHT_APPROVAL_KEY.
+func approvalPrivateKey() (ed25519.PrivateKey, error) {
+	value := os.Getenv(approvalKeyEnv)
+	if value == "" {
+		return nil, fmt.Errorf("%s must be set to create approval tokens. Please generate a key pair with dockwright approve --generate-key", approvalKeyEnv)
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%s is not a base64 encoded ed25519 private key, as dockwright approve --generate-key prints", approvalKeyEnv)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// approvalPublicKey reads the public key from DOCKWRIGHT_APPROVAL_PUBLIC_KEY.
+func approvalPublicKey() (ed25519.PublicKey, error) {
+	value := os.Getenv(approvalPublicKeyEnv)
+	if value == "" {
+		return nil, fmt.Errorf("%s is set, but %s to verify it with is not", approvalTokenEnv, approvalPublicKeyEnv)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%s is not a base64 encoded ed25519 public key, as dockwright approve --generate-key prints", approvalPublicKeyEnv)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// protectsEnv reports whether env is one of environments.protected.
+func (c *Config) protectsEnv(env string) bool {
+	return env != "" && matchesContext(c.EnvironmentsProtected, env)
+}
+
+// approveRelease prepares the release and asks for approval to deploy it,
+// without changing the cluster. A blue/green release is approved as the
+// color it will be deployed as.
+func (h *HelmRunner) approveRelease(ctx context.Context) error {
+	if h.cfg.HelmStrategy == StrategyBlueGreen && h.companion == nil && h.color == "" {
+		client, err := h.cfg.KubeClient()
+		if err != nil {
+			return err
+		}
+		_, next, err := h.nextColor(ctx, client)
+		if err != nil {
+			return err
+		}
+		return h.forColor(next).approveRelease(ctx)
+	}
+
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+	return h.approve(rel)
+}
+
+// approve shows what deploying the release changes, its chart version, its
+// images and, with helm.diff, its manifests, and then asks for approval.
+func (h *HelmRunner) approve(rel *helmRelease) error {
+	deployed, err := h.deployedRelease()
+	if err != nil {
+		h.log().Debugf("Could not read the deployed release %s: %v", h.cfg.ReleaseName(), err)
+	}
+	if deployed != nil && deployed.Chart != nil && deployed.Chart.Metadata.Version != rel.chart.Metadata.Version {
+		h.log().Warnf("⚠️  Chart version of release %s changes from %s to %s since the last deploy", h.cfg.ReleaseName(), deployed.Chart.Metadata.Version, rel.chart.Metadata.Version)
+	}
+	h.logImageChanges(rel, deployed)
+	if err := h.diff(rel); err != nil {
+		return err
+	}
+	return h.gate()
+}
+
+// logImageChanges logs the images the release will run compared to the
+// deployed ones. The artifact's image keeps its tag when it is rebuilt, so
+// the commits it was built from are shown instead.
+func (h *HelmRunner) logImageChanges(rel *helmRelease, deployed *release.Release) {
+	rendered, err := h.render(rel)
+	if err != nil {
+		h.log().Debugf("Could not render release %s to compare its images: %v", h.cfg.ReleaseName(), err)
+		return
+	}
+	images := manifestImages(rendered.Manifest)
+	var previous []string
+	var previousCommit string
+	if deployed != nil {
+		previous = manifestImages(deployed.Manifest)
+		previousCommit = releaseCommit(deployed)
+	}
+	artifactImage, _ := h.cfg.ImageTag()
+	rebuilt := h.companion == nil && h.cfg.ShouldRunDockerBuild() && !h.cfg.SkipDocker
+	commit, _ := gitCommit()
+
+	h.log().Infof("🖼️  Images of release %s:", h.cfg.ReleaseName())
+	for _, image := range images {
+		switch {
+		case deployed == nil || !slices.Contains(previous, image):
+			h.log().Infof("   + %s", image)
+		case image == artifactImage && rebuilt && commit != previousCommit:
+			h.log().Infof("   ~ %s, rebuilt from commit %s (deployed: %s)", image, shortCommit(commit), shortCommit(previousCommit))
+		default:
+			h.log().Infof("     %s (unchanged)", image)
+		}
+	}
+	for _, image := range previous {
+		if !slices.Contains(images, image) {
+			h.log().Infof("   - %s", image)
+		}
+	}
+}
+
+// gate asks for approval to deploy the release. Deploys to protected
+// environments need the environment's name typed, or, without a terminal, an
+// approval token.
+func (h *HelmRunner) gate() error {
+	env := h.cfg.EnvName()
+	if h.cfg.DryRun {
+		return nil
+	}
+	if !h.cfg.protectsEnv(env) {
+		return confirm(h.cfg, fmt.Sprintf("Press Enter to deploy %s to %s: ", h.cfg.ReleaseName(), env))
+	}
+
+	switch {
+	case h.cfg.AllowProtected:
+		h.log().Warnf("🛡️  Deploying to protected environment %s (--allow-protected)", env)
+	case os.Getenv(approvalTokenEnv) != "":
+		if err := verifyApprovalToken(h.cfg, env); err != nil {
+			return err
+		}
+		h.log().Infof("🔏 Deploy to protected environment %s approved by token", env)
+	case h.cfg.AutoApprove:
+		return fmt.Errorf("environment %s is protected, so a deploy with --auto-approve needs an approval token in %s. Please create one with dockwright approve --env %s", env, approvalTokenEnv, env)
+	default:
+		h.log().Warnf("🛡️  Environment %s is protected", env)
+		fmt.Printf("Type the environment name to deploy %s to it: ", h.cfg.ReleaseName())
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("environment %s is protected and its name could not be read: %w. Please pass an approval token in %s to deploy without a terminal", env, err, approvalTokenEnv)
+		}
+		if strings.TrimSpace(answer) != env {
+			return fmt.Errorf("aborted: '%s' does not match the protected environment %s", strings.TrimSpace(answer), env)
+		}
+	}
+	return nil
+}
+
+// verifyApprovalToken checks that one of the comma-separated tokens in
+// DOCKWRIGHT_APPROVAL_TOKEN approves deploying to env: the commit of a
+// promoted image, or HEAD. Uncommitted changes deployed from HEAD are not
+// covered by any token. A promotion deploys an image built before, so its
+// working tree doesn't matter.
+func verifyApprovalToken(cfg *Config, env string) error {
+	key, err := approvalPublicKey()
+	if err != nil {
+		return err
+	}
+	commit := cfg.imageCommit
+	if commit == "" {
+		if commit, err = gitCommit(); err != nil {
+			return fmt.Errorf("approval tokens approve a commit, but %w", err)
+		}
+		if changes, _ := cfg.uncommittedChanges(); len(changes) > 0 {
+			return fmt.Errorf("the approval token can't approve the uncommitted changes in the working tree. Please commit them and have the commit approved")
+		}
+	}
+
+	message := approvalMessage(env, commit)
+	for _, token := range strings.Split(os.Getenv(approvalTokenEnv), ",") {
+		signature, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+		if err == nil && ed25519.Verify(key, message, signature) {
+			return nil
+		}
+	}
+	return fmt.Errorf("the approval token in %s does not approve commit %s in environment %s", approvalTokenEnv, shortCommit(commit), env)
+}
@@ -0,0 +1,272 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// auditAnnotation holds the full audit entry on the Kubernetes events written
+// by the kubernetes audit backend.
+const auditAnnotation = "dockwright.io/audit"
+
+// AuditEntry records who changed what, where and when. An entry is written
+// when a deploy, promote, rollback, uninstall or scale starts and when it
+// finishes.
+type AuditEntry struct {
+	Run          string          `json:"run"`
+	Command      string          `json:"command"`
+	Event        string          `json:"event"` // started, succeeded or failed
+	Time         time.Time       `json:"time"`
+	User         string          `json:"user"`
+	Host         string          `json:"host,omitempty"`
+	Version      string          `json:"version"`
+	Artifact     string          `json:"artifact"`
+	Commit       string          `json:"commit,omitempty"`
+	Dirty        bool            `json:"dirty,omitempty"`
+	Image        string          `json:"image,omitempty"`
+	ImageDigest  string          `json:"imageDigest,omitempty"`
+	Envs         []string        `json:"envs,omitempty"`
+	Context      string          `json:"context,omitempty"`
+	Namespace    string          `json:"namespace,omitempty"`
+	Release      string          `json:"release,omitempty"`
+	ConfigDigest string          `json:"configDigest"` // SHA-256 of the full resolved configuration
+	Releases     []ReleaseReport `json:"releases,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// AuditBackend is where audit entries are recorded, declared in the
+// audit.backends section of the config file:
+//
+//	audit:
+//	  required: true
+//	  backends:
+//	    - type: file
+//	      path: /var/log/dockwright/audit.jsonl
+//	    - type: http
+//	      url: https://audit.example.com/deploys
+//	      headers:
+//	        Authorization: Bearer ${AUDIT_TOKEN}
+//	    - type: kubernetes
+type AuditBackend struct {
+	Type    string            `yaml:"type"`    // file, http or kubernetes
+	Path    string            `yaml:"path"`    // file the entries are appended to
+	URL     string            `yaml:"url"`     // environment variables are expanded
+	Headers map[string]string `yaml:"headers"` // environment variables are expanded
+}
+
+// loadAuditBackends reads the audit.backends section of the config file.
+func loadAuditBackends() ([]AuditBackend, error) {
+	raw, ok := rawConfigValue("audit.backends")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var backends []AuditBackend
+	if err := yaml.Unmarshal(content, &backends); err != nil {
+		return nil, fmt.Errorf("invalid audit.backends section: %w", err)
+	}
+
+	for i, b := range backends {
+		switch {
+		case b.Type == "file" && b.Path == "":
+			return nil, fmt.Errorf("audit.backends[%d]: path is required", i)
+		case b.Type == "http" && b.URL == "":
+			return nil, fmt.Errorf("audit.backends[%d]: url is required", i)
+		case b.Type != "file" && b.Type != "http" && b.Type != "kubernetes":
+			return nil, fmt.Errorf("audit.backends[%d]: unknown type '%s', expected 'file', 'http' or 'kubernetes'", i, b.Type)
+		}
+	}
+	return backends, nil
+}
+
+// audit records an event of the run with every audit backend. Dry-runs
+// change nothing and are not audited. The error joins the failures of all
+// backends; the caller decides whether it stops the deploy.
+func (r *runRecorder) audit(ctx context.Context, event string) error {
+	if len(r.cfg.AuditBackends) == 0 || r.cfg.DryRun {
+		return nil
+	}
+
+	digest, err := r.cfg.Digest()
+	if err != nil {
+		return err
+	}
+	host, _ := os.Hostname()
+	entry := AuditEntry{
+		Run:          r.record.ID,
+		Command:      r.record.Command,
+		Event:        event,
+		Time:         time.Now().UTC(),
+		User:         r.record.User,
+		Host:         host,
+		Version:      r.record.Version,
+		Artifact:     r.cfg.ArtifactName,
+		Commit:       r.record.Commit,
+		Dirty:        r.record.Dirty,
+		Image:        r.record.Image,
+		ImageDigest:  r.record.ImageDigest,
+		Envs:         r.record.Envs,
+		Context:      r.record.Context,
+		Namespace:    r.record.Namespace,
+		Release:      r.record.Release,
+		ConfigDigest: digest,
+		Error:        r.record.Error,
+	}
+	if event != NotifyStarted {
+		r.mu.Lock()
+		entry.Releases = r.releases
+		r.mu.Unlock()
+	}
+
+	var errs []error
+	for _, b := range r.cfg.AuditBackends {
+		if err := b.record(ctx, r.cfg, entry); err != nil {
+			errs = append(errs, fmt.Errorf("%s audit backend: %w", b.Type, err))
+			continue
+		}
+		log.Debugf("Recorded the %s audit entry of run %s with the %s backend", event, entry.Run, b.Type)
+	}
+	return errors.Join(errs...)
+}
+
+// record writes an audit entry to the backend.
+func (b AuditBackend) record(ctx context.Context, cfg *Config, entry AuditEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	switch b.Type {
+	case "file":
+		return appendAuditEntry(b.Path, content)
+	case "http":
+		return postJSON(ctx, b.URL, b.Headers, content)
+	default:
+		return recordAuditEvents(ctx, cfg, entry, content)
+	}
+}
+
+// appendAuditEntry appends an entry to an audit file, one JSON object per
+// line. The file is only ever appended to, and synced before the deploy goes
+// on.
+func appendAuditEntry(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(content, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// recordAuditEvents records an audit entry as a Kubernetes event on the
+// release in each environment's namespace, so that the cluster keeps the
+// record next to the release. The full entry is kept in an annotation.
+// The API server deletes events after its --event-ttl, one hour by default,
+// so these are a convenience, not a lasting record.
+func recordAuditEvents(ctx context.Context, cfg *Config, entry AuditEntry, content []byte) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notificationTimeout)
+	defer cancel()
+
+	action := strings.ToUpper(entry.Command[:1]) + entry.Command[1:]
+	reason, eventType := action+"Started", corev1.EventTypeNormal
+	switch entry.Event {
+	case RunSucceeded:
+		reason = action + "Succeeded"
+	case RunFailed:
+		reason, eventType = action+"Failed", corev1.EventTypeWarning
+	}
+	message := fmt.Sprintf("%s of %s %s, by %s", action, entry.Artifact, entry.Event, entry.User)
+	if entry.Commit != "" {
+		message += fmt.Sprintf(" from commit %s", shortCommit(entry.Commit))
+	}
+	message += fmt.Sprintf(" (config %s)", entry.ConfigDigest)
+	if entry.Error != "" {
+		message += ": " + entry.Error
+	}
+	if len(message) > 1024 {
+		message = message[:1021] + "..."
+	}
+
+	var errs []error
+	for _, envCfg := range cfg.PerEnvironment() {
+		client, err := envCfg.KubeClient()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		namespace, release := NewHelmRunner(envCfg).namespace(), envCfg.ReleaseName()
+		now := metav1.NewTime(entry.Time)
+		event := &corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: release + ".",
+				Namespace:    namespace,
+				Annotations:  map[string]string{auditAnnotation: string(content)},
+			},
+			InvolvedObject: corev1.ObjectReference{
+				APIVersion: "helm.sh/v3",
+				Kind:       "Release",
+				Name:       release,
+				Namespace:  namespace,
+			},
+			Reason:              reason,
+			Message:             message,
+			Type:                eventType,
+			Source:              corev1.EventSource{Component: "dockwright", Host: entry.Host},
+			ReportingController: "dockwright.io/cli",
+			ReportingInstance:   entry.Host,
+			FirstTimestamp:      now,
+			LastTimestamp:       now,
+			Count:               1,
+		}
+		if _, err := client.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+			errs = append(errs, fmt.Errorf("context %s, namespace %s: %w", envCfg.KubernetesContext, namespace, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// auditStart records the start of a run. With audit.required, a run whose
+// start can't be recorded is refused before it changes anything.
+func (r *runRecorder) auditStart(ctx context.Context) error {
+	err := r.audit(ctx, NotifyStarted)
+	if err == nil {
+		return nil
+	}
+	if r.cfg.AuditRequired {
+		return fmt.Errorf("❌ failed to record the %s in the audit log, which audit.required demands: %w", r.record.Command, err)
+	}
+	log.Warnf("⚠️  Failed to record the %s in the audit log: %v", r.record.Command, err)
+	return nil
+}
+
+// auditFinish records the result of a run. The run is over, so a failure is
+// only logged, as an error when audit.required is set.
+func (r *runRecorder) auditFinish(ctx context.Context) {
+	err := r.audit(ctx, r.record.Result)
+	switch {
+	case err == nil:
+	case r.cfg.AuditRequired:
+		log.Errorf("❌ Failed to record the %s %s in the audit log: %v", r.record.Result, r.record.Command, err)
+	default:
+		log.Warnf("⚠️  Failed to record the %s %s in the audit log: %v", r.record.Result, r.record.Command, err)
+	}
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// composeFileNames lists the compose file names in the order docker compose looks them up.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// ComposeService describes a buildable service of a docker compose project.
+type ComposeService struct {
+	Name       string
+	Context    string
+	Dockerfile string
+}
+
+// composeBuild mirrors the build section of a compose service, which may be
+// either a plain context path or a mapping.
+type composeBuild struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+func (b *composeBuild) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain composeBuild
+	return node.Decode((*plain)(b))
+}
+
+// FindComposeFile returns the path of the compose file in the current directory.
+func FindComposeFile() (string, error) {
+	for _, name := range composeFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no compose file found, expected one of %v", composeFileNames)
+}
+
+// LoadComposeServices parses the compose file at path and returns every service
+// that declares a build section, sorted by name. Services that only reference
+// a prebuilt image are skipped.
+func LoadComposeServices(path string) ([]ComposeService, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read compose file '%s': %w", path, err)
+	}
+
+	var project struct {
+		Services map[string]struct {
+			Build *composeBuild `yaml:"build"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(content, &project); err != nil {
+		return nil, fmt.Errorf("failed to parse compose file '%s': %w", path, err)
+	}
+
+	baseDir := filepath.Dir(path)
+	var services []ComposeService
+	for name, svc := range project.Services {
+		if svc.Build == nil {
+			continue
+		}
+
+		context := svc.Build.Context
+		if context == "" {
+			context = "."
+		}
+		context = filepath.Join(baseDir, context)
+
+		// docker build resolves -f relative to the working directory, not the context
+		var dockerfile string
+		if svc.Build.Dockerfile != "" {
+			dockerfile = filepath.Join(context, svc.Build.Dockerfile)
+		}
+
+		services = append(services, ComposeService{Name: name, Context: context, Dockerfile: dockerfile})
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
@@ -1,11 +1,20 @@
 package pkg
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
@@ -15,18 +24,126 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// Supported image builders.
+const (
+	BuilderDocker  = "docker"
+	BuilderBuildah = "buildah"
+	BuilderKaniko  = "kaniko"
+)
+
+// Supported deployment strategies.
+const (
+	StrategyRolling   = "rolling"
+	StrategyBlueGreen = "blueGreen"
+)
+
 // Config holds all configuration values for Dockwright.
 type Config struct {
-	ArtifactName      string
-	HelmFlavour       string
-	DockerNamespace   string
-	DockerHost        string
-	KubernetesConfig  string
-	KubernetesContext string
-	Env               []string
-	DryRun            bool
-	RunDockerBuild    bool
-	AutoApprove       bool
+	ArtifactName                   string
+	HelmReleaseName                string
+	HelmFlavour                    string
+	HelmFlavourSource              string
+	HelmChartPath                  string
+	HelmRepository                 string
+	HelmChart                      string
+	HelmChartVersion               string
+	HelmSet                        map[string]string
+	HelmSetString                  map[string]string
+	HelmSetFile                    map[string]string
+	HelmExtraValuesFiles           []string
+	HelmDiff                       bool
+	HelmAtomic                     bool
+	HelmCleanupOnFail              bool
+	HelmWait                       bool
+	HelmTimeout                    time.Duration
+	HelmRolloutStatus              bool
+	HelmRunTests                   bool
+	HelmStrategy                   string
+	HelmBlueGreenService           string
+	HelmBlueGreenGracePeriod       time.Duration
+	HelmPostRenderer               string
+	HelmPostRendererArgs           []string
+	HelmUpdateDependencies         bool
+	HelmPublishRepository          string
+	HelmTemplateValues             bool
+	HelmMetadata                   bool
+	HelmMetadataAnnotations        bool
+	HelmWorkflowTimeout            time.Duration
+	DockerNamespace                string
+	DockerHost                     string
+	DockerPlatforms                []string
+	DockerPlatformBuilders         map[string]string
+	DockerMaxSizeMB                int
+	DockerMinFreeSpaceMB           int
+	DockerCompose                  bool
+	DockerBuilder                  string
+	DockerInsecure                 bool
+	DockerMirror                   string
+	DockerAlwaysPull               bool
+	DockerProvenance               string
+	DockerBuilderID                string
+	DockerBuildTimeout             time.Duration
+	ToolMinVersions                map[string]string
+	KubernetesConfig               string
+	KubernetesContext              string
+	KubernetesNamespace            string
+	KubernetesCreateNamespace      bool
+	KubernetesNamespaceLabels      map[string]string
+	KubernetesNamespaceAnnotations map[string]string
+	KubernetesImagePullSecret      string
+	KubernetesProtectedContexts    []string
+	KubernetesAllowedContexts      []string
+	KubernetesCertExpiryDays       int
+	Env                            []string
+	EnvironmentsAllowed            []string
+	EnvironmentsProtected          []string
+	Selector                       map[string]string
+	Environments                   map[string]EnvironmentConfig
+	Releases                       []CompanionRelease
+	Hooks                          map[string][]Hook
+	Stages                         []CustomStage
+	Notifications                  []Notification
+	AuditBackends                  []AuditBackend
+	Plugins                        []Plugin
+	DryRun                         bool
+	ServerDryRun                   bool
+	RunDockerBuild                 bool
+	AutoApprove                    bool
+	DeployAutoRollback             bool
+	DeployBlockingJobs             []string
+	DeploySmokeTestPath            string
+	DeploySmokeTestTarget          string
+	DeploySmokeTestStatus          int
+	DeploySmokeTestRetries         int
+	DeploySmokeTestTimeout         time.Duration
+	DeployTimeout                  time.Duration
+	DeployEnvConcurrency           int
+	RetryAttempts                  int
+	RetryBackoff                   time.Duration
+	RetryErrors                    []string
+	RetrySteps                     map[string]RetryPolicy
+	ValidationSkipChecks           []string
+	ValidationChecks               []string
+	ValidationTimeout              time.Duration
+	GitRequireClean                bool
+	GitAllowedRefs                 []string
+	PluginPaths                    []string
+	HistoryFile                    string
+	ReportFile                     string
+	AuditRequired                  bool
+	Debug                          bool // set by --debug, shows the full helm and docker output
+	AllowProtected                 bool // set by --allow-protected, skips typing the name of protected contexts
+	Strict                         bool // set by --strict, fails the validation on warnings
+	Offline                        bool // set by --offline, skips the checks that need the network or a daemon
+	Resume                         bool // set by --resume, skips the stages the failed previous run completed
+	SkipDocker                     bool // set by --skip-docker, skips the docker stage of this run
+	SkipHelm                       bool // set by --skip-helm, skips the helm stage of this run
+
+	run         *runRecorder    // records the run of deploy or build, shared by the derived configurations
+	logger      *log.Logger     // logs of an environment deployed in parallel, see log()
+	tagOverride string          // tag of a dev rebuild, or the digest a promotion pins
+	cliFields   map[string]bool // names of the fields given as CLI flags, which environments don't override
+	imageCommit string          // commit a promoted image was deployed from, which tokens approve and the release records instead of HEAD
 }
 
 // ConfigField defines metadata for a single configuration option.
@@ -37,6 +154,7 @@ type ConfigField struct {
 	Description string
 	Required    bool
 	Default     string
+	Repeatable  bool // flag may be given multiple times, values are joined with commas
 }
 
 // ConfigFields returns all available configuration field definitions.
@@ -54,9 +172,223 @@ func ConfigFields() []ConfigField {
 			Name:        "helmFlavour",
 			ConfigPath:  "helm.flavour",
 			Flag:        "helm-flavour",
-			Description: "Helm chart flavour (stateful or stateless)",
+			Description: "Helm chart flavour (stateful, stateless or one from helm.flavourSource)",
 			Required:    true,
 		},
+		{
+			Name:        "helmFlavourSource",
+			ConfigPath:  "helm.flavourSource",
+			Flag:        "helm-flavour-source",
+			Description: "Directory, git repository or oci:// registry providing additional flavour charts",
+			Required:    false,
+		},
+		{
+			Name:        "helmReleaseName",
+			ConfigPath:  "helm.releaseName",
+			Flag:        "helm-release-name",
+			Description: "Helm release name, may use {{.ArtifactName}} and {{.Env}} (defaults to the artifact name)",
+			Required:    false,
+		},
+		{
+			Name:        "helmChartPath",
+			ConfigPath:  "helm.chartPath",
+			Flag:        "helm-chart-path",
+			Description: "Path to a project-local Helm chart, used instead of the flavour chart",
+			Required:    false,
+		},
+		{
+			Name:        "helmRepository",
+			ConfigPath:  "helm.repository",
+			Flag:        "helm-repository",
+			Description: "URL of the Helm chart repository serving helm.chart",
+			Required:    false,
+		},
+		{
+			Name:        "helmChart",
+			ConfigPath:  "helm.chart",
+			Flag:        "helm-chart",
+			Description: "Name of a remote chart or oci:// reference, used instead of the flavour chart",
+			Required:    false,
+		},
+		{
+			Name:        "helmChartVersion",
+			ConfigPath:  "helm.chartVersion",
+			Flag:        "helm-chart-version",
+			Description: "Chart version or semver range, e.g. ~1.4.0 (defaults to the latest)",
+			Required:    false,
+		},
+		{
+			Name:        "helmSet",
+			ConfigPath:  "helm.set",
+			Flag:        "set",
+			Description: "Set a chart value (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "helmSetString",
+			ConfigPath:  "helm.setString",
+			Flag:        "set-string",
+			Description: "Set a chart value as a string (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "helmSetFile",
+			ConfigPath:  "helm.setFile",
+			Flag:        "set-file",
+			Description: "Set a chart value to the content of a file (key=path), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "helmExtraValuesFiles",
+			ConfigPath:  "helm.extraValuesFiles",
+			Flag:        "values",
+			Description: "Additional values file applied after the environment values files, may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "helmDiff",
+			ConfigPath:  "helm.diff",
+			Flag:        "helm-diff",
+			Description: "Show a helm diff of the release and confirm it before upgrading",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmAtomic",
+			ConfigPath:  "helm.atomic",
+			Flag:        "helm-atomic",
+			Description: "Roll the release back automatically if the upgrade fails (implies --wait)",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmCleanupOnFail",
+			ConfigPath:  "helm.cleanupOnFail",
+			Flag:        "helm-cleanup-on-fail",
+			Description: "Delete resources created by a failed upgrade, so they don't block the next attempt",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmWait",
+			ConfigPath:  "helm.wait",
+			Flag:        "helm-wait",
+			Description: "Wait until all release resources are ready before reporting success",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmTimeout",
+			ConfigPath:  "helm.timeout",
+			Flag:        "helm-timeout",
+			Description: "Time to wait for the release to become ready (e.g., 5m, 0 uses the helm default)",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "helmRolloutStatus",
+			ConfigPath:  "helm.rolloutStatus",
+			Flag:        "helm-rollout-status",
+			Description: "Wait for the Deployments, StatefulSets and DaemonSets of the release to finish rolling out",
+			Required:    false,
+			Default:     "true",
+		},
+		{
+			Name:        "helmRunTests",
+			ConfigPath:  "helm.runTests",
+			Flag:        "helm-run-tests",
+			Description: "Run helm test against the release after deploying it",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmStrategy",
+			ConfigPath:  "helm.strategy",
+			Flag:        "helm-strategy",
+			Description: "Deployment strategy (rolling or blueGreen)",
+			Required:    false,
+			Default:     StrategyRolling,
+		},
+		{
+			Name:        "helmBlueGreenService",
+			ConfigPath:  "helm.blueGreen.service",
+			Flag:        "helm-blue-green-service",
+			Description: "Service whose selector is switched to the new color (defaults to the release name)",
+			Required:    false,
+		},
+		{
+			Name:        "helmBlueGreenGracePeriod",
+			ConfigPath:  "helm.blueGreen.gracePeriod",
+			Flag:        "helm-blue-green-grace-period",
+			Description: "How long to keep the previous color after switching, 0 keeps it until the next deploy",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "helmPostRenderer",
+			ConfigPath:  "helm.postRenderer",
+			Flag:        "helm-post-renderer",
+			Description: "Executable that post-processes rendered manifests (e.g. a kustomize wrapper)",
+			Required:    false,
+		},
+		{
+			Name:        "helmPostRendererArgs",
+			ConfigPath:  "helm.postRendererArgs",
+			Flag:        "helm-post-renderer-args",
+			Description: "Comma-separated arguments passed to the post-renderer",
+			Required:    false,
+		},
+		{
+			Name:        "helmUpdateDependencies",
+			ConfigPath:  "helm.updateDependencies",
+			Flag:        "helm-update-dependencies",
+			Description: "Update the dependencies of a local chart before deploying, as helm dependency update does",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmPublishRepository",
+			ConfigPath:  "helm.publishRepository",
+			Flag:        "helm-publish-repository",
+			Description: "Chart repository (ChartMuseum API) or oci:// registry that dockwright chart publish pushes to",
+			Required:    false,
+		},
+		{
+			Name:        "helmTemplateValues",
+			ConfigPath:  "helm.templateValues",
+			Flag:        "helm-template-values",
+			Description: "Render the values files as Go templates with the pipeline variables before deploying",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmMetadata",
+			ConfigPath:  "helm.metadata",
+			Flag:        "helm-metadata",
+			Description: "Label releases with the dockwright version, git commit, deployer and time",
+			Required:    false,
+			Default:     "true",
+		},
+		{
+			Name:        "helmMetadataAnnotations",
+			ConfigPath:  "helm.metadataAnnotations",
+			Flag:        "helm-metadata-annotations",
+			Description: "Annotate deployed resources with the dockwright version, git commit and deployer as well",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "helmWorkflowTimeout",
+			ConfigPath:  "helm.workflowTimeout",
+			Flag:        "helm-workflow-timeout",
+			Description: "Abort the Helm workflow after this duration (e.g., 30m, 0 disables the timeout)",
+			Required:    false,
+			Default:     "0",
+		},
 		{
 			Name:        "dockerNamespace",
 			ConfigPath:  "docker.namespace",
@@ -72,6 +404,104 @@ func ConfigFields() []ConfigField {
 			Required:    false,
 			Default:     os.Getenv("REGISTRY_HOST"),
 		},
+		{
+			Name:        "dockerPlatforms",
+			ConfigPath:  "docker.platforms",
+			Flag:        "docker-platforms",
+			Description: "Comma-separated list of target platforms (e.g., linux/amd64,linux/arm64)",
+			Required:    false,
+		},
+		{
+			Name:        "dockerPlatformBuilders",
+			ConfigPath:  "docker.platformBuilders",
+			Flag:        "docker-platform-builders",
+			Description: "Comma-separated platform=builder pairs selecting the buildx builder per platform",
+			Required:    false,
+		},
+		{
+			Name:        "dockerMaxSizeMB",
+			ConfigPath:  "docker.maxSizeMB",
+			Flag:        "docker-max-size-mb",
+			Description: "Fail the build if the image exceeds this size in MB (0 disables the check)",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "dockerMinFreeSpaceMB",
+			ConfigPath:  "docker.minFreeSpaceMB",
+			Flag:        "docker-min-free-space-mb",
+			Description: "Warn when less disk space is free for the build, in MB (0 disables the check)",
+			Required:    false,
+			Default:     "5120",
+		},
+		{
+			Name:        "dockerCompose",
+			ConfigPath:  "docker.compose",
+			Flag:        "docker-compose",
+			Description: "Build and deploy every service with a build section in the compose file",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "dockerBuilder",
+			ConfigPath:  "docker.builder",
+			Flag:        "docker-builder",
+			Description: "Image build backend (docker, buildah or kaniko)",
+			Required:    false,
+			Default:     BuilderDocker,
+		},
+		{
+			Name:        "dockerInsecure",
+			ConfigPath:  "docker.insecure",
+			Flag:        "docker-insecure",
+			Description: "Allow plain HTTP or unverified TLS connections to the registry",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "dockerMirror",
+			ConfigPath:  "docker.mirror",
+			Flag:        "docker-mirror",
+			Description: "Registry mirror (pull-through cache) used for base images",
+			Required:    false,
+		},
+		{
+			Name:        "dockerAlwaysPull",
+			ConfigPath:  "docker.alwaysPull",
+			Flag:        "docker-always-pull",
+			Description: "Always pull newer versions of base images during build",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "dockerProvenance",
+			ConfigPath:  "docker.provenance",
+			Flag:        "docker-provenance",
+			Description: "Attach a SLSA provenance attestation to the pushed image (min or max)",
+			Required:    false,
+		},
+		{
+			Name:        "dockerBuilderID",
+			ConfigPath:  "docker.builderId",
+			Flag:        "docker-builder-id",
+			Description: "Builder identity recorded in the provenance attestation",
+			Required:    false,
+		},
+		{
+			Name:        "dockerBuildTimeout",
+			ConfigPath:  "docker.buildTimeout",
+			Flag:        "docker-build-timeout",
+			Description: "Abort the Docker workflow after this duration (e.g., 20m, 0 disables the timeout)",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "toolMinVersions",
+			ConfigPath:  "tools.minVersions",
+			Flag:        "tool-min-versions",
+			Description: "Comma-separated tool=version pairs raising the minimum versions of docker, buildah and helm",
+			Required:    false,
+		},
 		{
 			Name:        "kubernetesConfig",
 			ConfigPath:  "kubernetes.config",
@@ -88,6 +518,68 @@ func ConfigFields() []ConfigField {
 			Required:    true,
 			Default:     currentKubeContext(),
 		},
+		{
+			Name:        "kubernetesNamespace",
+			ConfigPath:  "kubernetes.namespace",
+			Flag:        "kubernetes-namespace",
+			Description: "Kubernetes namespace to deploy into (defaults to the context's namespace)",
+			Required:    false,
+		},
+		{
+			Name:        "kubernetesCreateNamespace",
+			ConfigPath:  "kubernetes.createNamespace",
+			Flag:        "kubernetes-create-namespace",
+			Description: "Create the namespace if it does not exist",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "kubernetesNamespaceLabels",
+			ConfigPath:  "kubernetes.namespaceLabels",
+			Flag:        "kubernetes-namespace-label",
+			Description: "Label of a namespace created by Dockwright (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "kubernetesNamespaceAnnotations",
+			ConfigPath:  "kubernetes.namespaceAnnotations",
+			Flag:        "kubernetes-namespace-annotation",
+			Description: "Annotation of a namespace created by Dockwright (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "kubernetesImagePullSecret",
+			ConfigPath:  "kubernetes.imagePullSecret",
+			Flag:        "kubernetes-image-pull-secret",
+			Description: "Name of a docker-registry Secret to create from the registry credentials and pass as imagePullSecrets",
+			Required:    false,
+		},
+		{
+			Name:        "kubernetesProtectedContexts",
+			ConfigPath:  "kubernetes.protectedContexts",
+			Flag:        "kubernetes-protected-context",
+			Description: "Context (or pattern) that requires typing its name or --allow-protected to deploy to, may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "kubernetesAllowedContexts",
+			ConfigPath:  "kubernetes.allowedContexts",
+			Flag:        "kubernetes-allowed-context",
+			Description: "Context (or pattern) Dockwright may deploy to; others are refused, may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "kubernetesCertExpiryDays",
+			ConfigPath:  "kubernetes.certExpiryDays",
+			Flag:        "kubernetes-cert-expiry-days",
+			Description: "Warn when a kubeconfig client certificate or token expires within this many days",
+			Required:    false,
+			Default:     "14",
+		},
 		{
 			Name:        "env",
 			ConfigPath:  "env",
@@ -95,6 +587,29 @@ func ConfigFields() []ConfigField {
 			Description: "Comma-separated list of environments (e.g., staging,production)",
 			Required:    false,
 		},
+		{
+			Name:        "environmentsAllowed",
+			ConfigPath:  "environments.allowed",
+			Flag:        "allowed-envs",
+			Description: "Comma-separated list of the known environments; others are refused",
+			Required:    false,
+		},
+		{
+			Name:        "environmentsProtected",
+			ConfigPath:  "environments.protected",
+			Flag:        "protected-env",
+			Description: "Environment or pattern whose deploys need its name typed or an approval token, repeatable",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "selector",
+			ConfigPath:  "selector",
+			Flag:        "selector",
+			Description: "Only deploy the releases with these labels (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
 		{
 			Name:        "dryRun",
 			ConfigPath:  "dry-run",
@@ -103,6 +618,14 @@ func ConfigFields() []ConfigField {
 			Required:    false,
 			Default:     "false",
 		},
+		{
+			Name:        "serverDryRun",
+			ConfigPath:  "server-dry-run",
+			Flag:        "server-dry-run",
+			Description: "In dry-run mode, also submit the rendered manifests to the cluster with a server-side dry-run",
+			Required:    false,
+			Default:     "false",
+		},
 		{
 			Name:        "runDockerBuild",
 			ConfigPath:  "docker.build",
@@ -119,6 +642,170 @@ func ConfigFields() []ConfigField {
 			Required:    false,
 			Default:     "false",
 		},
+		{
+			Name:        "deployAutoRollback",
+			ConfigPath:  "deploy.autoRollback",
+			Flag:        "auto-rollback",
+			Description: "Roll back to the previous revision when the rollout of a deploy fails",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "deployBlockingJobs",
+			ConfigPath:  "deploy.blockingJobs",
+			Flag:        "blocking-job",
+			Description: "Job to wait for after the upgrade, as a name pattern (db-migrate-*) or label (key=value), may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "deploySmokeTestPath",
+			ConfigPath:  "deploy.smokeTest.path",
+			Flag:        "smoke-test-path",
+			Description: "URL path to probe after the rollout, such as /healthz (enables the smoke test)",
+			Required:    false,
+		},
+		{
+			Name:        "deploySmokeTestTarget",
+			ConfigPath:  "deploy.smokeTest.target",
+			Flag:        "smoke-test-target",
+			Description: "What the smoke test probes: the release's service or its ingress",
+			Required:    false,
+			Default:     "service",
+		},
+		{
+			Name:        "deploySmokeTestStatus",
+			ConfigPath:  "deploy.smokeTest.status",
+			Flag:        "smoke-test-status",
+			Description: "HTTP status the smoke test expects",
+			Required:    false,
+			Default:     "200",
+		},
+		{
+			Name:        "deploySmokeTestRetries",
+			ConfigPath:  "deploy.smokeTest.retries",
+			Flag:        "smoke-test-retries",
+			Description: "How often a failed smoke test request is retried",
+			Required:    false,
+			Default:     "5",
+		},
+		{
+			Name:        "deploySmokeTestTimeout",
+			ConfigPath:  "deploy.smokeTest.timeout",
+			Flag:        "smoke-test-timeout",
+			Description: "Timeout of a single smoke test request",
+			Required:    false,
+			Default:     "10s",
+		},
+		{
+			Name:        "deployTimeout",
+			ConfigPath:  "deploy.timeout",
+			Flag:        "deploy-timeout",
+			Description: "Abort the whole deploy after this duration (e.g., 1h, 0 disables the timeout)",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "deployEnvConcurrency",
+			ConfigPath:  "deploy.envConcurrency",
+			Flag:        "env-concurrency",
+			Description: "Number of environments deployed at the same time",
+			Required:    false,
+			Default:     "1",
+		},
+		{
+			Name:        "retryAttempts",
+			ConfigPath:  "retry.attempts",
+			Flag:        "retry-attempts",
+			Description: "How often a failed registry or cluster operation is attempted, 1 disables retries",
+			Required:    false,
+			Default:     "1",
+		},
+		{
+			Name:        "retryBackoff",
+			ConfigPath:  "retry.backoff",
+			Flag:        "retry-backoff",
+			Description: "Delay before the first retry, doubled for every further retry",
+			Required:    false,
+			Default:     "5s",
+		},
+		{
+			Name:        "retryErrors",
+			ConfigPath:  "retry.errors",
+			Flag:        "retry-error",
+			Description: "Regular expression of the errors that are retried, repeatable (defaults to connection errors and rate limits)",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "validationSkipChecks",
+			ConfigPath:  "validation.skipChecks",
+			Flag:        "skip-checks",
+			Description: "Comma-separated ids of validation checks to skip, e.g. tools,kube-context",
+			Required:    false,
+		},
+		{
+			Name:        "validationChecks",
+			ConfigPath:  "validation.checks",
+			Flag:        "validation-check",
+			Description: "Script run as an additional validation check, repeatable",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "validationTimeout",
+			ConfigPath:  "validation.timeout",
+			Flag:        "validation-timeout",
+			Description: "Abort the validation after this duration (e.g., 5m, 0 disables the timeout)",
+			Required:    false,
+			Default:     "0",
+		},
+		{
+			Name:        "gitRequireClean",
+			ConfigPath:  "git.requireClean",
+			Flag:        "git-require-clean",
+			Description: "Refuse to deploy from a git working tree with uncommitted changes",
+			Required:    false,
+			Default:     "false",
+		},
+		{
+			Name:        "gitAllowedRefs",
+			ConfigPath:  "git.allowedRefs",
+			Flag:        "git-allowed-ref",
+			Description: "Branch or tag pattern deploys are allowed from, may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "pluginPaths",
+			ConfigPath:  "plugins.paths",
+			Flag:        "plugin",
+			Description: "Plugin executable, or directory of plugins, to load, may be repeated",
+			Required:    false,
+			Repeatable:  true,
+		},
+		{
+			Name:        "historyFile",
+			ConfigPath:  "history.file",
+			Flag:        "history-file",
+			Description: "File the runs of deploy, build, promote, rollback, uninstall and scale are recorded in, empty to disable it (default: dockwright/<artifact>/history.jsonl in the user cache directory)",
+			Required:    false,
+		},
+		{
+			Name:        "reportFile",
+			ConfigPath:  "report.file",
+			Flag:        "report-file",
+			Description: "File a JSON report of each recorded run is written to",
+			Required:    false,
+		},
+		{
+			Name:        "auditRequired",
+			ConfigPath:  "audit.required",
+			Flag:        "audit-required",
+			Description: "Refuse to deploy, promote, roll back, uninstall or scale when its start can't be recorded in the audit log",
+			Required:    false,
+			Default:     "false",
+		},
 	}
 }
 
@@ -135,58 +822,310 @@ func LoadConfig(cmd *cobra.Command) (*Config, error) {
 	fields := ConfigFields()
 
 	for _, field := range fields {
-		value := resolveFieldValue(cmd, field)
+		value, err := resolveFieldValue(cmd, field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config field %s: %w", field.Name, err)
+		}
 		if err := setConfigField(cfg, field, value); err != nil {
 			return nil, fmt.Errorf("failed to set config field %s: %w", field.Name, err)
 		}
+		if field.setOnCommandLine(cmd) {
+			if cfg.cliFields == nil {
+				cfg.cliFields = map[string]bool{}
+			}
+			cfg.cliFields[field.Name] = true
+		}
+	}
+
+	if err := cfg.validateEnvNames(); err != nil {
+		return nil, err
+	}
+	if !cfg.cliFields["historyFile"] && !viper.IsSet("history.file") {
+		cfg.HistoryFile = defaultHistoryFile(cfg.ArtifactName)
+	}
+
+	environments, err := loadEnvironments()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Environments = environments
+	cfg.KubernetesConfig = expandKubeConfig(cfg.KubernetesConfig)
+
+	releases, err := loadCompanionReleases()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Releases = releases
+
+	hooks, err := loadHooks()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Hooks = hooks
+
+	stages, err := loadCustomStages()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Stages = stages
+
+	notifications, err := loadNotifications()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Notifications = notifications
+
+	auditBackends, err := loadAuditBackends()
+	if err != nil {
+		return nil, err
+	}
+	cfg.AuditBackends = auditBackends
+
+	retrySteps, err := loadRetrySteps()
+	if err != nil {
+		return nil, err
+	}
+	cfg.RetrySteps = retrySteps
+
+	if cmd != nil && slices.Contains(pluginCommands, cmd.Name()) {
+		plugins, err := loadPlugins(cfg.PluginPaths)
+		if err != nil {
+			return nil, err
+		}
+		if err := applyPluginCredentials(plugins); err != nil {
+			return nil, err
+		}
+		cfg.Plugins = plugins
+	}
+
+	if cmd != nil && slices.Contains(releaseCommands, cmd.Name()) {
+		if err := cfg.validateReleaseNames(); err != nil {
+			return nil, err
+		}
+	}
+
+	if cmd != nil {
+		cfg.Debug, _ = cmd.Flags().GetBool("debug")
+		cfg.AllowProtected, _ = cmd.Flags().GetBool("allow-protected")
+		cfg.Strict, _ = cmd.Flags().GetBool("strict")
+		cfg.Offline, _ = cmd.Flags().GetBool("offline")
+		cfg.Resume, _ = cmd.Flags().GetBool("resume")
+		cfg.SkipDocker, _ = cmd.Flags().GetBool("skip-docker")
+		cfg.SkipHelm, _ = cmd.Flags().GetBool("skip-helm")
 	}
 
 	return cfg, nil
 }
 
+// toolOutput returns where the progress output of helm and docker goes: the
+// terminal with --debug, and nowhere otherwise.
+func (c *Config) toolOutput() io.Writer {
+	if c.Debug && c.logger != nil {
+		return &lineWriter{logger: c.logger}
+	}
+	if c.Debug {
+		return os.Stderr
+	}
+	return io.Discard
+}
+
+// fieldValue is the resolved value of a config field. A flag, a scalar in
+// the config file and a default are a single value, separating the items of
+// list and map fields with commas. The values of a repeatable flag and the
+// entries of a config file list or map are items of their own, kept whole so
+// that they may contain commas.
+type fieldValue struct {
+	value   string
+	items   []string
+	isItems bool
+}
+
+// setOnCommandLine reports whether the field was given as a CLI flag.
+func (f ConfigField) setOnCommandLine(cmd *cobra.Command) bool {
+	return cmd != nil && cmd.Flags().Changed(f.Flag)
+}
+
 // resolveFieldValue determines the value for a field based on precedence.
-func resolveFieldValue(cmd *cobra.Command, field ConfigField) string {
+func resolveFieldValue(cmd *cobra.Command, field ConfigField) (fieldValue, error) {
 	// Priority 1: CLI flags
-	if cmd != nil && cmd.Flags().Changed(field.Flag) {
-		if val, err := cmd.Flags().GetString(field.Flag); err == nil {
-			return val
+	if field.setOnCommandLine(cmd) {
+		if field.Repeatable {
+			if vals, err := cmd.Flags().GetStringArray(field.Flag); err == nil {
+				return fieldValue{items: vals, isItems: true}, nil
+			}
+		} else if val, err := cmd.Flags().GetString(field.Flag); err == nil {
+			return fieldValue{value: val}, nil
 		}
 	}
 
 	// Priority 2: Config file
 	if viper.IsSet(field.ConfigPath) {
-		return viper.GetString(field.ConfigPath)
+		return configFileValue(field.ConfigPath)
 	}
 
 	// Priority 3: Default
-	return field.Default
+	return fieldValue{value: field.Default}, nil
+}
+
+// configFileValue reads a config file entry. The entries of lists become
+// items, and maps become key=value items with dot-separated keys for nested
+// maps.
+func configFileValue(path string) (fieldValue, error) {
+	switch raw := viper.Get(path).(type) {
+	case []interface{}:
+		items := make([]string, 0, len(raw))
+		for i, item := range raw {
+			value, err := scalarConfigValue(item)
+			if err != nil {
+				return fieldValue{}, fmt.Errorf("%s[%d]: %w", path, i, err)
+			}
+			items = append(items, value)
+		}
+		return fieldValue{items: items, isItems: true}, nil
+	case map[string]interface{}:
+		// viper lowercases keys and splits dotted keys, so maps are read as written
+		if value, ok := rawConfigValue(path); ok {
+			if m, ok := value.(map[string]interface{}); ok {
+				raw = m
+			}
+		}
+		pairs, err := flattenMap("", raw)
+		if err != nil {
+			return fieldValue{}, fmt.Errorf("%s.%w", path, err)
+		}
+		sort.Strings(pairs)
+		return fieldValue{items: pairs, isItems: true}, nil
+	default:
+		return fieldValue{value: viper.GetString(path)}, nil
+	}
+}
+
+// scalarConfigValue returns a string, number or boolean of the config file
+// as a string. Nested lists and maps have no string form.
+func scalarConfigValue(value interface{}) (string, error) {
+	switch value.(type) {
+	case []interface{}, map[string]interface{}:
+		return "", fmt.Errorf("expected a string, number or boolean, got a nested list or map")
+	case nil:
+		return "", nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// rawConfigValue returns the value at path in the config file exactly as written.
+func rawConfigValue(path string) (interface{}, bool) {
+	content, err := os.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, false
+	}
+
+	var current interface{}
+	if err := yaml.Unmarshal(content, &current); err != nil {
+		return nil, false
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		found := false
+		for key, value := range m {
+			if strings.EqualFold(key, segment) {
+				current, found = value, true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// flattenMap converts a nested map into key=value pairs with dot-separated keys.
+func flattenMap(prefix string, m map[string]interface{}) ([]string, error) {
+	var pairs []string
+	for key, value := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			nestedPairs, err := flattenMap(key, nested)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, nestedPairs...)
+			continue
+		}
+		scalar, err := scalarConfigValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		pairs = append(pairs, key+"="+scalar)
+	}
+	return pairs, nil
 }
 
 // setConfigField sets a field on the Config struct by name.
-func setConfigField(cfg *Config, field ConfigField, value string) error {
+func setConfigField(cfg *Config, field ConfigField, resolved fieldValue) error {
 	v := reflect.ValueOf(cfg).Elem()
 	f := v.FieldByName(cases.Title(language.Und, cases.NoLower).String(field.Name))
 	if !f.IsValid() {
 		return fmt.Errorf("field %s not found in Config struct", field.Name)
 	}
 
+	// Items are split only from single values, never within an item
+	items := slices.DeleteFunc(slices.Clone(resolved.items), func(item string) bool { return strings.TrimSpace(item) == "" })
+	if !resolved.isItems {
+		items = parseList(resolved.value, ",")
+	}
+	value := resolved.value
+	if resolved.isItems {
+		value = strings.Join(resolved.items, ",")
+	}
+
+	if f.Type() == reflect.TypeOf(time.Duration(0)) {
+		parsed, err := parseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
 	switch f.Kind() {
 	case reflect.String:
 		f.SetString(value)
 	case reflect.Bool:
 		parsed := parseBool(value)
 		f.SetBool(parsed)
+	case reflect.Int:
+		parsed, err := parseInt(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(parsed))
 	case reflect.Slice:
 		if f.Type().Elem().Kind() == reflect.String {
-			parsed := parseList(value, ",")
-			slice := reflect.MakeSlice(f.Type(), len(parsed), len(parsed))
-			for i, item := range parsed {
-				slice.Index(i).SetString(item)
+			slice := reflect.MakeSlice(f.Type(), len(items), len(items))
+			for i, item := range items {
+				slice.Index(i).SetString(strings.TrimSpace(item))
 			}
 			f.Set(slice)
 		} else {
 			return fmt.Errorf("unsupported slice element type: %s", f.Type().Elem().Kind())
 		}
+	case reflect.Map:
+		if f.Type().Key().Kind() == reflect.String && f.Type().Elem().Kind() == reflect.String {
+			parsed, err := parsePairs(items)
+			if err != nil {
+				return err
+			}
+			f.Set(reflect.ValueOf(parsed))
+		} else {
+			return fmt.Errorf("unsupported map type: %s", f.Type())
+		}
 	default:
 		return fmt.Errorf("unsupported field type: %s", f.Kind())
 	}
@@ -198,6 +1137,30 @@ func parseBool(value string) bool {
 	return value == "true" || value == "1" || strings.ToLower(value) == "yes"
 }
 
+// parseInt parses a string to int, treating an empty string as zero.
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer value: %s", value)
+	}
+	return parsed, nil
+}
+
+// parseDuration parses a duration string such as "90s" or "10m", treating an empty string as zero.
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration value: %s", value)
+	}
+	return parsed, nil
+}
+
 // parseList parses a separator-separated string into a slice of strings.
 func parseList(value, sep string) []string {
 	if value == "" {
@@ -212,12 +1175,26 @@ func parseList(value, sep string) []string {
 	return items
 }
 
-func defaultKubeConfigPath() string {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
+// parseMap parses a separator-separated list of key=value pairs into a map.
+func parseMap(value, sep string) (map[string]string, error) {
+	return parsePairs(parseList(value, sep))
+}
+
+// parsePairs parses key=value pairs into a map. Only the first = separates
+// the key from the value, which may contain anything else.
+func parsePairs(pairs []string) (map[string]string, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	items := make(map[string]string)
+	for _, pair := range pairs {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid key=value pair: %s", pair)
+		}
+		items[strings.TrimSpace(key)] = strings.TrimSpace(val)
 	}
-	return filepath.Join(home, ".kube", "config")
+	return items, nil
 }
 
 // ImageRepository returns the full Docker image repository path.
@@ -234,28 +1211,195 @@ func (c *Config) ImageTag() (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s:latest", repo), nil
+	return fmt.Sprintf("%s:%s", repo, c.tag()), nil
+}
+
+// tag returns the tag of the artifact's image: latest, the tag of the
+// current rebuild of dockwright dev, or latest pinned to the digest
+// dockwright promote deploys.
+func (c *Config) tag() string {
+	if c.tagOverride != "" {
+		return c.tagOverride
+	}
+	return "latest"
+}
+
+// PlatformImageTag returns the per-platform Docker image tag used when assembling a manifest list.
+func (c *Config) PlatformImageTag(platform string) (string, error) {
+	repo, err := c.ImageRepository()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%s-%s", repo, c.tag(), strings.ReplaceAll(platform, "/", "-")), nil
+}
+
+// IsMultiPlatform returns true if the image should be published as a multi-platform manifest list.
+func (c *Config) IsMultiPlatform() bool {
+	return len(c.DockerPlatforms) > 1
+}
+
+// Digest returns the SHA-256 digest of the resolved configuration.
+func (c *Config) Digest() (string, error) {
+	content, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute the configuration digest: %w", err)
+	}
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(content)), nil
+}
+
+// SkipsStage reports whether --skip-docker or --skip-helm skips a pipeline
+// stage in this run.
+func (c *Config) SkipsStage(stage string) bool {
+	return stage == "docker" && c.SkipDocker || stage == "helm" && c.SkipHelm
 }
 
 // ShouldRunDockerBuild returns true if Docker build should be run.
-// It returns false if either Dockerfile is not found or runDockerBuild is set to false.
+// It returns false if either Dockerfile (or compose file in compose mode) is not found or runDockerBuild is set to false.
 func (c *Config) ShouldRunDockerBuild() bool {
+	if c.DockerCompose {
+		_, err := FindComposeFile()
+		return err == nil && c.RunDockerBuild
+	}
+
 	_, err := os.Stat("Dockerfile")
 	hasDockerfile := err == nil
 
 	return hasDockerfile && c.RunDockerBuild
 }
 
-// ChartPath returns the path to the Helm chart based on flavour.
+// ComposeServices returns the buildable services of the compose project.
+func (c *Config) ComposeServices() ([]ComposeService, error) {
+	path, err := FindComposeFile()
+	if err != nil {
+		return nil, err
+	}
+	return LoadComposeServices(path)
+}
+
+// ForService returns a copy of the configuration whose artifact is the given compose service.
+func (c *Config) ForService(service string) *Config {
+	svcCfg := *c
+	svcCfg.ArtifactName = fmt.Sprintf("%s-%s", c.ArtifactName, service)
+	return &svcCfg
+}
+
+// releaseNamePattern matches the release names helm accepts.
+var releaseNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// ReleaseName returns the name of the Helm release, rendered from
+// helm.releaseName or the artifact name if unset. The template is checked by
+// LoadConfig for the commands that use it, so rendering cannot fail here.
+func (c *Config) ReleaseName() string {
+	name, _ := c.renderReleaseName()
+	return name
+}
+
+func (c *Config) renderReleaseName() (string, error) {
+	if c.HelmReleaseName == "" {
+		return c.ArtifactName, nil
+	}
+
+	tmpl, err := template.New("releaseName").Option("missingkey=error").Parse(c.HelmReleaseName)
+	if err != nil {
+		return "", fmt.Errorf("invalid helm.releaseName template: %w", err)
+	}
+
+	var name strings.Builder
+	data := struct{ ArtifactName, Env string }{c.ArtifactName, c.EnvName()}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("failed to render helm.releaseName: %w", err)
+	}
+	return name.String(), nil
+}
+
+// releaseCommands are the commands that deploy, or plan, check or operate on
+// the deployed releases, and so need valid release names. Commands such as
+// build and prune don't, and don't fail on them.
+var releaseCommands = []string{
+	"deploy", "dev", "promote", "plan", "validate", "render",
+	"rollback", "history", "uninstall", "scale", "drift", "exec", "port-forward",
+}
+
+// pluginCommands are the commands that load plugins: those running pipeline
+// steps or validation checks, or logging in to a registry or chart
+// repository. Describing the plugins and fetching their credentials takes a
+// while, so the other commands don't.
+var pluginCommands = []string{"deploy", "dev", "build", "promote", "promote-image", "validate", "publish"}
+
+// validateReleaseNames checks that helm.releaseName, rendered for every
+// compose service, and the names of the companion releases render to
+// distinct, valid release names for every environment, and that the needs of
+// the companion releases can be resolved.
+func (c *Config) validateReleaseNames() error {
+	var services []ComposeService
+	if c.DockerCompose {
+		var err error
+		if services, err = c.ComposeServices(); err != nil {
+			return err
+		}
+	}
+
+	for _, envCfg := range c.PerEnvironment() {
+		releases := []*Config{envCfg}
+		if c.DockerCompose {
+			releases = releases[:0]
+			for _, svc := range services {
+				releases = append(releases, envCfg.ForService(svc.Name))
+			}
+		}
+		for _, rel := range c.Releases {
+			releases = append(releases, envCfg.ForRelease(rel))
+		}
+
+		seen := make(map[string]bool)
+		for _, relCfg := range releases {
+			name, err := relCfg.renderReleaseName()
+			if err != nil {
+				return err
+			}
+			if len(name) > 53 || !releaseNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid release name '%s': it must be at most 53 lowercase alphanumeric characters, '-' or '.'", name)
+			}
+			if seen[name] {
+				if c.DockerCompose {
+					return fmt.Errorf("release name '%s' is used more than once. Please give each of helm.releases a distinct name, and include {{.ArtifactName}} in helm.releaseName so that each compose service gets its own release", name)
+				}
+				return fmt.Errorf("release name '%s' is used more than once, please give each of helm.releases a distinct name", name)
+			}
+			seen[name] = true
+		}
+
+		if _, err := envCfg.orderedReleases(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UsesFlavourChart returns true if the release is deployed from one of the base flavour charts.
+func (c *Config) UsesFlavourChart() bool {
+	return c.HelmChartPath == "" && c.HelmChart == ""
+}
+
+// IsOCIChart returns true if the remote chart is stored in an OCI registry.
+func (c *Config) IsOCIChart() bool {
+	return strings.HasPrefix(c.HelmChart, "oci://")
+}
+
+// ChartPath returns the path to the Helm chart: the project-local chart if
+// configured, otherwise the built-in base chart of the flavour.
 func (c *Config) ChartPath() string {
-	return filepath.Join("/usr/local/share/dockwright/charts", c.HelmFlavour)
+	if c.HelmChartPath != "" {
+		return c.HelmChartPath
+	}
+	return filepath.Join(builtinChartsDir, c.HelmFlavour)
 }
 
 // Log prints the configuration in a tabular format.
 func (c *Config) LogSummary() {
 	log.Info("🛠️  Configuration loaded:")
-	log.Info("   Field                | Value")
-	log.Info("   ---------------------|----------------")
+	log.Info("   Field                    | Value")
+	log.Info("   -------------------------|----------------")
 
 	v := reflect.ValueOf(c).Elem()
 	t := v.Type()
@@ -263,6 +1407,9 @@ func (c *Config) LogSummary() {
 	for i := 0; i < v.NumField(); i++ {
 		field := t.Field(i)
 		value := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
 
 		fieldName := field.Name
 		var coloredValue string
@@ -272,30 +1419,16 @@ func (c *Config) LogSummary() {
 			coloredValue = fmt.Sprintf("\033[32m%s\033[0m", value.String())
 		case reflect.Bool:
 			coloredValue = fmt.Sprintf("\033[33m%t\033[0m", value.Bool())
-		case reflect.Slice:
+		case reflect.Int:
+			coloredValue = fmt.Sprintf("\033[33m%d\033[0m", value.Int())
+		case reflect.Slice, reflect.Map:
 			coloredValue = fmt.Sprintf("\033[36m%v\033[0m", value.Interface())
 		default:
 			coloredValue = fmt.Sprintf("%v", value.Interface())
 		}
 
-		log.Infof("   %-20s | %s", fieldName, coloredValue)
-	}
-}
-
-func currentKubeContext() string {
-	path := defaultKubeConfigPath()
-	content, err := os.ReadFile(path)
-	if err != nil {
-		return ""
-	}
-
-	var kubeconfig struct {
-		CurrentContext string `yaml:"current-context"`
-	}
-	if err := yaml.Unmarshal(content, &kubeconfig); err != nil {
-		return ""
+		log.Infof("   %-24s | %s", fieldName, coloredValue)
 	}
-	return kubeconfig.CurrentContext
 }
 
 func currentDirName() string {
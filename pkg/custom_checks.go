@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// customChecksDir holds the team's own validation scripts.
+var customChecksDir = filepath.Join(".dockwright", "checks")
+
+// customCheckWarningExit is the exit code with which a custom check reports
+// a warning rather than a failure.
+const customCheckWarningExit = 10
+
+// customChecks returns a validation check for each script in
+// .dockwright/checks/*.sh and in validation.checks. A script found in both
+// is checked once.
+func (v *Validator) customChecks() []validationCheck {
+	scripts, _ := filepath.Glob(filepath.Join(customChecksDir, "*.sh"))
+	scripts = append(scripts, v.cfg.ValidationChecks...)
+
+	var checks []validationCheck
+	seen := make(map[string]bool)
+	for _, script := range scripts {
+		path, err := filepath.Abs(script)
+		if err != nil {
+			path = filepath.Clean(script)
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		name := strings.TrimSuffix(filepath.Base(script), filepath.Ext(script))
+		checks = append(checks, validationCheck{
+			id:   "custom-" + name,
+			name: "Custom: " + name,
+			icon: "🧩",
+			fn:   func(ctx context.Context) error { return v.runCustomCheck(ctx, script) },
+		})
+	}
+	return checks
+}
+
+// runCustomCheck runs a check script. Scripts that are not executable are
+// run with sh.
+func (v *Validator) runCustomCheck(ctx context.Context, script string) error {
+	info, err := os.Stat(script)
+	if err != nil {
+		return fmt.Errorf("check script %s not found. Please check validation.checks", script)
+	}
+	path, err := filepath.Abs(script)
+	if err != nil {
+		return err
+	}
+	name, args := path, []string(nil)
+	if info.Mode()&0o111 == 0 {
+		name, args = "sh", []string{path}
+	}
+	return v.runCheckCommand(ctx, script, name, args...)
+}
+
+// runCheckCommand runs the command of a custom or plugin check. Exit code 0
+// passes the check, exit code customCheckWarningExit warns and any other
+// fails it, with the command's stdout as the message.
+func (v *Validator) runCheckCommand(ctx context.Context, label, name string, args ...string) error {
+	cmd := command(ctx, name, args...)
+	cmd.Env = append(os.Environ(), v.cfg.pipelineEnv()...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if stderr.Len() > 0 {
+		log.Debugf("%s: %s", label, strings.TrimSpace(stderr.String()))
+	}
+
+	message := strings.TrimSpace(stdout.String())
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case !errors.As(err, &exitErr):
+		return fmt.Errorf("failed to run check %s: %w", label, err)
+	}
+	if message == "" {
+		message = strings.TrimSpace(stderr.String())
+	}
+	if message == "" {
+		message = fmt.Sprintf("%s exited with code %d", label, exitErr.ExitCode())
+	}
+	if exitErr.ExitCode() == customCheckWarningExit {
+		return warningf("%s", message)
+	}
+	return errors.New(message)
+}
+
+// pipelineEnv describes the deployment to check scripts and hooks.
+func (c *Config) pipelineEnv() []string {
+	image, _ := c.ImageTag()
+	return []string{
+		"DOCKWRIGHT_ARTIFACT=" + c.ArtifactName,
+		"DOCKWRIGHT_ENVS=" + strings.Join(c.Env, ","),
+		"DOCKWRIGHT_RELEASE=" + c.ReleaseName(),
+		"DOCKWRIGHT_IMAGE=" + image,
+		"DOCKWRIGHT_CONTEXT=" + c.KubernetesContext,
+		"DOCKWRIGHT_NAMESPACE=" + c.KubernetesNamespace,
+		"DOCKWRIGHT_DRY_RUN=" + strconv.FormatBool(c.DryRun),
+		"DOCKWRIGHT_OFFLINE=" + strconv.FormatBool(c.Offline),
+	}
+}
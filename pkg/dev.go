@@ -0,0 +1,314 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/fsnotify/fsnotify"
+)
+
+// devDebounce is how long dockwright dev waits for a burst of changes, such
+// as an editor saving several files, to settle before it rebuilds.
+const devDebounce = 500 * time.Millisecond
+
+// devLoop rebuilds the artifact's image and upgrades its release whenever
+// its sources change. In a local cluster, the image is loaded into the
+// cluster instead of being pushed to the registry.
+type devLoop struct {
+	cfg         *Config
+	cluster     string // kind, minikube, k3d or docker-desktop, empty for other clusters
+	clusterName string
+	ignore      []string // patterns of .dockerignore
+	iteration   int
+}
+
+// newDevLoop prepares the loop for the context the configuration deploys to.
+func newDevLoop(cfg *Config) *devLoop {
+	l := &devLoop{cfg: cfg, ignore: dockerignorePatterns()}
+
+	context := cfg.KubernetesContext
+	if context == "" {
+		context = currentKubeContext()
+	}
+	switch {
+	case strings.HasPrefix(context, "kind-"):
+		l.cluster, l.clusterName = "kind", strings.TrimPrefix(context, "kind-")
+	case strings.HasPrefix(context, "k3d-"):
+		l.cluster, l.clusterName = "k3d", strings.TrimPrefix(context, "k3d-")
+	case context == "minikube", context == "docker-desktop":
+		l.cluster, l.clusterName = context, context
+	}
+
+	if l.cluster != "" {
+		// The image is never pulled from the registry, so a pull policy of
+		// Always would fail the rollout
+		set := maps.Clone(cfg.HelmSet)
+		if set == nil {
+			set = map[string]string{}
+		}
+		if _, ok := set["image.pullPolicy"]; !ok {
+			set["image.pullPolicy"] = "IfNotPresent"
+		}
+		cfg.HelmSet = set
+	}
+	return l
+}
+
+// local reports whether the images are loaded into a local cluster rather
+// than pushed. Kaniko and multi-platform builds push by design.
+func (l *devLoop) local() bool {
+	return l.cluster != "" && l.cfg.DockerBuilder != BuilderKaniko && !l.cfg.IsMultiPlatform()
+}
+
+// run deploys once and then again after every change, until ctx is
+// cancelled. A failed iteration is logged, and the next change retries.
+func (l *devLoop) run(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch the source tree: %w", err)
+	}
+	defer watcher.Close()
+	if err := l.watchDir(watcher, "."); err != nil {
+		return fmt.Errorf("failed to watch the source tree: %w", err)
+	}
+
+	if l.local() {
+		log.Infof("🏠 Local %s cluster %s: images are loaded into the cluster instead of being pushed", l.cluster, l.clusterName)
+	}
+	l.deploy(ctx, true)
+
+	changed := map[string]bool{}
+	timer := time.NewTimer(devDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("👋 Stopped watching")
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			path := filepath.Clean(event.Name)
+			if l.ignored(path) || event.Op == fsnotify.Chmod {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(path); err == nil && info.IsDir() {
+					if err := l.watchDir(watcher, path); err != nil {
+						log.Warnf("⚠️  Failed to watch %s: %v", path, err)
+					}
+				}
+			}
+			changed[path] = true
+			timer.Reset(devDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warnf("⚠️  Watching the source tree failed: %v", err)
+		case <-timer.C:
+			paths := slices.Sorted(maps.Keys(changed))
+			clear(changed)
+			rebuild := slices.ContainsFunc(paths, l.needsRebuild)
+			if len(paths) == 1 {
+				log.Infof("✏️  %s changed", paths[0])
+			} else {
+				log.Infof("✏️  %d files changed, %s first", len(paths), paths[0])
+			}
+			// Changes made while deploying are queued by the watcher and
+			// start the next iteration
+			l.deploy(ctx, rebuild)
+		}
+	}
+}
+
+// deploy runs one iteration: it builds the image unless only the chart or
+// the values changed, and upgrades the release. Every rebuilt image gets a
+// tag of its own, so that the pods roll out even though nothing else in the
+// release changed.
+func (l *devLoop) deploy(ctx context.Context, rebuild bool) {
+	l.iteration++
+	start := time.Now()
+	logSection(l.iteration, "DEV ITERATION", "🔁")
+
+	rebuild = rebuild && l.cfg.ShouldRunDockerBuild()
+	if rebuild {
+		l.cfg.tagOverride = "dev-" + start.Format("20060102-150405")
+	}
+	err := l.build(ctx, rebuild)
+	if err == nil {
+		err = NewHelmRunner(l.cfg).Run(ctx)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+	case err != nil:
+		log.Errorf("❌ Iteration failed: %v", err)
+		log.Infof("👀 Watching for changes to retry")
+	default:
+		log.Infof("✅ Deployed in %s, watching for changes", time.Since(start).Round(100*time.Millisecond))
+	}
+}
+
+// build builds the images of the iteration and makes them available to the
+// cluster, by loading them into a local cluster or pushing them.
+func (l *devLoop) build(ctx context.Context, rebuild bool) error {
+	if !rebuild {
+		if l.cfg.ShouldRunDockerBuild() {
+			log.Infof("⏭️  Only the chart or the values changed, skipping the image build")
+		}
+		return nil
+	}
+	if !l.local() {
+		if err := NewDockerRunner(l.cfg).Run(ctx); err != nil {
+			return fmt.Errorf("docker workflow failed: %w", err)
+		}
+		return nil
+	}
+
+	runners := []*DockerRunner{NewDockerRunner(l.cfg)}
+	if l.cfg.DockerCompose {
+		services, err := l.cfg.ComposeServices()
+		if err != nil {
+			return err
+		}
+		runners = runners[:0]
+		for _, svc := range services {
+			runners = append(runners, &DockerRunner{cfg: l.cfg.ForService(svc.Name), context: svc.Context, dockerfile: svc.Dockerfile})
+		}
+	}
+	for _, runner := range runners {
+		image, err := runner.cfg.ImageTag()
+		if err != nil {
+			return err
+		}
+		if err := runner.build(ctx, image); err != nil {
+			return fmt.Errorf("docker build failed: %w", err)
+		}
+		if err := l.load(ctx, image); err != nil {
+			return fmt.Errorf("failed to load %s into the %s cluster: %w", image, l.cluster, err)
+		}
+	}
+	return nil
+}
+
+// load loads a locally built image into the local cluster. Docker Desktop
+// runs its cluster on the Docker daemon's image store, so there is nothing
+// to load.
+func (l *devLoop) load(ctx context.Context, image string) error {
+	var args []string
+	switch l.cluster {
+	case "kind":
+		args = []string{"kind", "load", "docker-image", image, "--name", l.clusterName}
+	case "minikube":
+		args = []string{"minikube", "image", "load", image}
+	case "k3d":
+		args = []string{"k3d", "image", "import", image, "--cluster", l.clusterName}
+	default:
+		return nil
+	}
+
+	if l.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: %s", strings.Join(args, " "))
+		return nil
+	}
+	log.Infof("📦 Loading %s into the %s cluster %s", image, l.cluster, l.clusterName)
+	cmd := command(ctx, args[0], args[1:]...)
+	cmd.Stdout = l.cfg.toolOutput()
+	cmd.Stderr = os.Stderr
+	defer flushOutput(cmd.Stdout)
+	return cmd.Run()
+}
+
+// watchDir watches dir and its subdirectories, except for the ignored ones.
+func (l *devLoop) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != "." && l.ignored(path) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// ignored reports whether a change to path is not a change of the sources:
+// git's own files, editor swap and backup files, the files dockwright writes
+// itself, and what .dockerignore excludes from the build context.
+func (l *devLoop) ignored(path string) bool {
+	base := filepath.Base(path)
+	switch {
+	case path == ".git" || strings.HasPrefix(path, ".git"+string(filepath.Separator)):
+		return true
+	case strings.HasSuffix(base, "~"), strings.HasSuffix(base, ".swp"), strings.HasSuffix(base, ".swx"),
+		strings.HasPrefix(base, ".#"), strings.HasPrefix(base, "#") && strings.HasSuffix(base, "#"), base == "4913":
+		return true
+	}
+	for _, file := range l.cfg.stateFiles() {
+		if filepath.Clean(file) == path {
+			return true
+		}
+	}
+	return matchesDockerignore(l.ignore, path)
+}
+
+// needsRebuild reports whether a change to path changes the image. Changes
+// to dockwright's configuration, the values files and the chart only need a
+// helm upgrade.
+func (l *devLoop) needsRebuild(path string) bool {
+	for _, dir := range []string{".dockwright", l.cfg.HelmChartPath} {
+		dir = filepath.Clean(dir)
+		if dir != "." && (path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))) {
+			return false
+		}
+	}
+	return true
+}
+
+// dockerignorePatterns reads the patterns of .dockerignore. Negated
+// patterns are not supported and skipped.
+func dockerignorePatterns() []string {
+	f, err := os.Open(".dockerignore")
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		patterns = append(patterns, filepath.Clean(strings.TrimPrefix(line, "/")))
+	}
+	return patterns
+}
+
+// matchesDockerignore reports whether path or one of its parent directories
+// matches one of the patterns.
+func matchesDockerignore(patterns []string, path string) bool {
+	for p := path; p != "." && p != string(filepath.Separator); p = filepath.Dir(p) {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, p); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
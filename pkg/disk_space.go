@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// CheckDiskSpace warns when the filesystem of the build context, or of the
+// Docker daemon's storage, has less than docker.minFreeSpaceMB free, as a
+// build running out of space only fails late with "no space left on device".
+func (c *Config) CheckDiskSpace(ctx context.Context) error {
+	if c.DockerMinFreeSpaceMB <= 0 || !c.ShouldRunDockerBuild() {
+		return nil
+	}
+
+	locations := []struct{ name, path string }{{"the build context", "."}}
+	if c.DockerBuilder == BuilderDocker {
+		if root, err := dockerRootDir(ctx); err == nil {
+			locations = append(locations, struct{ name, path string }{"Docker's storage", root})
+		} else {
+			log.Debugf("Could not locate Docker's storage: %v", err)
+		}
+	}
+
+	minFree := int64(c.DockerMinFreeSpaceMB) * 1000 * 1000
+	var low []string
+	for _, location := range locations {
+		free, err := freeDiskSpace(location.path)
+		if err != nil {
+			log.Debugf("Could not determine the free disk space of %s: %v", location.path, err)
+			continue
+		}
+		log.Debugf("%s free on the filesystem of %s (%s)", formatMB(free), location.name, location.path)
+		if free < minFree {
+			low = append(low, fmt.Sprintf("%s (%s) has only %s free", location.name, location.path, formatMB(free)))
+		}
+	}
+	if len(low) == 0 {
+		return nil
+	}
+
+	hint := "Please free up disk space before building"
+	if c.DockerBuilder == BuilderDocker {
+		if reclaimable := dockerReclaimable(ctx); reclaimable != "" {
+			hint = fmt.Sprintf("Please free up disk space before building, e.g. with docker system prune, which can reclaim %s", reclaimable)
+		}
+	}
+	return warningf("the filesystem of %s, less than docker.minFreeSpaceMB (%dMB). %s", strings.Join(low, " and "), c.DockerMinFreeSpaceMB, hint)
+}
+
+// dockerRootDir returns the directory the Docker daemon stores images and
+// build cache in, if the daemon runs on this machine.
+func dockerRootDir(ctx context.Context) (string, error) {
+	if host := os.Getenv("DOCKER_HOST"); host != "" && !strings.HasPrefix(host, "unix://") {
+		return "", fmt.Errorf("the docker daemon at %s is remote", host)
+	}
+	out, err := command(ctx, "docker", "info", "--format", "{{.DockerRootDir}}").Output()
+	if err != nil {
+		return "", err
+	}
+	root := strings.TrimSpace(string(out))
+	// Docker Desktop's daemon runs in a VM, where the directory doesn't exist locally
+	if _, err := os.Stat(root); err != nil {
+		return "", err
+	}
+	return root, nil
+}
+
+// dockerReclaimable returns the space docker system df reports as
+// reclaimable from images and the build cache, such as "2.1GB of images and
+// 3.4GB of build cache".
+func dockerReclaimable(ctx context.Context) string {
+	out, err := command(ctx, "docker", "system", "df", "--format", "{{.Type}}\t{{.Reclaimable}}").Output()
+	if err != nil {
+		return ""
+	}
+	var parts []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		kind, reclaimable, ok := strings.Cut(line, "\t")
+		if !ok || (kind != "Images" && kind != "Build Cache") {
+			continue
+		}
+		size, _, _ := strings.Cut(reclaimable, " ")
+		if size != "" && size != "0B" {
+			parts = append(parts, fmt.Sprintf("%s of %s", size, strings.ToLower(kind)))
+		}
+	}
+	return strings.Join(parts, " and ")
+}
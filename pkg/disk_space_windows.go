@@ -0,0 +1,10 @@
+//go:build windows
+
+package pkg
+
+import "errors"
+
+// freeDiskSpace is not implemented on Windows, where the check is skipped.
+func freeDiskSpace(path string) (int64, error) {
+	return 0, errors.New("not supported on Windows")
+}
@@ -1,60 +1,503 @@
 package pkg
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/charmbracelet/log"
 )
 
 // DockerRunner handles Docker build, login, and push operations.
 type DockerRunner struct {
-	cfg *Config
+	cfg        *Config
+	context    string
+	dockerfile string // empty means <context>/Dockerfile
 }
 
 // NewDockerRunner creates a new DockerRunner with the given configuration.
 func NewDockerRunner(cfg *Config) *DockerRunner {
-	return &DockerRunner{cfg: cfg}
+	return &DockerRunner{cfg: cfg, context: "."}
 }
 
 // Run executes the Docker workflow: build, login, and push.
-func (d *DockerRunner) Run() error {
+func (d *DockerRunner) Run(ctx context.Context) error {
 	if !d.cfg.ShouldRunDockerBuild() {
 		log.Info("⏭️  Skipping Docker workflow. Either docker build (--docker-build) flag is disabled or Dockerfile is missing.")
 		return nil
 	}
 
+	ctx, cancel := d.withBuildTimeout(ctx)
+	defer cancel()
+
+	var err error
+	if d.cfg.DockerCompose {
+		err = d.runCompose(ctx)
+	} else {
+		err = d.runImage(ctx)
+	}
+	return d.cancellationError(ctx, err)
+}
+
+// withBuildTimeout bounds ctx by the configured build timeout, if any.
+func (d *DockerRunner) withBuildTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, d.cfg.DockerBuildTimeout, "the docker workflow", "docker.buildTimeout")
+}
+
+// cancellationError explains err when it was caused by the timeout or an interrupt,
+// rather than surfacing the bare exit status of the killed command.
+func (d *DockerRunner) cancellationError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%v: %w", context.Cause(ctx), err)
+	case context.Canceled:
+		return fmt.Errorf("cancelled: %w", err)
+	}
+	return err
+}
+
+// runCompose runs the image workflow once per buildable compose service.
+func (d *DockerRunner) runCompose(ctx context.Context) error {
+	services, err := d.cfg.ComposeServices()
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range services {
+		log.Infof("🐙 Compose service: %s", svc.Name)
+		runner := &DockerRunner{cfg: d.cfg.ForService(svc.Name), context: svc.Context, dockerfile: svc.Dockerfile}
+		if err := runner.runImage(ctx); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// runImage builds and publishes the image of a single artifact.
+func (d *DockerRunner) runImage(ctx context.Context) error {
 	imageTag, err := d.cfg.ImageTag()
 	if err != nil {
 		return err
 	}
 
-	if err := d.build(imageTag); err != nil {
+	if d.cfg.IsMultiPlatform() {
+		return d.runMultiPlatform(ctx, imageTag)
+	}
+
+	if d.cfg.DockerBuilder == BuilderKaniko {
+		return d.runKaniko(ctx, imageTag)
+	}
+
+	// Attestations cannot be kept in the local image store, so buildx pushes directly
+	if d.cfg.DockerProvenance != "" {
+		return d.runAttested(ctx, imageTag)
+	}
+
+	if err := d.build(ctx, imageTag); err != nil {
 		return fmt.Errorf("docker build failed: %w", err)
 	}
 
-	if err := d.login(); err != nil {
+	if err := d.checkSize(ctx, imageTag); err != nil {
+		return err
+	}
+
+	if err := d.login(ctx); err != nil {
 		return fmt.Errorf("docker login failed: %w", err)
 	}
 
-	if err := d.push(imageTag); err != nil {
+	if err := d.push(ctx, imageTag); err != nil {
 		return fmt.Errorf("docker push failed: %w", err)
 	}
 
 	return nil
 }
 
-func (d *DockerRunner) build(imageTag string) error {
+// tool returns the CLI used for build, login and push. buildah mirrors the
+// docker CLI for these commands, so both share the same code path.
+func (d *DockerRunner) tool() string {
+	if d.cfg.DockerBuilder == BuilderBuildah {
+		return "buildah"
+	}
+	return "docker"
+}
+
+// progressArgs returns the flags printing every build step in full with
+// --debug, instead of the collapsing progress display.
+func (d *DockerRunner) progressArgs() []string {
+	if d.cfg.Debug && d.tool() == "docker" {
+		return []string{"--progress=plain"}
+	}
+	return nil
+}
+
+// tlsArgs returns the flags disabling TLS verification for insecure registries.
+// The docker CLI has no such flag; docker relies on the daemon's insecure-registries.
+func (d *DockerRunner) tlsArgs() []string {
+	if d.cfg.DockerInsecure && d.tool() == "buildah" {
+		return []string{"--tls-verify=false"}
+	}
+	return nil
+}
+
+// buildEnv returns the environment for the build command, pointing buildah at a
+// registries.conf that routes Docker Hub pulls through the configured mirror.
+// The returned cleanup function must always be called.
+func (d *DockerRunner) buildEnv() ([]string, func(), error) {
+	noop := func() {}
+	if d.cfg.DockerMirror == "" || d.tool() != "buildah" {
+		return nil, noop, nil
+	}
+
+	file, err := os.CreateTemp("", "dockwright-registries-*.conf")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create registries.conf: %w", err)
+	}
+	cleanup := func() { os.Remove(file.Name()) }
+
+	mirror := registryHost(d.cfg.DockerMirror)
+	conf := fmt.Sprintf("[[registry]]\nprefix = \"docker.io\"\nlocation = \"docker.io\"\n\n[[registry.mirror]]\nlocation = %q\ninsecure = %t\n", mirror, d.cfg.DockerInsecure)
+	if _, err := file.WriteString(conf); err != nil {
+		file.Close()
+		cleanup()
+		return nil, noop, fmt.Errorf("failed to write registries.conf: %w", err)
+	}
+	file.Close()
+
+	return append(os.Environ(), "CONTAINERS_REGISTRIES_CONF="+file.Name()), cleanup, nil
+}
+
+// pullArgs returns the flags forcing base images to be refreshed during build.
+func (d *DockerRunner) pullArgs() []string {
+	if !d.cfg.DockerAlwaysPull {
+		return nil
+	}
+	if d.tool() == "buildah" {
+		return []string{"--pull=always"}
+	}
+	return []string{"--pull"}
+}
+
+// dockerfilePath returns the path of the Dockerfile used for the build.
+func (d *DockerRunner) dockerfilePath() string {
+	if d.dockerfile != "" {
+		return d.dockerfile
+	}
+	return filepath.Join(d.context, "Dockerfile")
+}
+
+// logBaseImages logs the digests the base images of the Dockerfile resolved to,
+// so that it is visible which patch level of a base image went into the build.
+// The digests are read from the provenance in the build's metadata file: with
+// BuildKit, the local image store holds no base image, or a stale one when
+// --pull fetched a newer one.
+func (d *DockerRunner) logBaseImages(metadataFile string) {
+	bases, err := dockerfileBaseImages(d.dockerfilePath())
+	if err != nil {
+		log.Warnf("⚠️  Could not determine base images: %v", err)
+		return
+	}
+	if len(bases) == 0 {
+		return
+	}
+
+	var digests map[string]string
+	if content, err := os.ReadFile(metadataFile); err == nil {
+		digests = provenanceDigests(content)
+	}
+	for _, base := range bases {
+		digest := "unknown"
+		if _, pinned, ok := strings.Cut(base, "@"); ok {
+			digest = pinned
+		} else if resolved, ok := digests[familiarImageRef(base)]; ok {
+			digest = resolved
+		}
+		log.Infof("   Base image: %s → %s", base, digest)
+	}
+}
+
+// provenanceDigests returns the digests of the images in the materials of the
+// SLSA provenance BuildKit writes to the metadata file, keyed by their
+// familiar name:tag reference.
+func provenanceDigests(metadata []byte) map[string]string {
+	type material struct {
+		URI    string            `json:"uri"`
+		Digest map[string]string `json:"digest"`
+	}
+	var content struct {
+		Provenance struct {
+			Materials       []material `json:"materials"` // SLSA v0.2
+			BuildDefinition struct {
+				ResolvedDependencies []material `json:"resolvedDependencies"` // SLSA v1
+			} `json:"buildDefinition"`
+		} `json:"buildx.build.provenance"`
+	}
+	if err := json.Unmarshal(metadata, &content); err != nil {
+		return nil
+	}
+
+	digests := make(map[string]string)
+	materials := append(content.Provenance.Materials, content.Provenance.BuildDefinition.ResolvedDependencies...)
+	for _, m := range materials {
+		// pkg:docker/<name>@<tag>?platform=...
+		ref, ok := strings.CutPrefix(m.URI, "pkg:docker/")
+		if !ok || m.Digest["sha256"] == "" {
+			continue
+		}
+		ref, _, _ = strings.Cut(ref, "?")
+		name, tag, ok := strings.Cut(ref, "@")
+		if !ok {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		digests[name+":"+tag] = "sha256:" + m.Digest["sha256"]
+	}
+	return digests
+}
+
+// familiarImageRef returns the name:tag reference of an image as Docker
+// displays it, without the Docker Hub registry and library namespace.
+func familiarImageRef(ref string) string {
+	repo := imageRefRepository(ref)
+	tag := strings.TrimPrefix(ref[len(repo):], ":")
+	if tag == "" {
+		tag = "latest"
+	}
+	repo = strings.TrimPrefix(strings.TrimPrefix(repo, "docker.io/"), "library/")
+	return repo + ":" + tag
+}
+
+// dockerfileBaseImages returns the external images referenced by FROM instructions,
+// skipping scratch, earlier build stages and images parameterised with build args.
+func dockerfileBaseImages(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	stages := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+
+		var image string
+		for i := 1; i < len(fields); i++ {
+			if strings.HasPrefix(fields[i], "--") {
+				continue
+			}
+			image = fields[i]
+			if i+2 < len(fields) && strings.EqualFold(fields[i+1], "AS") {
+				stages[strings.ToLower(fields[i+2])] = true
+			}
+			break
+		}
+
+		if image == "" || image == "scratch" || strings.Contains(image, "$") || stages[strings.ToLower(image)] {
+			continue
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// dockerfileArgs returns the -f argument when a non-default Dockerfile is used.
+func (d *DockerRunner) dockerfileArgs() []string {
+	if d.dockerfile == "" {
+		return nil
+	}
+	return []string{"-f", d.dockerfile}
+}
+
+// checkSize reports the size and layer breakdown of the built image and enforces
+// the configured size budget.
+func (d *DockerRunner) checkSize(ctx context.Context, imageTag string) error {
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would report image size of %s", imageTag)
+		return nil
+	}
+
+	if d.tool() != "docker" {
+		if d.cfg.DockerMaxSizeMB > 0 {
+			log.Warnf("⚠️  Image size budget is only enforced with the docker builder, skipping check for %s", imageTag)
+		}
+		return nil
+	}
+
+	out, err := command(ctx, "docker", "image", "inspect", "--format", "{{.Size}}", imageTag).Output()
+	if err != nil {
+		return fmt.Errorf("failed to inspect image %s: %w", imageTag, err)
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse size of image %s: %w", imageTag, err)
+	}
+
+	log.Infof("📦 Image size: %s", formatMB(size))
+
+	out, err = command(ctx, "docker", "history", "--human=false", "--format", "{{.Size}}\t{{.CreatedBy}}", imageTag).Output()
+	if err != nil {
+		return fmt.Errorf("failed to read layers of image %s: %w", imageTag, err)
+	}
+	log.Info("   Layers (non-empty, newest first):")
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		layerSize, createdBy, _ := strings.Cut(line, "\t")
+		layerBytes, err := strconv.ParseInt(layerSize, 10, 64)
+		if err != nil || layerBytes == 0 {
+			continue
+		}
+		if len(createdBy) > 80 {
+			createdBy = createdBy[:77] + "..."
+		}
+		log.Infof("     %10s  %s", formatMB(layerBytes), createdBy)
+	}
+
+	if d.cfg.DockerMaxSizeMB > 0 && size > int64(d.cfg.DockerMaxSizeMB)*1000*1000 {
+		return fmt.Errorf("image %s is %s, which exceeds the configured budget of %dMB (docker.maxSizeMB)", imageTag, formatMB(size), d.cfg.DockerMaxSizeMB)
+	}
+	if d.cfg.DockerMaxSizeMB > 0 {
+		log.Infof("✓  Image size is within the budget of %dMB", d.cfg.DockerMaxSizeMB)
+	}
+	return nil
+}
+
+// formatMB formats a byte count in megabytes, matching the units docker reports.
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%.1fMB", float64(bytes)/1000/1000)
+}
+
+// runAttested builds and pushes the image in one buildx invocation together with
+// its provenance attestation.
+func (d *DockerRunner) runAttested(ctx context.Context, imageTag string) error {
+	if err := d.login(ctx); err != nil {
+		return fmt.Errorf("docker login failed: %w", err)
+	}
+
+	var platform string
+	if len(d.cfg.DockerPlatforms) == 1 {
+		platform = d.cfg.DockerPlatforms[0]
+	}
+	if err := d.buildxPush(ctx, platform, imageTag); err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+
+	if d.cfg.DockerMaxSizeMB > 0 {
+		log.Warnf("⚠️  Image size budget is not enforced for attested builds, skipping check for %s", imageTag)
+	}
+	return nil
+}
+
+// provenanceArgs returns the buildx flags attaching a provenance attestation and
+// annotating the image with the source commit and configuration digest.
+func (d *DockerRunner) provenanceArgs() []string {
+	if d.cfg.DockerProvenance == "" {
+		return nil
+	}
+
+	attrs := "mode=" + d.cfg.DockerProvenance
+	if d.cfg.DockerBuilderID != "" {
+		attrs += ",builder-id=" + d.cfg.DockerBuilderID
+	}
+	args := []string{"--provenance", attrs}
+
+	if commit, err := gitCommit(); err == nil {
+		args = append(args, "--annotation", "org.opencontainers.image.revision="+commit)
+	} else {
+		log.Warnf("⚠️  Source commit not recorded in provenance: %v", err)
+	}
+	if digest, err := d.cfg.Digest(); err == nil {
+		args = append(args, "--annotation", "dev.dockwright.config.digest="+digest)
+	} else {
+		log.Warnf("⚠️  Configuration digest not recorded in provenance: %v", err)
+	}
+
+	return args
+}
+
+// runMultiPlatform builds every configured platform concurrently, pushing each
+// platform image under its own tag, then publishes a manifest list under imageTag.
+func (d *DockerRunner) runMultiPlatform(ctx context.Context, imageTag string) error {
+	if err := d.login(ctx); err != nil {
+		return fmt.Errorf("docker login failed: %w", err)
+	}
+
+	platformTags, err := d.buildPlatforms(ctx)
+	if err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+
+	if err := d.pushManifest(ctx, imageTag, platformTags); err != nil {
+		return fmt.Errorf("docker manifest push failed: %w", err)
+	}
+
+	return nil
+}
+
+func (d *DockerRunner) build(ctx context.Context, imageTag string) error {
 	log.Infof("🔨 Building Docker image: %s", imageTag)
-	log.Infof("   Build context: %s", ".")
+	log.Infof("   Build context: %s", d.context)
+	if d.dockerfile != "" {
+		log.Infof("   Dockerfile: %s", d.dockerfile)
+	}
+
+	args := []string{"build", "-t", imageTag, "--label", artifactLabel + "=" + d.cfg.ArtifactName}
+	args = append(args, d.tlsArgs()...)
+	args = append(args, d.pullArgs()...)
+	args = append(args, d.dockerfileArgs()...)
+	args = append(args, d.progressArgs()...)
+	if len(d.cfg.DockerPlatforms) == 1 {
+		args = append(args, "--platform", d.cfg.DockerPlatforms[0])
+		log.Infof("   Platform: %s", d.cfg.DockerPlatforms[0])
+	}
 
 	if d.cfg.DryRun {
-		log.Infof("   🧪 [DRY-RUN] Would run: docker build -t %s .", imageTag)
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", d.tool(), strings.Join(append(args, d.context), " "))
 		return nil
 	}
 
-	cmd := exec.Command("docker", "build", "-t", imageTag, ".")
+	env, cleanup, err := d.buildEnv()
+	defer cleanup()
+	if err != nil {
+		return err
+	}
+
+	// BuildKit records the digests the base images resolved to in the
+	// provenance of the metadata file
+	var metadataFile string
+	if d.tool() == "docker" && os.Getenv("DOCKER_BUILDKIT") != "0" {
+		file, err := os.CreateTemp("", "dockwright-metadata-*.json")
+		if err != nil {
+			return fmt.Errorf("failed to create the build metadata file: %w", err)
+		}
+		file.Close()
+		metadataFile = file.Name()
+		defer os.Remove(metadataFile)
+
+		args = append(args, "--metadata-file", metadataFile)
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(env, "BUILDX_METADATA_PROVENANCE=min")
+	}
+	args = append(args, d.context)
+
+	cmd := command(ctx, d.tool(), args...)
+	cmd.Env = env
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
@@ -63,65 +506,337 @@ func (d *DockerRunner) build(imageTag string) error {
 	}
 
 	log.Infof("✓  Successfully built Docker image: %s", imageTag)
+	if metadataFile != "" {
+		d.logBaseImages(metadataFile)
+	}
 	return nil
 }
 
-func (d *DockerRunner) login() error {
-	username := os.Getenv("REGISTRY_USERNAME")
-	password := os.Getenv("REGISTRY_PASSWORD")
+func (d *DockerRunner) login(ctx context.Context) error {
+	return d.loginTo(ctx, d.cfg.DockerHost)
+}
 
-	if username == "" || password == "" {
-		return fmt.Errorf("REGISTRY_USERNAME and REGISTRY_PASSWORD environment variables must be set for Docker login")
+// loginTo authenticates against the given registry host with the registry credentials.
+func (d *DockerRunner) loginTo(ctx context.Context, host string) error {
+	username, password, err := registryCredentials()
+	if err != nil {
+		return err
 	}
 
-	log.Infof("🔐 Authenticating with Docker registry: %s", d.cfg.DockerHost)
+	log.Infof("🔐 Authenticating with Docker registry: %s", host)
 	log.Infof("   Username: %s", username)
 
+	args := append([]string{"login"}, d.tlsArgs()...)
+	args = append(args, host, "-u", username, "--password-stdin")
+
 	if d.cfg.DryRun {
-		log.Infof("   🧪 [DRY-RUN] Would run: docker login %s -u %s", d.cfg.DockerHost, username)
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", d.tool(), strings.Join(args, " "))
 		return nil
 	}
 
-	cmd := exec.Command("docker", "login", d.cfg.DockerHost, "-u", username, "--password-stdin")
-	stdin, err := cmd.StdinPipe()
+	err = d.cfg.retry(ctx, "login", func() error {
+		cmd := command(ctx, d.tool(), args...)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return fmt.Errorf("failed to create stdin pipe: %w", err)
+		}
+
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start docker login: %w", err)
+		}
+
+		if _, err := fmt.Fprintln(stdin, password); err != nil {
+			return fmt.Errorf("failed to write password: %w", err)
+		}
+		stdin.Close()
+
+		return outputError(cmd.Wait(), &stderr)
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
+		return fmt.Errorf("docker login failed: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("failed to start docker login: %w", err)
+	log.Infof("✓  Successfully authenticated with registry: %s", host)
+	return nil
+}
+
+func (d *DockerRunner) push(ctx context.Context, imageTag string) error {
+	log.Infof("📤 Pushing Docker image: %s", imageTag)
+	log.Infof("   Target registry: %s", d.cfg.DockerHost)
+
+	args := append([]string{"push"}, d.tlsArgs()...)
+	if !d.cfg.Debug {
+		args = append(args, "--quiet") // hides the per-layer progress
 	}
+	args = append(args, imageTag)
 
-	if _, err := fmt.Fprintln(stdin, password); err != nil {
-		return fmt.Errorf("failed to write password: %w", err)
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", d.tool(), strings.Join(args, " "))
+		return nil
 	}
-	stdin.Close()
 
-	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("docker login failed: %w", err)
+	err := d.cfg.retry(ctx, "push", func() error {
+		var stderr bytes.Buffer
+		cmd := command(ctx, d.tool(), args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		return outputError(cmd.Run(), &stderr)
+	})
+	if err != nil {
+		return err
 	}
 
-	log.Infof("✓  Successfully authenticated with registry: %s", d.cfg.DockerHost)
+	log.Infof("✓  Successfully pushed image to registry: %s", imageTag)
 	return nil
 }
 
-func (d *DockerRunner) push(imageTag string) error {
-	log.Infof("📤 Pushing Docker image: %s", imageTag)
-	log.Infof("   Target registry: %s", d.cfg.DockerHost)
+// registryHost strips the scheme and trailing slash from a registry URL.
+func registryHost(url string) string {
+	url = strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://")
+	return strings.TrimSuffix(url, "/")
+}
+
+// registryCredentials returns the registry username and password from the environment.
+func registryCredentials() (string, string, error) {
+	username := os.Getenv("REGISTRY_USERNAME")
+	password := os.Getenv("REGISTRY_PASSWORD")
+
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("REGISTRY_USERNAME and REGISTRY_PASSWORD environment variables must be set for Docker login")
+	}
+	return username, password, nil
+}
+
+// artifactLabel labels the images built locally with the artifact's name, so
+// that prune finds them once a rebuild has taken their tag.
+const artifactLabel = "dev.dockwright.artifact"
+
+// LocalImage describes an image of the artifact present in the local Docker
+// store, by one of its tags, or by its ID if it has none left.
+type LocalImage struct {
+	ID         string
+	Repository string
+	Tag        string
+	CreatedAt  string
+}
+
+// ref returns the reference removing the image: its tag, which leaves other
+// tags of the same image alone, or its ID if it is dangling.
+func (img LocalImage) ref() string {
+	if img.Tag == "<none>" || img.Tag == "" {
+		return img.ID
+	}
+	return img.Repository + ":" + img.Tag
+}
+
+// StaleImages returns the local images of the artifact except for the keep
+// most recent tagged ones: the older tags of the artifact repository, and
+// the dangling images that rebuilds of a tag left behind.
+func (d *DockerRunner) StaleImages(ctx context.Context, keep int) ([]LocalImage, error) {
+	repo, err := d.cfg.ImageRepository()
+	if err != nil {
+		return nil, err
+	}
+
+	// docker lists images newest first
+	tagged, err := d.localImages(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var images []LocalImage
+	kept := make(map[string]bool)
+	for _, img := range tagged {
+		if !kept[img.ID] && len(kept) < keep {
+			kept[img.ID] = true
+		}
+		if !kept[img.ID] {
+			images = append(images, img)
+		}
+	}
+
+	dangling, err := d.localImages(ctx, "--filter", "dangling=true", "--filter", "label="+artifactLabel+"="+d.cfg.ArtifactName)
+	if err != nil {
+		return nil, err
+	}
+	return append(images, dangling...), nil
+}
+
+// localImages lists the local images docker images selects with args.
+func (d *DockerRunner) localImages(ctx context.Context, args ...string) ([]LocalImage, error) {
+	args = append([]string{"images"}, args...)
+	out, err := command(ctx, "docker", append(args, "--format", "{{.ID}}\t{{.Repository}}\t{{.Tag}}\t{{.CreatedAt}}")...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var images []LocalImage
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		parts := strings.SplitN(line, "\t", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		images = append(images, LocalImage{ID: parts[0], Repository: parts[1], Tag: parts[2], CreatedAt: parts[3]})
+	}
+	return images, nil
+}
+
+// RemoveImages removes the given images from the local Docker store by
+// reference. Images still used by a container are left in place.
+func (d *DockerRunner) RemoveImages(ctx context.Context, images []LocalImage) error {
+	var failed int
+	for _, img := range images {
+		if d.cfg.DryRun {
+			log.Infof("   🧪 [DRY-RUN] Would run: docker rmi %s", img.ref())
+			continue
+		}
+
+		var stderr bytes.Buffer
+		cmd := command(ctx, "docker", "rmi", img.ref())
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			log.Warnf("⚠️  Failed to remove image %s: %s", img.ref(), strings.TrimSpace(stderr.String()))
+			failed++
+			continue
+		}
+		log.Infof("✓  Removed image %s", img.ref())
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d image(s) could not be removed", failed, len(images))
+	}
+	return nil
+}
+
+// buildPlatforms builds and pushes one image per platform in parallel and
+// returns the pushed per-platform tags in the configured platform order.
+func (d *DockerRunner) buildPlatforms(ctx context.Context) ([]string, error) {
+	tags := make([]string, len(d.cfg.DockerPlatforms))
+	errs := make([]error, len(d.cfg.DockerPlatforms))
+
+	var wg sync.WaitGroup
+	for i, platform := range d.cfg.DockerPlatforms {
+		tag, err := d.cfg.PlatformImageTag(platform)
+		if err != nil {
+			return nil, err
+		}
+		tags[i] = tag
+
+		wg.Add(1)
+		go func(i int, platform, tag string) {
+			defer wg.Done()
+			if err := d.buildxPush(ctx, platform, tag); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", platform, err)
+			}
+		}(i, platform, tag)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// buildxPush builds the image with buildx and pushes it straight to the registry.
+// An empty platform builds for the builder's default platform.
+func (d *DockerRunner) buildxPush(ctx context.Context, platform, tag string) error {
+	args := []string{"buildx", "build"}
+	if builder, ok := d.cfg.DockerPlatformBuilders[platform]; ok {
+		args = append(args, "--builder", builder)
+	}
+	args = append(args, d.pullArgs()...)
+	args = append(args, d.dockerfileArgs()...)
+	args = append(args, d.provenanceArgs()...)
+	args = append(args, d.progressArgs()...)
+	if platform != "" {
+		args = append(args, "--platform", platform)
+	}
+	args = append(args, "-t", tag, "--push", d.context)
+
+	label := platform
+	if label == "" {
+		label = "default platform"
+	}
+	log.Infof("🔨 Building Docker image for %s: %s", label, tag)
 
 	if d.cfg.DryRun {
-		log.Infof("   🧪 [DRY-RUN] Would run: docker push %s", imageTag)
+		log.Infof("   🧪 [DRY-RUN] Would run: docker %s", strings.Join(args, " "))
 		return nil
 	}
 
-	cmd := exec.Command("docker", "push", imageTag)
+	cmd := command(ctx, "docker", args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if platform != "" {
+		prefix := fmt.Sprintf("[%s] ", platform)
+		cmd.Stdout = newPrefixWriter(os.Stdout, prefix)
+		cmd.Stderr = newPrefixWriter(os.Stderr, prefix)
+	}
 
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 
-	log.Infof("✓  Successfully pushed image to registry: %s", imageTag)
+	log.Infof("✓  Successfully built and pushed %s image: %s", label, tag)
 	return nil
 }
+
+func (d *DockerRunner) pushManifest(ctx context.Context, imageTag string, platformTags []string) error {
+	log.Infof("📤 Publishing manifest list: %s", imageTag)
+	for _, tag := range platformTags {
+		log.Infof("   Platform image: %s", tag)
+	}
+
+	args := append([]string{"buildx", "imagetools", "create", "-t", imageTag}, platformTags...)
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: docker %s", strings.Join(args, " "))
+		return nil
+	}
+
+	err := d.cfg.retry(ctx, "push", func() error {
+		var stderr bytes.Buffer
+		cmd := command(ctx, "docker", args...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+		return outputError(cmd.Run(), &stderr)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("✓  Successfully published manifest list: %s", imageTag)
+	return nil
+}
+
+// prefixWriter prefixes every complete line written to it, so that output of
+// concurrently running commands stays attributable.
+type prefixWriter struct {
+	mu     sync.Mutex
+	out    io.Writer
+	prefix string
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(out io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{out: out, prefix: prefix}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf.Write(p)
+	for {
+		line, err := w.buf.ReadBytes('\n')
+		if err != nil {
+			// Keep the incomplete line until the rest of it arrives.
+			w.buf.Write(line)
+			break
+		}
+		if _, err := fmt.Fprintf(w.out, "%s%s", w.prefix, line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,318 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentConfig holds the settings that differ per deployment environment,
+// read from the environments section of .dockwright/config.yaml:
+//
+//	environments:
+//	  production:
+//	    kubernetes:
+//	      context: eks-prod
+//	      namespace: my-service
+//	    helmFlags: ["--atomic", "--timeout", "10m"]
+//	    dryRun: true
+type EnvironmentConfig struct {
+	Kubernetes struct {
+		Config               string            `yaml:"config"`
+		Context              string            `yaml:"context"`
+		Namespace            string            `yaml:"namespace"`
+		NamespaceLabels      map[string]string `yaml:"namespaceLabels"`
+		NamespaceAnnotations map[string]string `yaml:"namespaceAnnotations"`
+	} `yaml:"kubernetes"`
+	HelmFlags []string `yaml:"helmFlags"`
+	DryRun    bool     `yaml:"dryRun"`
+
+	helm helmFlagSettings // HelmFlags, parsed when the configuration is loaded
+}
+
+// loadEnvironments reads the environments section of the config file.
+func loadEnvironments() (map[string]EnvironmentConfig, error) {
+	raw, ok := rawConfigValue("environments")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	// environments.allowed and environments.protected list environments, they
+	// aren't ones themselves
+	if m, ok := raw.(map[string]interface{}); ok {
+		m = maps.Clone(m)
+		for key := range m {
+			if strings.EqualFold(key, "allowed") || strings.EqualFold(key, "protected") {
+				delete(m, key)
+			}
+		}
+		raw = m
+	}
+
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var environments map[string]EnvironmentConfig
+	if err := yaml.Unmarshal(content, &environments); err != nil {
+		return nil, fmt.Errorf("invalid environments section: %w", err)
+	}
+	for name, env := range environments {
+		if env.helm, err = parseHelmFlags(env.HelmFlags); err != nil {
+			return nil, fmt.Errorf("invalid environments.%s.helmFlags: %w", name, err)
+		}
+		environments[name] = env
+	}
+	return environments, nil
+}
+
+// validateEnvNames refuses environments outside environments.allowed, so a
+// typo such as prodcution fails instead of deploying with the values files
+// of an environment that doesn't exist.
+func (c *Config) validateEnvNames() error {
+	if len(c.EnvironmentsAllowed) == 0 {
+		return nil
+	}
+	for _, env := range c.Env {
+		if slices.Contains(c.EnvironmentsAllowed, env) {
+			continue
+		}
+		hint := ""
+		if closest := closestName(env, c.EnvironmentsAllowed); closest != "" {
+			hint = fmt.Sprintf(". Did you mean '%s'?", closest)
+		}
+		return fmt.Errorf("unknown environment '%s', environments.allowed lists %s%s", env, strings.Join(c.EnvironmentsAllowed, ", "), hint)
+	}
+	return nil
+}
+
+// closestName returns the name a typo most likely meant: the one at the
+// smallest edit distance, if it is close enough.
+func closestName(typo string, names []string) string {
+	closest, best := "", max(2, len(typo)/3)+1
+	for _, name := range names {
+		if d := editDistance(typo, name); d < best {
+			closest, best = name, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// helmFlagSettings are the settings given by an environment's helm flags.
+// Those the flags don't give are nil.
+type helmFlagSettings struct {
+	atomic, cleanupOnFail, wait   *bool
+	createNamespace, updateDeps   *bool
+	timeout                       *time.Duration
+	chartVersion, postRenderer    *string
+	postRendererArgs, valuesFiles []string
+	set, setString, setFile       map[string]string
+}
+
+// parseHelmFlags parses the helm flags of an environment. Only the flags that
+// map to a Helm setting of the configuration are supported. Like the CLI
+// flags, each --set is kept whole, so helm parses lists such as a={x,y}.
+func parseHelmFlags(args []string) (helmFlagSettings, error) {
+	var settings helmFlagSettings
+	if len(args) == 0 {
+		return settings, nil
+	}
+
+	flags := pflag.NewFlagSet("helmFlags", pflag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	atomic := flags.Bool("atomic", false, "")
+	cleanupOnFail := flags.Bool("cleanup-on-fail", false, "")
+	wait := flags.Bool("wait", false, "")
+	timeout := flags.Duration("timeout", 0, "")
+	createNamespace := flags.Bool("create-namespace", false, "")
+	updateDeps := flags.Bool("dependency-update", false, "")
+	chartVersion := flags.String("version", "", "")
+	postRenderer := flags.String("post-renderer", "", "")
+	postRendererArgs := flags.StringArray("post-renderer-args", nil, "")
+	set := flags.StringArray("set", nil, "")
+	setString := flags.StringArray("set-string", nil, "")
+	setFile := flags.StringArray("set-file", nil, "")
+	valuesFiles := flags.StringArrayP("values", "f", nil, "")
+
+	if err := flags.Parse(args); err != nil {
+		return settings, err
+	}
+	if flags.NArg() > 0 {
+		return settings, fmt.Errorf("unexpected argument '%s'", flags.Arg(0))
+	}
+
+	settings.atomic = givenFlag(flags, "atomic", atomic)
+	settings.cleanupOnFail = givenFlag(flags, "cleanup-on-fail", cleanupOnFail)
+	settings.wait = givenFlag(flags, "wait", wait)
+	settings.timeout = givenFlag(flags, "timeout", timeout)
+	settings.createNamespace = givenFlag(flags, "create-namespace", createNamespace)
+	settings.updateDeps = givenFlag(flags, "dependency-update", updateDeps)
+	settings.chartVersion = givenFlag(flags, "version", chartVersion)
+	settings.postRenderer = givenFlag(flags, "post-renderer", postRenderer)
+	settings.postRendererArgs = *postRendererArgs
+	settings.valuesFiles = *valuesFiles
+
+	var err error
+	if settings.set, err = parsePairs(*set); err != nil {
+		return settings, fmt.Errorf("--set: %w", err)
+	}
+	if settings.setString, err = parsePairs(*setString); err != nil {
+		return settings, fmt.Errorf("--set-string: %w", err)
+	}
+	if settings.setFile, err = parsePairs(*setFile); err != nil {
+		return settings, fmt.Errorf("--set-file: %w", err)
+	}
+	return settings, nil
+}
+
+// givenFlag returns value if the flag was given, and nil otherwise.
+func givenFlag[T any](flags *pflag.FlagSet, name string, value *T) *T {
+	if !flags.Changed(name) {
+		return nil
+	}
+	return value
+}
+
+// apply applies the environment's helm flags on top of the helm section of
+// the config file. The CLI flags take precedence over both.
+func (s helmFlagSettings) apply(cfg *Config) {
+	applyUnlessCLI(cfg, "helmAtomic", &cfg.HelmAtomic, s.atomic)
+	applyUnlessCLI(cfg, "helmCleanupOnFail", &cfg.HelmCleanupOnFail, s.cleanupOnFail)
+	applyUnlessCLI(cfg, "helmWait", &cfg.HelmWait, s.wait)
+	applyUnlessCLI(cfg, "helmTimeout", &cfg.HelmTimeout, s.timeout)
+	applyUnlessCLI(cfg, "kubernetesCreateNamespace", &cfg.KubernetesCreateNamespace, s.createNamespace)
+	applyUnlessCLI(cfg, "helmUpdateDependencies", &cfg.HelmUpdateDependencies, s.updateDeps)
+	applyUnlessCLI(cfg, "helmChartVersion", &cfg.HelmChartVersion, s.chartVersion)
+	applyUnlessCLI(cfg, "helmPostRenderer", &cfg.HelmPostRenderer, s.postRenderer)
+	if len(s.postRendererArgs) > 0 {
+		applyUnlessCLI(cfg, "helmPostRendererArgs", &cfg.HelmPostRendererArgs, &s.postRendererArgs)
+	}
+
+	// Values files given on the command line are applied last
+	if cfg.cliFields["helmExtraValuesFiles"] {
+		cfg.HelmExtraValuesFiles = slices.Concat(s.valuesFiles, cfg.HelmExtraValuesFiles)
+	} else {
+		cfg.HelmExtraValuesFiles = slices.Concat(cfg.HelmExtraValuesFiles, s.valuesFiles)
+	}
+	cfg.HelmSet = mergeSetFlags(cfg, "helmSet", cfg.HelmSet, s.set)
+	cfg.HelmSetString = mergeSetFlags(cfg, "helmSetString", cfg.HelmSetString, s.setString)
+	cfg.HelmSetFile = mergeSetFlags(cfg, "helmSetFile", cfg.HelmSetFile, s.setFile)
+}
+
+// applyUnlessCLI sets *setting to the environment's value, if it gives one
+// and the setting wasn't given on the command line.
+func applyUnlessCLI[T any](cfg *Config, field string, setting *T, value *T) {
+	if value != nil && !cfg.cliFields[field] {
+		*setting = *value
+	}
+}
+
+// mergeSetFlags returns a copy of values with the environment's pairs added.
+// Pairs given on the command line win over the environment's, which win over
+// those of the config file.
+func mergeSetFlags(cfg *Config, field string, values, envValues map[string]string) map[string]string {
+	if cfg.cliFields[field] {
+		return mergeMaps(maps.Clone(envValues), values)
+	}
+	return mergeMaps(values, envValues)
+}
+
+// mergeMaps returns a copy of base with the entries of overrides added,
+// leaving base itself untouched.
+func mergeMaps(base, overrides map[string]string) map[string]string {
+	if len(overrides) == 0 {
+		return base
+	}
+	merged := maps.Clone(base)
+	if merged == nil {
+		merged = make(map[string]string)
+	}
+	maps.Copy(merged, overrides)
+	return merged
+}
+
+// ForEnv returns a copy of the configuration that deploys only the given
+// environment, with its Kubernetes settings, helm flags and dry-run applied.
+func (c *Config) ForEnv(env string) *Config {
+	envCfg := *c
+	envCfg.Env = []string{env}
+
+	settings := c.Environments[env].Kubernetes
+	if settings.Config != "" {
+		envCfg.KubernetesConfig = expandKubeConfig(settings.Config)
+	}
+	if settings.Context != "" {
+		envCfg.KubernetesContext = settings.Context
+	}
+	if settings.Namespace != "" {
+		envCfg.KubernetesNamespace = settings.Namespace
+	}
+	envCfg.KubernetesNamespaceLabels = mergeMaps(c.KubernetesNamespaceLabels, settings.NamespaceLabels)
+	envCfg.KubernetesNamespaceAnnotations = mergeMaps(c.KubernetesNamespaceAnnotations, settings.NamespaceAnnotations)
+
+	if c.Environments[env].DryRun {
+		envCfg.DryRun = true
+	}
+
+	c.Environments[env].helm.apply(&envCfg)
+	return &envCfg
+}
+
+// PerEnvironment returns one configuration per environment, in the order the
+// environments were given, or the configuration itself if none are set.
+func (c *Config) PerEnvironment() []*Config {
+	if len(c.Env) == 0 {
+		return []*Config{c}
+	}
+
+	configs := make([]*Config, 0, len(c.Env))
+	for _, env := range c.Env {
+		configs = append(configs, c.ForEnv(env))
+	}
+	return configs
+}
+
+// SingleEnvironment returns the configuration of the only targeted
+// environment, for commands that operate on a single release.
+func (c *Config) SingleEnvironment() (*Config, error) {
+	configs := c.PerEnvironment()
+	if len(configs) > 1 {
+		return nil, fmt.Errorf("this command operates on a single environment, but %d were given. Please select one with --env", len(configs))
+	}
+	return configs[0], nil
+}
+
+// EnvName returns the name of the environment the configuration deploys, if
+// it targets exactly one.
+func (c *Config) EnvName() string {
+	if len(c.Env) == 1 {
+		return c.Env[0]
+	}
+	return ""
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// commandWaitDelay is how long a cancelled command may take to exit after being
+// interrupted before it is killed.
+const commandWaitDelay = 10 * time.Second
+
+// command returns an exec.Cmd bound to ctx. On cancellation the process is
+// interrupted first, giving tools like docker a chance to abort cleanly.
+func command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = commandWaitDelay
+	return cmd
+}
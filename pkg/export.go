@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// Export builds the image and writes it to a tarball at path instead of pushing it,
+// so it can be transferred into air-gapped environments out-of-band.
+func (d *DockerRunner) Export(ctx context.Context, path string) error {
+	if !d.cfg.ShouldRunDockerBuild() {
+		return fmt.Errorf("nothing to export: docker build (--docker-build) is disabled or Dockerfile is missing")
+	}
+
+	ctx, cancel := d.withBuildTimeout(ctx)
+	defer cancel()
+
+	return d.cancellationError(ctx, d.export(ctx, path))
+}
+
+func (d *DockerRunner) export(ctx context.Context, path string) error {
+	if d.cfg.DockerCompose {
+		return d.exportCompose(ctx, path)
+	}
+
+	imageTag, err := d.cfg.ImageTag()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case d.cfg.DockerBuilder == BuilderKaniko:
+		return d.exportKaniko(ctx, imageTag, path)
+	case d.cfg.IsMultiPlatform():
+		return d.exportMultiPlatform(ctx, imageTag, path)
+	}
+
+	if err := d.build(ctx, imageTag); err != nil {
+		return fmt.Errorf("docker build failed: %w", err)
+	}
+	if err := d.checkSize(ctx, imageTag); err != nil {
+		return err
+	}
+
+	args := []string{"save", "-o", path, imageTag}
+	if d.tool() == "buildah" {
+		args = []string{"push", imageTag, fmt.Sprintf("docker-archive:%s:%s", path, imageTag)}
+	}
+	return d.save(ctx, path, args)
+}
+
+// exportCompose builds every compose service and saves all images into a single tarball.
+func (d *DockerRunner) exportCompose(ctx context.Context, path string) error {
+	if d.cfg.DockerBuilder != BuilderDocker || d.cfg.IsMultiPlatform() {
+		return fmt.Errorf("exporting compose projects requires the '%s' builder and a single platform", BuilderDocker)
+	}
+
+	services, err := d.cfg.ComposeServices()
+	if err != nil {
+		return err
+	}
+
+	var tags []string
+	for _, svc := range services {
+		log.Infof("🐙 Compose service: %s", svc.Name)
+		runner := &DockerRunner{cfg: d.cfg.ForService(svc.Name), context: svc.Context, dockerfile: svc.Dockerfile}
+
+		imageTag, err := runner.cfg.ImageTag()
+		if err != nil {
+			return err
+		}
+		if err := runner.build(ctx, imageTag); err != nil {
+			return fmt.Errorf("service %s: docker build failed: %w", svc.Name, err)
+		}
+		if err := runner.checkSize(ctx, imageTag); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+		tags = append(tags, imageTag)
+	}
+
+	return d.save(ctx, path, append([]string{"save", "-o", path}, tags...))
+}
+
+// exportMultiPlatform builds all platforms into a single OCI image layout tarball.
+func (d *DockerRunner) exportMultiPlatform(ctx context.Context, imageTag, path string) error {
+	args := []string{"buildx", "build"}
+	args = append(args, d.pullArgs()...)
+	args = append(args, d.dockerfileArgs()...)
+	args = append(args,
+		"--platform", strings.Join(d.cfg.DockerPlatforms, ","),
+		"-t", imageTag,
+		"--output", "type=oci,dest="+path,
+		d.context,
+	)
+
+	log.Infof("🔨 Building Docker image for %s: %s", strings.Join(d.cfg.DockerPlatforms, ", "), imageTag)
+	return d.save(ctx, path, args)
+}
+
+// exportKaniko builds the image with kaniko and writes it to a tarball without pushing.
+func (d *DockerRunner) exportKaniko(ctx context.Context, imageTag, path string) error {
+	args, err := d.kanikoArgs(imageTag)
+	if err != nil {
+		return err
+	}
+	args = append(args, "--no-push", "--tar-path", path)
+
+	log.Infof("🔨 Building image with kaniko: %s", imageTag)
+	log.Infof("   Build context: %s", d.context)
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", kanikoDefaultExecutor, strings.Join(args, " "))
+		return nil
+	}
+
+	if err := runKanikoExecutor(ctx, args); err != nil {
+		return err
+	}
+
+	log.Infof("✓  Successfully exported image to: %s", path)
+	return nil
+}
+
+// save runs the builder command writing the image tarball to path.
+func (d *DockerRunner) save(ctx context.Context, path string, args []string) error {
+	log.Infof("💾 Exporting image to: %s", path)
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", d.tool(), strings.Join(args, " "))
+		return nil
+	}
+
+	cmd := command(ctx, d.tool(), args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("image export failed: %w", err)
+	}
+
+	if info, err := os.Stat(path); err == nil {
+		log.Infof("✓  Successfully exported image to: %s (%s)", path, formatMB(info.Size()))
+	} else {
+		log.Infof("✓  Successfully exported image to: %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// flavourContractFile declares the values a flavour chart requires. It lives
+// in the chart's root, next to Chart.yaml.
+const flavourContractFile = "contract.yaml"
+
+// flavourContract is the content of a flavour's contract.yaml:
+//
+//	required:
+//	  - path: persistentVolume.storage
+//	    type: quantity
+//	    description: the size of the data volume, e.g. 10Gi
+type flavourContract struct {
+	Required []struct {
+		Path        string `yaml:"path"`
+		Type        string `yaml:"type"` // string, port, quantity, int or bool; any if empty
+		Description string `yaml:"description"`
+	} `yaml:"required"`
+}
+
+// CheckFlavourContract verifies that the values of the artifact's release
+// satisfy the contract of its flavour, such as the persistence settings of
+// the stateful flavour. A missing value would otherwise only surface as a
+// failed rollout. Only the values given to the release are checked, from the
+// values files to the --set overrides: the defaults of the chart are
+// placeholders and never satisfy a contract. Project-local and remote
+// charts, and flavours without a contract.yaml, are not checked.
+func (h *HelmRunner) CheckFlavourContract() error {
+	if !h.cfg.UsesFlavourChart() {
+		return nil
+	}
+	return h.eachRelease((*HelmRunner).checkReleaseFlavourContract)
+}
+
+func (h *HelmRunner) checkReleaseFlavourContract() error {
+	if h.companion != nil {
+		return nil
+	}
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	var contract flavourContract
+	for _, file := range rel.chart.Files {
+		if file.Name == flavourContractFile {
+			if err := yaml.Unmarshal(file.Data, &contract); err != nil {
+				return fmt.Errorf("invalid %s in flavour %s: %w", flavourContractFile, h.cfg.HelmFlavour, err)
+			}
+		}
+	}
+	if len(contract.Required) == 0 {
+		return nil
+	}
+
+	values := chartutil.Values(rel.values)
+	var problems []string
+	for _, req := range contract.Required {
+		value, _ := values.PathValue(req.Path)
+		if problem := checkContractValue(value, req.Type); problem != "" {
+			line := "  - " + req.Path
+			if req.Description != "" {
+				line += " (" + req.Description + ")"
+			}
+			problems = append(problems, line+" "+problem)
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the values do not satisfy the contract of the %s flavour:\n%s\nPlease set them correctly in .dockwright/helm/values.yaml or the environment values files", h.cfg.HelmFlavour, strings.Join(problems, "\n"))
+	}
+	return nil
+}
+
+// checkContractValue describes why a value does not have the type a contract
+// requires, or returns an empty string if it does.
+func checkContractValue(value any, typ string) string {
+	if value == nil || value == "" {
+		return "is not set"
+	}
+	switch typ {
+	case "", "string":
+		return ""
+	case "bool":
+		if _, ok := value.(bool); !ok {
+			return fmt.Sprintf("must be true or false, but is %v", value)
+		}
+	case "int", "port":
+		n, err := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+		if err != nil {
+			return fmt.Sprintf("must be a number, but is %v", value)
+		}
+		if typ == "port" && (n < 1 || n > 65535) {
+			return fmt.Sprintf("must be a port between 1 and 65535, but is %d", n)
+		}
+	case "quantity":
+		if _, err := resource.ParseQuantity(fmt.Sprint(value)); err != nil {
+			return fmt.Sprintf("must be a quantity such as 10Gi, but is %v", value)
+		}
+	default:
+		return fmt.Sprintf("has unknown type '%s' in %s", typ, flavourContractFile)
+	}
+	return ""
+}
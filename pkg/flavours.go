@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// builtinChartsDir is where make package installs the base flavour charts.
+const builtinChartsDir = "/usr/local/share/dockwright/charts"
+
+// builtinFlavours are the flavours shipped with Dockwright.
+var builtinFlavours = []string{"stateful", "stateless"}
+
+// flavourRefreshInterval is how long the clone of a git flavour source that
+// follows a branch is used before it is updated again.
+const flavourRefreshInterval = time.Hour
+
+// checkFlavourName rejects flavour names that would escape the flavour
+// source when joined into a path or a registry reference.
+func checkFlavourName(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid helm flavour '%s': the name must not contain '/' or '..'", name)
+	}
+	return nil
+}
+
+// flavourChart returns the chart of the configured flavour. Built-in flavours
+// take precedence over flavours of helm.flavourSource. For an OCI source, the
+// returned chart is an OCI reference that still has to be pulled.
+func (c *Config) flavourChart() (string, error) {
+	if err := checkFlavourName(c.HelmFlavour); err != nil {
+		return "", err
+	}
+	if slices.Contains(builtinFlavours, c.HelmFlavour) || c.HelmFlavourSource == "" {
+		return c.ChartPath(), nil
+	}
+	if registry.IsOCI(c.HelmFlavourSource) {
+		return strings.TrimSuffix(c.HelmFlavourSource, "/") + "/" + c.HelmFlavour, nil
+	}
+
+	dir, err := c.flavourDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, c.HelmFlavour), nil
+}
+
+// downloadsChart reports whether the chart has to be pulled from a chart
+// repository or an OCI registry.
+func (c *Config) downloadsChart() bool {
+	if !c.UsesFlavourChart() {
+		return c.HelmChart != ""
+	}
+	return !slices.Contains(builtinFlavours, c.HelmFlavour) && registry.IsOCI(c.HelmFlavourSource)
+}
+
+// Flavours returns the names of the available flavours. The flavours of an
+// OCI source can't be listed, so only the built-in ones are returned for it.
+func (c *Config) Flavours() ([]string, error) {
+	flavours := slices.Clone(builtinFlavours)
+	if c.HelmFlavourSource == "" || registry.IsOCI(c.HelmFlavourSource) {
+		return flavours, nil
+	}
+
+	dir, err := c.flavourDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flavours from %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), "Chart.yaml")); err == nil && !slices.Contains(flavours, entry.Name()) {
+			flavours = append(flavours, entry.Name())
+		}
+	}
+	return flavours, nil
+}
+
+// flavourDir returns the local directory holding the flavours of
+// helm.flavourSource. Git sources, given as git::<url> with an optional
+// ?ref=<branch or tag>, are cloned into the user cache directory. A clone of
+// a branch is updated when it is older than flavourRefreshInterval, except
+// with --offline. A clone of a tag is never updated.
+func (c *Config) flavourDir() (string, error) {
+	source, ok := strings.CutPrefix(c.HelmFlavourSource, "git::")
+	if !ok {
+		return source, nil
+	}
+
+	url, ref, _ := strings.Cut(source, "?ref=")
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory for flavours: %w", err)
+	}
+	sum := sha256.Sum256([]byte(source))
+	dir := filepath.Join(cacheDir, "dockwright", "flavours", hex.EncodeToString(sum[:])[:12])
+
+	gitDir := filepath.Join(dir, ".git")
+	info, err := os.Stat(gitDir)
+	if c.Offline {
+		if err != nil {
+			return "", fmt.Errorf("the flavours of %s have not been fetched yet, which --offline does not allow. Please run Dockwright once with network access", url)
+		}
+		log.Debugf("Using the cached flavours of %s, --offline is set", url)
+		return dir, nil
+	}
+	if err == nil {
+		if !followsBranch(gitDir) || time.Since(info.ModTime()) < flavourRefreshInterval {
+			log.Debugf("Using the cached flavours of %s", url)
+			return dir, nil
+		}
+		log.Debugf("Updating flavours from %s", url)
+		fetch := []string{"-C", dir, "fetch", "--depth", "1", "origin"}
+		if ref != "" {
+			fetch = append(fetch, ref)
+		}
+		if err := runGit(fetch...); err != nil {
+			return "", fmt.Errorf("failed to update flavours from %s: %w", url, err)
+		}
+		if err := runGit("-C", dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", fmt.Errorf("failed to update flavours from %s: %w", url, err)
+		}
+		// The modification time of .git records when the clone was updated
+		now := time.Now()
+		if err := os.Chtimes(gitDir, now, now); err != nil {
+			log.Debugf("Could not record the update of the flavours of %s: %v", url, err)
+		}
+		return dir, nil
+	}
+
+	log.Infof("📥 Fetching flavours from %s", url)
+	clone := []string{"clone", "--depth", "1"}
+	if ref != "" {
+		clone = append(clone, "--branch", ref)
+	}
+	if err := runGit(append(clone, url, dir)...); err != nil {
+		return "", fmt.Errorf("failed to fetch flavours from %s: %w", url, err)
+	}
+	return dir, nil
+}
+
+// followsBranch reports whether the clone in gitDir follows a branch. Clones
+// of a tag have a detached HEAD.
+func followsBranch(gitDir string) bool {
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	return err != nil || bytes.HasPrefix(head, []byte("ref: "))
+}
+
+// runGit runs git with the given arguments, returning its error output on failure.
+func runGit(args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// gitCommit returns the commit SHA of HEAD in the current directory.
+func gitCommit() (string, error) {
+	return gitResolveCommit("HEAD")
+}
+
+// gitResolveCommit returns the full SHA of a commit, branch or tag.
+func gitResolveCommit(rev string) (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--verify", "--quiet", rev+"^{commit}").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve git commit %s: %w", rev, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitExactTag returns the tag pointing at HEAD, or an empty string if there is none.
+func gitExactTag() string {
+	out, err := exec.Command("git", "describe", "--tags", "--exact-match", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// gitChanges returns the paths with uncommitted changes, including untracked
+// files, relative to the top of the working tree.
+func gitChanges() ([]string, error) {
+	out, err := exec.Command("git", "status", "--porcelain", "--untracked-files=all").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %w", err)
+	}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if len(line) > 3 {
+			paths = append(paths, line[3:])
+		}
+	}
+	return paths, nil
+}
+
+// uncommittedChanges returns the paths with uncommitted changes, except for
+// the files dockwright writes itself, which would otherwise leave the working
+// tree dirty after every run.
+func (c *Config) uncommittedChanges() ([]string, error) {
+	changes, err := gitChanges()
+	if err != nil || len(changes) == 0 {
+		return changes, err
+	}
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate the git working tree: %w", err)
+	}
+	top := strings.TrimSpace(string(out))
+
+	// git reports the working tree with symlinks resolved
+	own := make(map[string]bool)
+	for _, file := range c.stateFiles() {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+			abs = filepath.Join(dir, filepath.Base(abs))
+		}
+		own[abs] = true
+	}
+	return slices.DeleteFunc(changes, func(path string) bool {
+		return own[filepath.Join(top, filepath.FromSlash(path))]
+	}), nil
+}
+
+// stateFiles returns the files dockwright writes while it runs: the run
+// history, the run report and the logs of file audit backends.
+func (c *Config) stateFiles() []string {
+	var files []string
+	for _, file := range []string{c.HistoryFile, c.ReportFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	for _, b := range c.AuditBackends {
+		if b.Type == "file" && b.Path != "" {
+			files = append(files, b.Path)
+		}
+	}
+	return files
+}
+
+// gitRefs returns the branch and the tags of HEAD. In CI, where HEAD is
+// usually detached, the branch is taken from the CI system's environment.
+func gitRefs() []string {
+	var refs []string
+	if out, err := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD").Output(); err == nil {
+		refs = append(refs, strings.TrimSpace(string(out)))
+	} else {
+		for _, env := range []string{"GITHUB_REF_NAME", "CI_COMMIT_REF_NAME", "BUILD_SOURCEBRANCHNAME"} {
+			if value := os.Getenv(env); value != "" {
+				refs = append(refs, value)
+				break
+			}
+		}
+	}
+	if out, err := exec.Command("git", "tag", "--points-at", "HEAD").Output(); err == nil {
+		refs = append(refs, strings.Fields(string(out))...)
+	}
+	return refs
+}
+
+// CheckGitTree refuses to deploy from a git working tree with uncommitted
+// changes (git.requireClean), or from a branch or tag outside
+// git.allowedRefs, so what is deployed can be traced back to a commit. With
+// kubernetes.protectedContexts, only deploys to protected contexts are
+// checked, otherwise all of them.
+func (c *Config) CheckGitTree() error {
+	if !c.GitRequireClean && len(c.GitAllowedRefs) == 0 {
+		return nil
+	}
+	if len(c.KubernetesProtectedContexts) > 0 && !slices.ContainsFunc(c.targetContexts(), func(context string) bool {
+		return matchesContext(c.KubernetesProtectedContexts, context)
+	}) {
+		return nil
+	}
+	if _, err := gitCommit(); err != nil {
+		return fmt.Errorf("%w. git.requireClean and git.allowedRefs require deploying from a git checkout", err)
+	}
+
+	if c.GitRequireClean {
+		changes, err := c.uncommittedChanges()
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			shown := changes[:min(len(changes), 3)]
+			if len(changes) > len(shown) {
+				shown = append(shown, "…")
+			}
+			return fmt.Errorf("the git working tree has %d uncommitted change(s): %s. Please commit or stash them before deploying", len(changes), strings.Join(shown, ", "))
+		}
+	}
+
+	if len(c.GitAllowedRefs) > 0 {
+		refs := gitRefs()
+		for _, ref := range refs {
+			for _, pattern := range c.GitAllowedRefs {
+				if matched, _ := path.Match(pattern, ref); matched {
+					return nil
+				}
+			}
+		}
+		current := "a detached HEAD"
+		if len(refs) > 0 {
+			current = strings.Join(refs, ", ")
+		}
+		return fmt.Errorf("deploying from %s, which is not in git.allowedRefs (%s). Please deploy from an allowed branch or tag", current, strings.Join(c.GitAllowedRefs, ", "))
+	}
+	return nil
+}
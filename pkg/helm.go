@@ -1,18 +1,40 @@
 package pkg
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 // HelmRunner handles Helm deployment operations.
 type HelmRunner struct {
-	cfg *Config
+	cfg         *Config
+	service     string                 // compose service being deployed, if any
+	companion   *CompanionRelease      // companion release being deployed, if any
+	color       string                 // blue/green color being deployed, if any
+	kubeVersion *chartutil.KubeVersion // cluster version to render for, if known
 }
 
 // NewHelmRunner creates a new HelmRunner with the given configuration.
@@ -20,137 +42,819 @@ func NewHelmRunner(cfg *Config) *HelmRunner {
 	return &HelmRunner{cfg: cfg}
 }
 
-// Run executes the Helm deployment workflow.
-func (h *HelmRunner) Run() error {
-	chartPath := h.cfg.ChartPath()
+// Run executes the Helm deployment workflow. Multiple environments are
+// deployed one after another, each as its own release and after its own
+// confirmation, or with deploy.envConcurrency several at a time, followed by
+// a report of the outcome per environment.
+func (h *HelmRunner) Run(ctx context.Context) error {
+	configs := h.cfg.PerEnvironment()
+	if len(configs) == 1 {
+		return NewHelmRunner(configs[0]).deployCharts(ctx)
+	}
+
+	results := make([]environmentResult, len(configs))
+	for i, cfg := range configs {
+		results[i] = environmentResult{cfg: cfg, status: "⏭️  skipped"}
+	}
+	defer logEnvironmentReport(results)
+
+	if h.parallel() {
+		return h.runParallel(ctx, configs, results)
+	}
 
-	if err := h.validateChartExists(chartPath); err != nil {
+	for i, cfg := range configs {
+		env := cfg.EnvName()
+		log.Infof("🌍 Environment %d/%d: %s", i+1, len(configs), env)
+		log.Infof("   Context: %s", cfg.KubernetesContext)
+		if cfg.KubernetesNamespace != "" {
+			log.Infof("   Namespace: %s", cfg.KubernetesNamespace)
+		}
+		if cfg.DryRun && !h.cfg.DryRun {
+			log.Infof("   🧪 Dry-run for this environment")
+		}
+		start := time.Now()
+		err := NewHelmRunner(cfg).deployCharts(ctx)
+		results[i].duration = time.Since(start).Round(time.Second)
+		if err != nil {
+			results[i].status = "❌ failed"
+			return fmt.Errorf("environment %s: %w", env, err)
+		}
+
+		results[i].status = "✅ deployed"
+		if cfg.DryRun {
+			results[i].status = "🧪 dry-run"
+		}
+		log.Infof("✅ Environment %s deployed", env)
+	}
+	return nil
+}
+
+// deployCharts deploys the artifact's releases and its companion releases.
+// All of them are approved before any of them changes the cluster, so that
+// declining one leaves the cluster as it was. The CRDs of all of them are
+// then applied first, as a release may create custom resources whose CRD
+// another release brings.
+func (h *HelmRunner) deployCharts(ctx context.Context) error {
+	if err := h.eachChart(func(r *HelmRunner) error { return r.approveRelease(ctx) }); err != nil {
+		return err
+	}
+	if err := h.eachChart((*HelmRunner).applyCRDs); err != nil {
 		return err
 	}
+	return h.eachChart(func(r *HelmRunner) error { return r.runRelease(ctx) })
+}
+
+// environmentResult is the outcome of deploying one environment.
+type environmentResult struct {
+	cfg      *Config
+	status   string
+	duration time.Duration
+}
+
+// logEnvironmentReport logs the outcome of every environment with the
+// context and namespace it was deployed to.
+func logEnvironmentReport(results []environmentResult) {
+	var report strings.Builder
+	w := tabwriter.NewWriter(&report, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ENVIRONMENT\tCONTEXT\tNAMESPACE\tDURATION\tSTATUS")
+	for _, r := range results {
+		duration := "-"
+		if r.duration > 0 {
+			duration = r.duration.String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.cfg.EnvName(), r.cfg.KubernetesContext, NewHelmRunner(r.cfg).namespace(), duration, r.status)
+	}
+	_ = w.Flush()
+
+	log.Info("📋 Environment report:")
+	for _, line := range strings.Split(strings.TrimSuffix(report.String(), "\n"), "\n") {
+		log.Infof("   %s", line)
+	}
+}
+
+// eachRelease calls fn for every release of the configured environments,
+// compose services and companion releases.
+func (h *HelmRunner) eachRelease(fn func(*HelmRunner) error) error {
+	configs := h.cfg.PerEnvironment()
+	for _, cfg := range configs {
+		if err := NewHelmRunner(cfg).eachChart(fn); err != nil {
+			if len(configs) > 1 {
+				return fmt.Errorf("environment %s: %w", cfg.EnvName(), err)
+			}
+			return err
+		}
+	}
+	return nil
+}
 
-	valuesFiles, err := h.collectValuesFiles()
+// eachChart calls fn for the artifact's releases and then for each of the
+// companion releases, in dependency order. With --selector, only the
+// matching releases are included.
+func (h *HelmRunner) eachChart(fn func(*HelmRunner) error) error {
+	releases, err := h.cfg.selectedReleases()
 	if err != nil {
-		return fmt.Errorf("failed to collect values files: %w", err)
+		return err
 	}
 
-	args := h.buildArgs(chartPath, valuesFiles)
+	artifactSelected := h.cfg.selects(h.cfg.ReleaseName(), nil)
+	if !artifactSelected && len(releases) == 0 {
+		return fmt.Errorf("no release matches the selector %s", strings.Join(sortedPairs(h.cfg.Selector), ","))
+	}
 
-	imageArgs, err := h.buildImageArgs()
+	if artifactSelected {
+		if err := h.eachService(fn); err != nil {
+			return err
+		}
+	}
+
+	for i := range releases {
+		rel := releases[i]
+		runner := &HelmRunner{cfg: h.cfg.ForRelease(rel), companion: &rel}
+		h.log().Infof("🧩 Companion release: %s", runner.cfg.ReleaseName())
+		if err := fn(runner); err != nil {
+			return fmt.Errorf("release %s: %w", runner.cfg.ReleaseName(), err)
+		}
+	}
+	return nil
+}
+
+// eachService calls fn for the artifact's release, or for one release per
+// buildable compose service.
+func (h *HelmRunner) eachService(fn func(*HelmRunner) error) error {
+	if !h.cfg.DockerCompose {
+		return fn(h)
+	}
+
+	services, err := h.cfg.ComposeServices()
 	if err != nil {
 		return err
 	}
-	args = append(args, imageArgs...)
 
-	return h.execute(args)
+	for _, svc := range services {
+		h.log().Infof("🐙 Compose service: %s", svc.Name)
+		runner := &HelmRunner{cfg: h.cfg.ForService(svc.Name), service: svc.Name}
+		if err := fn(runner); err != nil {
+			return fmt.Errorf("service %s: %w", svc.Name, err)
+		}
+	}
+	return nil
+}
+
+// helmRelease is everything a release is deployed from: the chart and the
+// values layered onto it.
+type helmRelease struct {
+	chartPath   string // local chart directory or downloaded chart archive
+	chart       *chart.Chart
+	valuesFiles []string
+	options     *values.Options
+	values      map[string]interface{}
+	cleanup     func() // removes temporary values files
+}
+
+// runRelease deploys a single Helm release for the configured artifact,
+// once approved by approveRelease.
+func (h *HelmRunner) runRelease(ctx context.Context) error {
+	if h.cfg.HelmStrategy == StrategyBlueGreen && h.companion == nil && h.color == "" {
+		return h.runBlueGreen(ctx)
+	}
+
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	if err := h.ensureNamespace(ctx); err != nil {
+		return err
+	}
+
+	if err := h.ensurePullSecret(ctx); err != nil {
+		return err
+	}
+
+	if err := h.upgrade(ctx, rel); err != nil {
+		return err
+	}
+
+	if h.cfg.HelmRunTests {
+		return h.Test()
+	}
+	return nil
+}
+
+// prepareRelease resolves the chart and merges the values files and value
+// overrides of the release.
+func (h *HelmRunner) prepareRelease() (*helmRelease, error) {
+	chartPath, ch, err := h.resolveChart()
+	if err != nil {
+		return nil, err
+	}
+
+	valuesFiles, cleanup, err := h.collectValuesFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect values files: %w", err)
+	}
+
+	options, err := h.valueOptions(valuesFiles)
+	if err == nil {
+		err = h.addPullSecret(options, ch)
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	vals, err := options.MergeValues(getter.All(h.settings()))
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to merge values: %w", err)
+	}
+
+	return &helmRelease{
+		chartPath:   chartPath,
+		chart:       ch,
+		valuesFiles: valuesFiles,
+		options:     options,
+		values:      vals,
+		cleanup:     cleanup,
+	}, nil
+}
+
+// resolveChart locates the chart to deploy and loads it. Remote charts are
+// downloaded into the Helm repository cache, resolving semver ranges in
+// helm.chartVersion to a concrete version.
+func (h *HelmRunner) resolveChart() (string, *chart.Chart, error) {
+	chartPath, chartRef := h.cfg.HelmChartPath, h.cfg.HelmChart
+	if h.cfg.UsesFlavourChart() {
+		flavourChart, err := h.cfg.flavourChart()
+		if err != nil {
+			return "", nil, err
+		}
+		if registry.IsOCI(flavourChart) {
+			chartRef = flavourChart
+		} else {
+			chartPath = flavourChart
+		}
+	}
+
+	if chartRef == "" {
+		if err := h.validateChartExists(chartPath); err != nil {
+			return "", nil, err
+		}
+	} else {
+		located, err := h.locateChart(chartRef)
+		if err != nil {
+			return "", nil, err
+		}
+		chartPath = located
+	}
+
+	ch, err := h.loadChart(chartPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if ch.Metadata.Deprecated {
+		h.log().Warnf("⚠️  Chart %s is deprecated", ch.Name())
+	}
+
+	if h.cfg.HelmChart != "" && h.cfg.HelmChartVersion != "" {
+		h.log().Infof("📌 Chart version %s resolved to %s", h.cfg.HelmChartVersion, ch.Metadata.Version)
+	} else {
+		h.log().Infof("📌 Chart version: %s", ch.Metadata.Version)
+	}
+	return chartPath, ch, nil
+}
+
+// loadChart loads the chart at chartPath. Dependencies of local charts are
+// updated first if helm.updateDependencies is set, and fetched according to
+// Chart.lock if they are missing from charts/.
+func (h *HelmRunner) loadChart(chartPath string) (*chart.Chart, error) {
+	info, err := os.Stat(chartPath)
+	local := err == nil && info.IsDir()
+	if local && h.cfg.HelmUpdateDependencies {
+		h.log().Infof("📦 Updating chart dependencies of %s", chartPath)
+		manager, err := h.dependencyManager(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		err = manager.Update()
+		flushOutput(manager.Out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update dependencies of chart %s: %w", chartPath, err)
+		}
+	}
+
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", chartPath, err)
+	}
+	if ch.Metadata.Dependencies == nil {
+		return ch, nil
+	}
+
+	if err := action.CheckDependencies(ch, ch.Metadata.Dependencies); err != nil {
+		if !local {
+			return nil, fmt.Errorf("chart %s has missing dependencies: %w", chartPath, err)
+		}
+
+		h.log().Infof("📦 Building missing chart dependencies of %s", chartPath)
+		manager, err := h.dependencyManager(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		err = manager.Build()
+		flushOutput(manager.Out)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build dependencies of chart %s: %w", chartPath, err)
+		}
+		if ch, err = loader.Load(chartPath); err != nil {
+			return nil, fmt.Errorf("failed to reload chart %s: %w", chartPath, err)
+		}
+	}
+	return ch, nil
+}
+
+// dependencyManager returns the manager resolving the dependencies of the
+// local chart at chartPath, as helm dependency build and update do.
+func (h *HelmRunner) dependencyManager(chartPath string) (*downloader.Manager, error) {
+	registryClient, err := h.registryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	settings := h.settings()
+	return &downloader.Manager{
+		Out:              h.cfg.toolOutput(),
+		ChartPath:        chartPath,
+		Getters:          getter.All(settings),
+		RegistryClient:   registryClient,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}, nil
+}
+
+// locateChart downloads the remote chart and returns the path of the archive.
+// Repository credentials are read from HELM_REPOSITORY_USERNAME and
+// HELM_REPOSITORY_PASSWORD, OCI registries use the Docker registry credentials.
+func (h *HelmRunner) locateChart(chartRef string) (string, error) {
+	registryClient, err := h.registryClient()
+	if err != nil {
+		return "", err
+	}
+
+	// ChartPathOptions only accepts a registry client through an action
+	install := action.NewInstall(&action.Configuration{})
+	install.SetRegistryClient(registryClient)
+	install.Version = h.cfg.HelmChartVersion
+
+	if registry.IsOCI(chartRef) {
+		h.log().Infof("✅ Using OCI chart: %s", chartRef)
+	} else {
+		h.log().Infof("✅ Using remote chart: %s from %s", chartRef, h.cfg.HelmRepository)
+		install.RepoURL = h.cfg.HelmRepository
+		install.Username = os.Getenv("HELM_REPOSITORY_USERNAME")
+		install.Password = os.Getenv("HELM_REPOSITORY_PASSWORD")
+	}
+
+	var path string
+	err = h.cfg.retry(context.Background(), "chart", func() (err error) {
+		path, err = install.LocateChart(chartRef, h.settings())
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to download chart %s: %w", chartRef, err)
+	}
+	return path, nil
+}
+
+// deployedRelease returns the latest revision of the release, or nil if the
+// release does not exist or was uninstalled.
+func (h *HelmRunner) deployedRelease() (*release.Release, error) {
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := releaseHistory(cfg, h.cfg.ReleaseName(), 1)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	latest := revisions[len(revisions)-1]
+	if latest.Info != nil && latest.Info.Status == release.StatusUninstalled {
+		return nil, nil
+	}
+	return latest, nil
 }
 
 func (h *HelmRunner) validateChartExists(chartPath string) error {
 	if _, err := os.Stat(chartPath); os.IsNotExist(err) {
 		return fmt.Errorf("helm chart not found at path: %s. Please ensure the chart directory exists", chartPath)
 	}
-	log.Infof("✅ Helm chart found at: %s", chartPath)
+	h.log().Infof("✅ Helm chart found at: %s", chartPath)
 	return nil
 }
 
-func (h *HelmRunner) collectValuesFiles() ([]string, error) {
+func (h *HelmRunner) collectValuesFiles() ([]string, func(), error) {
+	generated := &generatedValues{}
+
+	var files []string
+	var err error
+	if h.companion != nil {
+		files, err = h.companionValuesFiles()
+	} else {
+		files, err = h.artifactValuesFiles(generated)
+	}
+	if err != nil {
+		generated.cleanup()
+		return nil, nil, err
+	}
+
+	if h.cfg.HelmTemplateValues {
+		data := h.valuesTemplateData()
+		templates := 0
+		for i, file := range files {
+			// Secrets may contain {{, so decrypted files are passed on as they are
+			if generated.decrypted[file] {
+				continue
+			}
+			rendered, err := generated.render(file, fmt.Sprintf("%02d-%s", i, filepath.Base(file)), data)
+			if err != nil {
+				generated.cleanup()
+				return nil, nil, err
+			}
+			files[i] = rendered
+			templates++
+		}
+		h.log().Infof("🧩 Rendered %d values file template(s)", templates)
+	}
+
+	h.log().Infof("✅ Collected %d values file(s) for deployment", len(files))
+	return files, generated.cleanup, nil
+}
+
+// artifactValuesFiles returns the base, service, environment and extra values
+// files of the artifact's release, decrypting encrypted ones into generated.
+func (h *HelmRunner) artifactValuesFiles(generated *generatedValues) ([]string, error) {
 	var files []string
 
 	// Base values file (optional)
 	baseValues := filepath.Join(".dockwright", "helm", "values.yaml")
 	if _, err := os.Stat(baseValues); err == nil {
 		files = append(files, baseValues)
-		log.Infof("📄 Found base values file: %s", baseValues)
+		h.log().Infof("📄 Found base values file: %s", baseValues)
+	}
+
+	// Compose service values file (optional)
+	if h.service != "" {
+		serviceValues := filepath.Join(".dockwright", "helm", "services", fmt.Sprintf("%s.values.yaml", h.service))
+		if _, err := os.Stat(serviceValues); err == nil {
+			files = append(files, serviceValues)
+			h.log().Infof("📄 Found service values file: %s", serviceValues)
+		}
 	}
 
-	// Environment-specific values files
+	// Environment-specific values files, plain and/or SOPS-encrypted
 	for _, env := range h.cfg.Env {
-		envValues := filepath.Join(".dockwright", "helm", fmt.Sprintf("%s.values.yaml", env))
-		if _, err := os.Stat(envValues); os.IsNotExist(err) {
-			return nil, fmt.Errorf("environment values file not found at path: %s. Please ensure the file exists", envValues)
+		envValues, encValues := envValuesFiles(env)
+		_, plainErr := os.Stat(envValues)
+		_, encErr := os.Stat(encValues)
+		if os.IsNotExist(plainErr) && os.IsNotExist(encErr) {
+			return nil, fmt.Errorf("environment values file not found at path: %s (or encrypted %s). Please ensure the file exists", envValues, encValues)
+		}
+
+		if plainErr == nil {
+			files = append(files, envValues)
+			h.log().Infof("📄 Found environment values file: %s", envValues)
+		}
+		if encErr == nil {
+			decrypted, err := generated.decrypt(encValues)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, decrypted)
+			h.log().Infof("🔓 Decrypted environment values file: %s", encValues)
 		}
-		files = append(files, envValues)
-		log.Infof("📄 Found environment values file: %s", envValues)
 	}
 
-	log.Infof("✅ Collected %d values file(s) for deployment", len(files))
+	// Additional values files (optional), applied last
+	for _, extraValues := range h.cfg.HelmExtraValuesFiles {
+		if _, err := os.Stat(extraValues); err != nil {
+			return nil, fmt.Errorf("extra values file not found at path: %s. Please check helm.extraValuesFiles and --values", extraValues)
+		}
+		files = append(files, extraValues)
+		h.log().Infof("📄 Found extra values file: %s", extraValues)
+	}
 	return files, nil
 }
 
-func (h *HelmRunner) buildArgs(chartPath string, valuesFiles []string) []string {
-	args := []string{
-		"upgrade", "--install",
-		h.cfg.ArtifactName,
-		chartPath,
-		"--kubeconfig", h.cfg.KubernetesConfig,
+// valueOptions returns the values files together with the image values and the
+// user-provided --set, --set-string and --set-file overrides, which take
+// precedence over the image values.
+func (h *HelmRunner) valueOptions(valuesFiles []string) (*values.Options, error) {
+	options := &values.Options{ValueFiles: valuesFiles}
+
+	if h.companion == nil && h.cfg.ShouldRunDockerBuild() {
+		imageRepo, err := h.cfg.ImageRepository()
+		if err != nil {
+			return nil, err
+		}
+		h.log().Infof("💉 Injecting image configuration into Helm deployment")
+		h.log().Infof("   Repository: %s", imageRepo)
+		h.log().Infof("   Tag: %s", h.cfg.tag())
+		options.Values = append(options.Values,
+			fmt.Sprintf("image.repository=%s", imageRepo),
+			fmt.Sprintf("image.tag=%s", h.cfg.tag()),
+		)
 	}
 
-	if h.cfg.KubernetesContext != "" {
-		args = append(args, "--kube-context", h.cfg.KubernetesContext)
+	options.Values = append(options.Values, sortedPairs(h.cfg.HelmSet)...)
+	options.StringValues = append(options.StringValues, sortedPairs(h.cfg.HelmSetString)...)
+	options.FileValues = append(options.FileValues, sortedPairs(h.cfg.HelmSetFile)...)
+	return options, nil
+}
+
+// addPullSecret adds kubernetes.imagePullSecret to the imagePullSecrets the
+// chart defaults or the values files already list, instead of replacing the
+// first of them. The list is passed as --set-json, so helm.set still takes
+// precedence.
+func (h *HelmRunner) addPullSecret(options *values.Options, ch *chart.Chart) error {
+	name := h.cfg.KubernetesImagePullSecret
+	if h.companion != nil || name == "" {
+		return nil
 	}
 
-	for _, f := range valuesFiles {
-		args = append(args, "--values", f)
+	files := &values.Options{ValueFiles: options.ValueFiles}
+	vals, err := files.MergeValues(getter.All(h.settings()))
+	if err != nil {
+		return fmt.Errorf("failed to merge values: %w", err)
+	}
+	current, ok := vals["imagePullSecrets"]
+	if !ok {
+		current = ch.Values["imagePullSecrets"]
+	}
+	secrets, _ := current.([]interface{})
+	for _, secret := range secrets {
+		if secret == name {
+			return nil
+		}
+		if entry, ok := secret.(map[string]interface{}); ok && entry["name"] == name {
+			return nil
+		}
+	}
+
+	h.log().Infof("🔑 Injecting image pull secret: %s", name)
+	// Charts listing the secrets by name rather than as {name: ...} get a name
+	var secret interface{} = map[string]interface{}{"name": name}
+	if len(secrets) > 0 {
+		if _, ok := secrets[0].(string); ok {
+			secret = name
+		}
+	}
+	list, err := json.Marshal(append(slices.Clone(secrets), secret))
+	if err != nil {
+		return err
+	}
+	options.JSONValues = append(options.JSONValues, "imagePullSecrets="+string(list))
+	return nil
+}
+
+// sortedPairs returns the map as key=value pairs sorted by key.
+func sortedPairs(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, m[key]))
+	}
+	return pairs
+}
+
+// kubeArgs returns the flags selecting the kubeconfig and context for helm
+// commands. A list of kubeconfig files is passed through KUBECONFIG instead.
+func (h *HelmRunner) kubeArgs() []string {
+	var args []string
+	if len(kubeConfigPaths(h.cfg.KubernetesConfig)) == 1 {
+		args = append(args, "--kubeconfig", h.cfg.KubernetesConfig)
+	}
+	if h.cfg.KubernetesContext != "" {
+		args = append(args, "--kube-context", h.cfg.KubernetesContext)
+	}
+	if h.cfg.KubernetesNamespace != "" {
+		args = append(args, "--namespace", h.cfg.KubernetesNamespace)
+	}
 	return args
 }
 
-func (h *HelmRunner) buildImageArgs() ([]string, error) {
-	if h.cfg.ShouldRunDockerBuild() {
-		imageRepo, err := h.cfg.ImageRepository()
-		if err != nil {
-			return nil, err
+// diff shows the changes the upgrade would make to the live release using the
+// helm-diff plugin.
+func (h *HelmRunner) diff(rel *helmRelease) error {
+	if !h.cfg.HelmDiff {
+		return nil
+	}
+
+	args := []string{"diff", "upgrade", "--allow-unreleased", "--detailed-exitcode", h.cfg.ReleaseName(), rel.chartPath}
+	args = append(args, h.kubeArgs()...)
+	for _, f := range rel.options.ValueFiles {
+		args = append(args, "--values", f)
+	}
+	for _, v := range rel.options.JSONValues {
+		args = append(args, "--set-json", v)
+	}
+	for _, v := range rel.options.Values {
+		args = append(args, "--set", v)
+	}
+	for _, v := range rel.options.StringValues {
+		args = append(args, "--set-string", v)
+	}
+	for _, v := range rel.options.FileValues {
+		args = append(args, "--set-file", v)
+	}
+	if h.cfg.HelmPostRenderer != "" {
+		args = append(args, "--post-renderer", h.cfg.HelmPostRenderer)
+		for _, arg := range h.cfg.HelmPostRendererArgs {
+			args = append(args, "--post-renderer-args", arg)
 		}
-		log.Infof("💉 Injecting image configuration into Helm deployment")
-		log.Infof("   Repository: %s", imageRepo)
-		log.Infof("   Tag: latest")
-		return []string{
-			"--set", fmt.Sprintf("image.repository=%s", imageRepo),
-			"--set", "image.tag=latest",
-		}, nil
 	}
+	if h.cfg.Debug {
+		args = append(args, "--debug")
+	}
+
+	h.log().Infof("🔍 Computing changes for release: %s", h.cfg.ReleaseName())
+
+	cmd := exec.Command("helm", args...)
+	cmd.Env = append(os.Environ(), "KUBECONFIG="+h.cfg.KubernetesConfig)
+	cmd.Stdout = h.cfg.terminalOutput()
+	cmd.Stderr = h.cfg.terminalOutput()
 
-	return nil, nil
+	err := cmd.Run()
+	flushOutput(cmd.Stdout)
+	flushOutput(cmd.Stderr)
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		h.log().Info("✅ No changes detected for release")
+		return nil
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		// --detailed-exitcode reports pending changes with exit code 2
+		return nil
+	default:
+		return fmt.Errorf("helm diff failed: %w", err)
+	}
 }
 
-func (h *HelmRunner) execute(args []string) error {
+// upgrade installs the release, or upgrades it if it already exists. In
+// dry-run mode the release is only rendered, without contacting the cluster
+// unless a server-side dry-run is requested.
+func (h *HelmRunner) upgrade(ctx context.Context, rel *helmRelease) error {
 	if h.cfg.DryRun {
-		args = append(args, "--dry-run")
-		log.Info("   🧪 [DRY-RUN] Would run: helm")
-		h.logArgs(args)
+		rendered, err := h.render(rel)
+		if err != nil {
+			return err
+		}
+		h.log().Infof("   🧪 [DRY-RUN] Would deploy release %s (chart %s-%s) to namespace %s", h.cfg.ReleaseName(), rel.chart.Name(), rel.chart.Metadata.Version, h.namespace())
+		h.logManifest(rendered)
+		if h.cfg.ServerDryRun {
+			if err := h.serverDryRun(ctx, rendered); err != nil {
+				return err
+			}
+		}
+		h.cfg.run.deployed(h, rendered)
 		return nil
 	}
 
-	log.Infof("🚀 Executing Helm deployment for artifact: %s", h.cfg.ArtifactName)
-	log.Infof("   Kubeconfig: %s", h.cfg.KubernetesConfig)
+	h.log().Infof("🚀 Executing Helm deployment for release: %s", h.cfg.ReleaseName())
+	h.log().Infof("   Kubeconfig: %s", h.cfg.KubernetesConfig)
 	if h.cfg.KubernetesContext != "" {
-		log.Infof("   Context: %s", h.cfg.KubernetesContext)
+		h.log().Infof("   Context: %s", h.cfg.KubernetesContext)
 	}
-	log.Info("   Running: helm")
-	h.logArgs(args)
+	h.log().Infof("   Namespace: %s", h.namespace())
+	h.log().Infof("   Chart: %s-%s", rel.chart.Name(), rel.chart.Metadata.Version)
 
-	cmd := exec.Command("helm", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+	postRenderer, err := h.postRenderer()
+	if err != nil {
+		return err
+	}
+
+	var labels map[string]string
+	if h.cfg.HelmMetadata {
+		metadata := newDeploymentMetadata(h.cfg)
+		if h.cfg.imageCommit != "" {
+			// A promotion deploys the image of the commit the source release was deployed from
+			metadata.Commit = h.cfg.imageCommit
+		}
+		h.log().Infof("   Deployed by: %s", metadata.DeployedBy)
+		if h.cfg.HelmMetadataAnnotations {
+			postRenderer = &metadataPostRenderer{annotations: metadata.annotations(), next: postRenderer}
+		}
+		labels = metadata.releaseLabels()
+	}
+
+	started := time.Now().Add(-10 * time.Second) // allow for clock skew with the cluster
+	hooks := h.watchHooks(ctx, cfg)
+	events := h.watchEvents(ctx, cfg)
+	var deployed *release.Release
+	err = h.cfg.retry(ctx, "upgrade", func() (err error) {
+		deployed, err = h.installOrUpgrade(ctx, cfg, rel, postRenderer, labels)
+		return err
+	})
+	failedHooks := hooks.stop()
+	if err != nil {
+		events.stop()
+		if failedHooks != "" {
+			err = fmt.Errorf("helm deployment failed: %w\n%s", err, failedHooks)
+		} else {
+			err = fmt.Errorf("helm deployment failed: %w", err)
+		}
+		return h.rollbackFailedUpgrade(cfg, err)
+	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("helm deployment failed: %w", err)
+	if h.cfg.HelmRolloutStatus {
+		err = h.waitForRollout(ctx, cfg, deployed)
+	}
+	if err == nil {
+		err = h.waitForBlockingJobs(ctx, deployed, started)
+	}
+	if err == nil {
+		err = h.smokeTest(ctx, deployed)
+	}
+	events.stop()
+	if err != nil {
+		return h.autoRollback(cfg, deployed, err)
 	}
 
-	log.Infof("✓  Successfully deployed %s with Helm", h.cfg.ArtifactName)
+	h.log().Infof("✓  Successfully deployed %s with Helm (revision %d, %s)", h.cfg.ReleaseName(), deployed.Version, deployed.Info.Status)
+	h.cfg.run.deployed(h, deployed)
 	return nil
 }
 
-func (h *HelmRunner) logArgs(args []string) {
-	log.Info("   Arguments:")
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
-		// Pair flags with their values on the same line
-		if i+1 < len(args) && strings.HasPrefix(arg, "--") && !strings.HasPrefix(args[i+1], "--") {
-			log.Infof("     \033[32m%s\033[0m = %s", arg, args[i+1])
-			i++ // skip the value
-		} else {
-			log.Infof("     %s", arg)
-		}
+// installOrUpgrade installs the release if it doesn't exist or was
+// uninstalled, and upgrades it otherwise. The release history is read anew on
+// every attempt, as a failed attempt may have installed the release.
+func (h *HelmRunner) installOrUpgrade(ctx context.Context, cfg *action.Configuration, rel *helmRelease, postRenderer postrender.PostRenderer, labels map[string]string) (*release.Release, error) {
+	revisions, err := releaseHistory(cfg, h.cfg.ReleaseName(), 1)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, fmt.Errorf("failed to read release history: %w", err)
+	}
+	uninstalled := err == nil && revisions[len(revisions)-1].Info.Status == release.StatusUninstalled
+
+	if err != nil || uninstalled {
+		install := action.NewInstall(cfg)
+		install.ReleaseName = h.cfg.ReleaseName()
+		install.Namespace = h.namespace()
+		install.CreateNamespace = h.cfg.KubernetesCreateNamespace
+		install.Replace = uninstalled
+		install.Atomic = h.cfg.HelmAtomic
+		install.Wait = h.cfg.HelmWait || h.cfg.HelmAtomic
+		install.Timeout = h.timeout()
+		install.PostRenderer = postRenderer
+		install.Labels = labels
+		return install.RunWithContext(ctx, rel.chart, rel.values)
+	}
+
+	upgrade := action.NewUpgrade(cfg)
+	upgrade.Namespace = h.namespace()
+	upgrade.Atomic = h.cfg.HelmAtomic
+	upgrade.CleanupOnFail = h.cfg.HelmCleanupOnFail
+	upgrade.Wait = h.cfg.HelmWait || h.cfg.HelmAtomic
+	upgrade.Timeout = h.timeout()
+	upgrade.PostRenderer = postRenderer
+	upgrade.Labels = labels
+	return upgrade.RunWithContext(ctx, h.cfg.ReleaseName(), rel.chart, rel.values)
+}
+
+// render renders the release in-process, as helm template does.
+func (h *HelmRunner) render(rel *helmRelease) (*release.Release, error) {
+	postRenderer, err := h.postRenderer()
+	if err != nil {
+		return nil, err
+	}
+
+	install := action.NewInstall(&action.Configuration{})
+	install.ReleaseName = h.cfg.ReleaseName()
+	install.Namespace = h.namespace()
+	install.DryRun = true
+	install.DryRunOption = "client"
+	install.ClientOnly = true
+	install.Replace = true
+	install.IncludeCRDs = true
+	install.PostRenderer = postRenderer
+	install.KubeVersion = h.kubeVersion
+
+	rendered, err := install.Run(rel.chart, rel.values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render release %s: %w", h.cfg.ReleaseName(), err)
 	}
+	return rendered, nil
 }
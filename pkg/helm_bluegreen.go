@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/postrender"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// colorLabel is the pod label selecting the blue or the green deployment.
+const colorLabel = "dockwright.io/color"
+
+// runBlueGreen deploys the release with the blue/green strategy: the new
+// version is installed as the inactive color, <release>-blue or
+// <release>-green, and must become ready and pass its tests before the
+// Service selector is switched to it. The previous color is uninstalled
+// after helm.blueGreen.gracePeriod, or kept for a quick switch back.
+func (h *HelmRunner) runBlueGreen(ctx context.Context) error {
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+
+	active, next, err := h.nextColor(ctx, client)
+	if err != nil {
+		return err
+	}
+	h.log().Infof("🔵🟢 Blue/green deployment of %s: active color %s, deploying %s", h.cfg.ReleaseName(), colorOrNone(active), next)
+
+	colored := h.forColor(next)
+	if err := colored.runRelease(ctx); err != nil {
+		return fmt.Errorf("%s deployment failed, traffic stays on %s: %w", next, colorOrNone(active), err)
+	}
+
+	if err := h.switchColor(ctx, client, next); err != nil {
+		return err
+	}
+
+	if active == "" || h.cfg.HelmBlueGreenGracePeriod == 0 {
+		if active != "" {
+			h.log().Infof("   Keeping %s as %s for a quick switch back", h.forColor(active).cfg.ReleaseName(), active)
+		}
+		return nil
+	}
+
+	h.log().Infof("⏳ Keeping %s for %s before removing it", active, h.cfg.HelmBlueGreenGracePeriod)
+	if !h.cfg.DryRun {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(h.cfg.HelmBlueGreenGracePeriod):
+		}
+	}
+	return h.forColor(active).Uninstall(false)
+}
+
+// nextColor returns the color the Service routes to and the other color,
+// which the next version is deployed as.
+func (h *HelmRunner) nextColor(ctx context.Context, client kubernetes.Interface) (active, next string, err error) {
+	active, err = h.activeColor(ctx, client)
+	if err != nil && h.cfg.DryRun {
+		h.log().Warnf("⚠️  Could not read the active color, assuming none: %v", err)
+	} else if err != nil {
+		return "", "", err
+	}
+	if active == "blue" {
+		return active, "green", nil
+	}
+	return active, "blue", nil
+}
+
+// CheckBlueGreenService verifies that the Service the blue/green strategy
+// switches exists in each environment. Dockwright doesn't create it, and only
+// changes its selector: an Ingress or other routing must go through it to
+// follow the switch. Without the Service, a deploy would only fail after the
+// new color was installed.
+func (h *HelmRunner) CheckBlueGreenService(ctx context.Context) error {
+	if h.cfg.HelmStrategy != StrategyBlueGreen {
+		return nil
+	}
+	if h.cfg.DryRun {
+		h.log().Info("⏭️  Skipping blue/green Service check in dry-run mode")
+		return nil
+	}
+
+	for _, cfg := range h.cfg.PerEnvironment() {
+		env := NewHelmRunner(cfg)
+		client, err := cfg.KubeClient()
+		if err != nil {
+			return err
+		}
+		if _, err := env.activeColor(ctx, client); err != nil {
+			return err
+		}
+		env.log().Infof("✅ Blue/green Service %s found", env.blueGreenService())
+	}
+	return nil
+}
+
+// forColor returns a runner deploying the release as the given color.
+func (h *HelmRunner) forColor(color string) *HelmRunner {
+	colorCfg := *h.cfg
+	colorCfg.HelmReleaseName = fmt.Sprintf("%s-%s", h.cfg.ReleaseName(), color)
+	colorCfg.HelmWait = true // the Service is only switched to ready pods
+	return &HelmRunner{cfg: &colorCfg, service: h.service, color: color}
+}
+
+// blueGreenService returns the name of the Service routing the traffic.
+func (h *HelmRunner) blueGreenService() string {
+	if h.cfg.HelmBlueGreenService != "" {
+		return h.cfg.HelmBlueGreenService
+	}
+	return h.cfg.ReleaseName()
+}
+
+// activeColor returns the color the Service currently routes to, or an empty
+// string if it doesn't route to either yet.
+func (h *HelmRunner) activeColor(ctx context.Context, client kubernetes.Interface) (string, error) {
+	name := h.blueGreenService()
+	svc, err := client.CoreV1().Services(h.namespace()).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("blue/green Service %s not found in namespace %s. Please create it, or set helm.blueGreen.service", name, h.namespace())
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get Service %s: %w", name, err)
+	}
+	return svc.Spec.Selector[colorLabel], nil
+}
+
+// switchColor points the Service selector to the pods of the given color.
+func (h *HelmRunner) switchColor(ctx context.Context, client kubernetes.Interface, color string) error {
+	name := h.blueGreenService()
+	h.log().Infof("🔀 Switching Service %s to %s", name, color)
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would set the selector %s=%s on Service %s", colorLabel, color, name)
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"selector": map[string]string{colorLabel: color}},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Services(h.namespace()).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to switch Service %s to %s: %w", name, color, err)
+	}
+	h.log().Infof("✓  Traffic now goes to %s", color)
+	return nil
+}
+
+func colorOrNone(color string) string {
+	if color == "" {
+		return "none"
+	}
+	return color
+}
+
+// colorPostRenderer labels the pods of every workload with their color, so
+// the Service can select them.
+type colorPostRenderer struct {
+	color string
+	next  postrender.PostRenderer
+}
+
+func (p *colorPostRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	if p.next != nil {
+		var err error
+		if rendered, err = p.next.Run(rendered); err != nil {
+			return nil, err
+		}
+	}
+	return transformManifests(rendered, func(resource *yaml.Node) {
+		if labels := podLabels(resource); labels != nil {
+			setMappingString(labels, colorLabel, p.color)
+		}
+	})
+}
+
+// podLabels returns the pod labels of a pod or a workload's pod template, or
+// nil if the resource doesn't define pods.
+func podLabels(resource *yaml.Node) *yaml.Node {
+	kind := lookupMapping(resource, "kind")
+	if kind == nil {
+		return nil
+	}
+
+	var path []string
+	switch kind.Value {
+	case "Pod":
+		path = []string{"metadata", "labels"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		path = []string{"spec", "template", "metadata", "labels"}
+	case "CronJob":
+		path = []string{"spec", "jobTemplate", "spec", "template", "metadata", "labels"}
+	default:
+		return nil
+	}
+
+	node := resource
+	for _, key := range path {
+		node = mappingValue(node, key)
+	}
+	return node
+}
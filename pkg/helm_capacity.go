@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// podTemplatePaths are the paths of the pod templates of the workload kinds
+// whose replicas are counted against the cluster's capacity.
+var podTemplatePaths = map[string][]string{
+	"Deployment":  {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"ReplicaSet":  {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+	"Pod":         {"spec"},
+}
+
+// releasePods are the pods a release runs, with their requests and limits.
+type releasePods struct {
+	count    int64
+	requests corev1.ResourceList
+	limits   corev1.ResourceList
+	perPod   map[string]corev1.ResourceList // requests of a single pod per workload
+}
+
+// CheckCapacity compares the resources the releases request with the
+// namespace's ResourceQuotas and the allocatable capacity of the cluster's
+// nodes, and warns when a release cannot possibly be scheduled. Its findings
+// are warnings, as the scheduler has the final say.
+func (h *HelmRunner) CheckCapacity(ctx context.Context) error {
+	if h.cfg.DryRun {
+		h.log().Info("⏭️  Skipping capacity check in dry-run mode")
+		return nil
+	}
+	warnings := 0
+	err := h.eachRelease(func(r *HelmRunner) error {
+		n, err := r.checkReleaseCapacity(ctx)
+		warnings += n
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if warnings > 0 {
+		return warningf("%d capacity warning(s), the releases may not be schedulable", warnings)
+	}
+	return nil
+}
+
+// checkReleaseCapacity logs the capacity warnings of the release and returns
+// their number.
+func (h *HelmRunner) checkReleaseCapacity(ctx context.Context) (int, error) {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return 0, err
+	}
+	defer rel.cleanup()
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		return 0, err
+	}
+	pods, err := podsOf(rendered.Manifest)
+	if err != nil {
+		return 0, err
+	}
+	if pods.count == 0 {
+		return 0, nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return 0, err
+	}
+	warnings := 0
+
+	quotas, err := client.CoreV1().ResourceQuotas(h.namespace()).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		h.log().Debugf("Could not list the resource quotas of namespace %s: %v", h.namespace(), err)
+	} else {
+		for _, quota := range quotas.Items {
+			for _, name := range slices.Sorted(maps.Keys(quota.Status.Hard)) {
+				hard := quota.Status.Hard[name]
+				required, ok := pods.quotaUsage(name)
+				if ok && required.Cmp(hard) > 0 {
+					h.log().Warnf("⚠️  Release %s needs %s %s, but ResourceQuota %s allows only %s", h.cfg.ReleaseName(), required.String(), name, quota.Name, hard.String())
+					warnings++
+				}
+			}
+		}
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		h.log().Debugf("Could not list the cluster's nodes: %v", err)
+	} else {
+		allocatable := corev1.ResourceList{}
+		largestNode := corev1.ResourceList{}
+		for _, node := range nodes.Items {
+			if node.Spec.Unschedulable {
+				continue
+			}
+			for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+				capacity := node.Status.Allocatable[name]
+				addQuantity(allocatable, name, capacity)
+				if largest := largestNode[name]; capacity.Cmp(largest) > 0 {
+					largestNode[name] = capacity
+				}
+			}
+		}
+
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			requested := pods.requests[name]
+			if total := allocatable[name]; requested.Cmp(total) > 0 {
+				h.log().Warnf("⚠️  Release %s requests %s %s, but all schedulable nodes together have only %s allocatable", h.cfg.ReleaseName(), requested.String(), name, total.String())
+				warnings++
+			}
+			for _, workload := range slices.Sorted(maps.Keys(pods.perPod)) {
+				pod := pods.perPod[workload][name]
+				if largest := largestNode[name]; pod.Cmp(largest) > 0 {
+					h.log().Warnf("⚠️  Pods of %s request %s %s, but the largest schedulable node has only %s allocatable", workload, pod.String(), name, largest.String())
+					warnings++
+				}
+			}
+		}
+	}
+
+	if warnings == 0 {
+		cpu, memory := pods.requests[corev1.ResourceCPU], pods.requests[corev1.ResourceMemory]
+		h.log().Infof("✅ Release %s fits: %d pod(s) requesting %s CPU and %s memory", h.cfg.ReleaseName(), pods.count, cpu.String(), memory.String())
+	}
+	return warnings, nil
+}
+
+// podsOf sums up the replicas, requests and limits of the pods the workloads
+// of a manifest run. DaemonSets are left out, as they scale with the nodes.
+func podsOf(manifest string) (*releasePods, error) {
+	pods := &releasePods{requests: corev1.ResourceList{}, limits: corev1.ResourceList{}, perPod: map[string]corev1.ResourceList{}}
+	objects := manifestObjects(manifest)
+	for _, name := range slices.Sorted(maps.Keys(objects)) {
+		obj := objects[name]
+		kind, _ := obj["kind"].(string)
+		path, ok := podTemplatePaths[kind]
+		if !ok {
+			continue
+		}
+
+		raw := interface{}(obj)
+		for _, key := range path {
+			m, _ := raw.(map[string]interface{})
+			raw = m[key]
+		}
+		content, err := json.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		var spec corev1.PodSpec
+		if err := json.Unmarshal(content, &spec); err != nil {
+			return nil, fmt.Errorf("invalid pod template in %s: %w", name, err)
+		}
+
+		replicas := int64(1)
+		if specMap, ok := obj["spec"].(map[string]interface{}); ok && kind != "Pod" {
+			switch n := specMap["replicas"].(type) {
+			case int:
+				replicas = int64(n)
+			case float64:
+				replicas = int64(n)
+			}
+			if kind == "Job" {
+				if n, ok := specMap["parallelism"].(int); ok {
+					replicas = int64(n)
+				}
+			}
+		}
+
+		requests, limits := podResources(spec)
+		pods.count += replicas
+		pods.perPod[name] = requests
+		for resourceName, quantity := range requests {
+			total := quantity.DeepCopy()
+			total.Mul(replicas)
+			addQuantity(pods.requests, resourceName, total)
+		}
+		for resourceName, quantity := range limits {
+			total := quantity.DeepCopy()
+			total.Mul(replicas)
+			addQuantity(pods.limits, resourceName, total)
+		}
+	}
+	return pods, nil
+}
+
+// podResources returns the effective requests and limits of a pod: the sum
+// of its containers, or the largest init container if that is higher, as
+// the scheduler computes them.
+func podResources(spec corev1.PodSpec) (corev1.ResourceList, corev1.ResourceList) {
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, c := range spec.Containers {
+		for name, quantity := range c.Resources.Requests {
+			addQuantity(requests, name, quantity)
+		}
+		for name, quantity := range c.Resources.Limits {
+			addQuantity(limits, name, quantity)
+		}
+	}
+	for _, c := range spec.InitContainers {
+		for name, quantity := range c.Resources.Requests {
+			if current := requests[name]; quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+		for name, quantity := range c.Resources.Limits {
+			if current := limits[name]; quantity.Cmp(current) > 0 {
+				limits[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return requests, limits
+}
+
+// quotaUsage returns how much of a ResourceQuota resource the pods use, if
+// it is a resource the check knows.
+func (p *releasePods) quotaUsage(name corev1.ResourceName) (resource.Quantity, bool) {
+	switch name {
+	case corev1.ResourcePods:
+		return *resource.NewQuantity(p.count, resource.DecimalSI), true
+	case corev1.ResourceCPU, corev1.ResourceRequestsCPU:
+		return p.requests[corev1.ResourceCPU], true
+	case corev1.ResourceMemory, corev1.ResourceRequestsMemory:
+		return p.requests[corev1.ResourceMemory], true
+	case corev1.ResourceLimitsCPU:
+		return p.limits[corev1.ResourceCPU], true
+	case corev1.ResourceLimitsMemory:
+		return p.limits[corev1.ResourceMemory], true
+	}
+	return resource.Quantity{}, false
+}
+
+// addQuantity adds quantity to the named resource of list.
+func addQuantity(list corev1.ResourceList, name corev1.ResourceName, quantity resource.Quantity) {
+	total := list[name]
+	total.Add(quantity)
+	list[name] = total
+}
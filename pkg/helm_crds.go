@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// crdEstablishTimeout is how long the API server may take to serve applied
+// CRDs, as Helm allows when it installs them.
+const crdEstablishTimeout = 60 * time.Second
+
+// applyCRDs applies the CustomResourceDefinitions of the release's chart, from
+// its crds directories as well as its templates, and waits for the API server
+// to serve them. Custom resources of the release, or of releases deployed
+// after it, can then be mapped when the upgrade builds its manifests. As with
+// Helm, CRDs of the crds directories are only created if missing, never
+// updated. CRDs of the templates belong to the release: they get Helm's
+// ownership metadata, so the upgrade adopts them, and are applied every time.
+func (h *HelmRunner) applyCRDs() error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	// CRDs by name, with those of the crds directories taking precedence
+	crds := map[string]map[string]interface{}{}
+	rendered, err := h.render(rel)
+	if err != nil {
+		return err
+	}
+	templated := map[string]bool{}
+	for name, obj := range manifestObjects(rendered.Manifest) {
+		if obj["kind"] == "CustomResourceDefinition" {
+			crds[name] = obj
+			templated[name] = true
+		}
+	}
+	for _, crd := range rel.chart.CRDObjects() {
+		for name, obj := range manifestObjects(string(crd.File.Data)) {
+			crds[name] = obj
+			delete(templated, name)
+		}
+	}
+	if len(crds) == 0 {
+		return nil
+	}
+
+	names := slices.Sorted(maps.Keys(crds))
+	if h.cfg.DryRun {
+		for _, name := range names {
+			if templated[name] {
+				h.log().Infof("   🧪 [DRY-RUN] Would apply %s", name)
+			} else {
+				h.log().Infof("   🧪 [DRY-RUN] Would create %s unless it exists", name)
+			}
+		}
+		return nil
+	}
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+	var resources kube.ResourceList
+	for _, name := range names {
+		data, err := json.Marshal(crds[name])
+		if err != nil {
+			return err
+		}
+		infos, err := cfg.KubeClient.Build(bytes.NewBuffer(data), false)
+		if err != nil {
+			return fmt.Errorf("failed to build %s: %w", name, err)
+		}
+		resources = append(resources, infos...)
+	}
+
+	var applied kube.ResourceList
+	for _, info := range resources {
+		helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager("dockwright")
+		if !templated["CustomResourceDefinition/"+info.Name] {
+			if _, err := helper.Get(info.Namespace, info.Name); err == nil {
+				h.log().Debugf("CRD %s already exists and is left as is", info.Name)
+				continue
+			} else if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to read CRD %s: %w", info.Name, err)
+			}
+			h.log().Infof("📜 Creating CRD %s", info.Name)
+			if _, err := helper.Create(info.Namespace, true, info.Object); err != nil && !apierrors.IsAlreadyExists(err) {
+				return fmt.Errorf("failed to create CRD %s: %w", info.Name, err)
+			}
+			applied = append(applied, info)
+			continue
+		}
+
+		if err := h.setHelmOwnership(info); err != nil {
+			return err
+		}
+		data, err := json.Marshal(info.Object)
+		if err != nil {
+			return err
+		}
+		h.log().Infof("📜 Applying CRD %s", info.Name)
+		force := true
+		if _, err := helper.Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{Force: &force}); err != nil {
+			return fmt.Errorf("failed to apply CRD %s: %w", info.Name, err)
+		}
+		applied = append(applied, info)
+	}
+	if len(applied) == 0 {
+		return nil
+	}
+
+	if err := cfg.KubeClient.Wait(applied, crdEstablishTimeout); err != nil {
+		return fmt.Errorf("CRDs of release %s were not established: %w", h.cfg.ReleaseName(), err)
+	}
+	// Refresh the cached discovery, so the upgrade finds the new kinds
+	discovery, err := cfg.RESTClientGetter.ToDiscoveryClient()
+	if err != nil {
+		return err
+	}
+	discovery.Invalidate()
+	_, _ = discovery.ServerGroups()
+
+	h.log().Infof("✓  %d CRD(s) of %s established", len(applied), h.cfg.ReleaseName())
+	return nil
+}
+
+// setHelmOwnership adds the labels and annotations with which Helm adopts an
+// existing resource into the release.
+func (h *HelmRunner) setHelmOwnership(info *resource.Info) error {
+	accessor, err := meta.Accessor(info.Object)
+	if err != nil {
+		return err
+	}
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["app.kubernetes.io/managed-by"] = "Helm"
+	accessor.SetLabels(labels)
+
+	annotations := accessor.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations["meta.helm.sh/release-name"] = h.cfg.ReleaseName()
+	annotations["meta.helm.sh/release-namespace"] = h.namespace()
+	accessor.SetAnnotations(annotations)
+	return nil
+}
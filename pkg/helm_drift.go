@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// DetectDrift compares the live objects of every release with the manifest
+// Helm last deployed, reporting changes made outside of Dockwright, such as
+// kubectl edits, that the next deploy would silently revert. It also reports
+// which resources the current chart and values would change. It fails if
+// any release has drifted.
+func (h *HelmRunner) DetectDrift() error {
+	drifted := 0
+	err := h.eachRelease(func(r *HelmRunner) error {
+		count, err := r.detectReleaseDrift()
+		drifted += count
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if drifted > 0 {
+		return fmt.Errorf("drift detected in %d resource(s)", drifted)
+	}
+	h.log().Info("✅ No drift detected")
+	return nil
+}
+
+// detectReleaseDrift reports the drift of a single release and returns the
+// number of drifted resources.
+func (h *HelmRunner) detectReleaseDrift() (int, error) {
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return 0, err
+	}
+
+	deployed, err := action.NewGet(cfg).Run(h.cfg.ReleaseName())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get release %s: %w", h.cfg.ReleaseName(), err)
+	}
+	h.log().Infof("🔎 Checking drift of release %s (revision %d)", h.cfg.ReleaseName(), deployed.Version)
+
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(deployed.Manifest), false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse the manifest of release %s: %w", h.cfg.ReleaseName(), err)
+	}
+
+	drifted := 0
+	for _, info := range resources {
+		desired, ok := info.Object.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		desired = desired.DeepCopy()
+		name := fmt.Sprintf("%s/%s", desired.GetKind(), desired.GetName())
+
+		if err := info.Get(); apierrors.IsNotFound(err) {
+			h.log().Warnf("⚠️  %s was deleted from the cluster", name)
+			drifted++
+			continue
+		} else if err != nil {
+			return drifted, fmt.Errorf("failed to get %s: %w", name, err)
+		}
+
+		live, err := runtime.DefaultUnstructuredConverter.ToUnstructured(info.Object)
+		if err != nil {
+			return drifted, err
+		}
+		if desired.GetKind() == "Secret" {
+			// The API server merges stringData into data and never returns it
+			delete(desired.Object, "stringData")
+		}
+		var changes []string
+		diffFields("", desired.Object, live, &changes)
+		if len(changes) > 0 {
+			drifted++
+			h.log().Warnf("⚠️  %s was changed in the cluster:", name)
+			for _, change := range changes {
+				h.log().Warnf("     %s", change)
+			}
+		}
+	}
+
+	h.logPendingChanges(deployed.Manifest)
+	return drifted, nil
+}
+
+// logPendingChanges lists the resources the current chart and values would
+// change compared with the deployed manifest.
+func (h *HelmRunner) logPendingChanges(deployedManifest string) {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		h.log().Warnf("⚠️  Could not render the current chart: %v", err)
+		return
+	}
+	defer rel.cleanup()
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		h.log().Warnf("⚠️  Could not render the current chart: %v", err)
+		return
+	}
+
+	deployed, current := manifestObjects(deployedManifest), manifestObjects(rendered.Manifest)
+	var pending []string
+	for key, obj := range current {
+		if other, ok := deployed[key]; !ok {
+			pending = append(pending, key+" (new)")
+		} else if !reflect.DeepEqual(obj, other) {
+			pending = append(pending, key+" (changed)")
+		}
+	}
+	for key := range deployed {
+		if _, ok := current[key]; !ok {
+			pending = append(pending, key+" (removed)")
+		}
+	}
+	sort.Strings(pending)
+
+	if len(pending) == 0 {
+		h.log().Infof("   The current chart and values match the deployed release")
+		return
+	}
+	h.log().Infof("   ℹ️  Changes not deployed yet: %s", strings.Join(pending, ", "))
+}
+
+// manifestObjects parses a manifest into its objects keyed by kind and name.
+// Annotations added by Dockwright at deploy time are left out, as they change
+// with every deploy. Parsing stops at a malformed document, as the decoder
+// can't skip past it.
+func manifestObjects(manifest string) map[string]map[string]interface{} {
+	objects := make(map[string]map[string]interface{})
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			break // io.EOF, or a malformed document
+		}
+		if obj == nil {
+			continue
+		}
+
+		u := unstructured.Unstructured{Object: obj}
+		if annotations := u.GetAnnotations(); len(annotations) > 0 {
+			for key := range annotations {
+				if strings.HasPrefix(key, "dockwright.io/") {
+					delete(annotations, key)
+				}
+			}
+			if len(annotations) == 0 {
+				annotations = nil
+			}
+			u.SetAnnotations(annotations)
+		}
+		objects[fmt.Sprintf("%s/%s", u.GetKind(), u.GetName())] = obj
+	}
+	return objects
+}
+
+// diffFields records the fields of desired whose value differs in live. Fields
+// only present in live, such as defaults and status set by the cluster, are
+// not drift, and neither are empty maps and lists missing in live, as the API
+// server drops them. Lists of named items, such as containers, are matched by
+// name.
+func diffFields(path string, desired, live interface{}, changes *[]string) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		if len(d) == 0 && live == nil {
+			return
+		}
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			*changes = append(*changes, fmt.Sprintf("%s: %v → %v", path, shortValue(desired), shortValue(live)))
+			return
+		}
+		keys := make([]string, 0, len(d))
+		for key := range d {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			diffFields(joinPath(path, key), d[key], l[key], changes)
+		}
+	case []interface{}:
+		if len(d) == 0 && live == nil {
+			return
+		}
+		l, ok := live.([]interface{})
+		if !ok {
+			*changes = append(*changes, fmt.Sprintf("%s: %v → %v", path, shortValue(desired), shortValue(live)))
+			return
+		}
+		if names := itemNames(d); names != nil {
+			liveByName := make(map[string]interface{})
+			for i, name := range itemNames(l) {
+				liveByName[name] = l[i]
+			}
+			for i, name := range names {
+				diffFields(fmt.Sprintf("%s[%s]", path, name), d[i], liveByName[name], changes)
+			}
+			return
+		}
+		if len(d) != len(l) {
+			*changes = append(*changes, fmt.Sprintf("%s: %d → %d items", path, len(d), len(l)))
+			return
+		}
+		for i := range d {
+			diffFields(fmt.Sprintf("%s[%d]", path, i), d[i], l[i], changes)
+		}
+	default:
+		if desired == nil || equalScalars(desired, live) {
+			return
+		}
+		*changes = append(*changes, fmt.Sprintf("%s: %v → %v", path, shortValue(desired), shortValue(live)))
+	}
+}
+
+// itemNames returns the name of every item if all items are objects with a
+// name, and nil otherwise.
+func itemNames(items []interface{}) []string {
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		name, ok := m["name"].(string)
+		if !ok {
+			return nil
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return names
+}
+
+// equalScalars compares scalars loosely, as the cluster normalises some values:
+// numbers may change type and quantities such as 0.5 CPU become 500m.
+func equalScalars(desired, live interface{}) bool {
+	if fmt.Sprint(desired) == fmt.Sprint(live) {
+		return true
+	}
+	d, dErr := resource.ParseQuantity(fmt.Sprint(desired))
+	l, lErr := resource.ParseQuantity(fmt.Sprint(live))
+	return dErr == nil && lErr == nil && d.Cmp(l) == 0
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// shortValue formats a value for the drift report, abbreviating long values.
+func shortValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	s := fmt.Sprint(v)
+	if len(s) > 60 {
+		s = s[:57] + "..."
+	}
+	return s
+}
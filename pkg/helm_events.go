@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+)
+
+// eventPollInterval is how often the namespace's events are polled.
+const eventPollInterval = 2 * time.Second
+
+// releaseNameAnnotation is set by Helm on every resource of a release.
+const releaseNameAnnotation = "meta.helm.sh/release-name"
+
+// maxOwnerDepth bounds the owner references followed from an event's object
+// to a resource of the release, such as Pod → ReplicaSet → Deployment.
+const maxOwnerDepth = 4
+
+// containerFieldPath extracts the container name from the field path of an
+// event about a container, such as spec.containers{app}.
+var containerFieldPath = regexp.MustCompile(`^spec\.(?:init)?[cC]ontainers\{(.+)\}$`)
+
+// eventWatcher logs the warning events of the release's objects and their
+// pods, such as FailedScheduling or ImagePullBackOff, while a deploy waits
+// for the release to become healthy. For containers in a restart back-off,
+// the last log lines of their previous run are logged too.
+type eventWatcher struct {
+	client    kubernetes.Interface
+	metadata  metadata.Interface
+	mapper    meta.RESTMapper
+	logger    *log.Logger
+	namespace string
+	release   string
+	started   time.Time
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+
+	seen  map[string]bool
+	owned map[string]bool // by apiVersion/kind/name
+}
+
+// watchEvents starts logging the warning events of the release's objects.
+// It never fails the deployment: if the events can't be read, it only warns.
+func (h *HelmRunner) watchEvents(ctx context.Context, cfg *action.Configuration) *eventWatcher {
+	w := &eventWatcher{
+		logger:    h.log(),
+		namespace: h.namespace(),
+		release:   h.cfg.ReleaseName(),
+		started:   time.Now().Add(-10 * time.Second),
+		seen:      map[string]bool{},
+		owned:     map[string]bool{},
+	}
+
+	client, err := cfg.KubernetesClientSet()
+	if err != nil {
+		h.log().Warnf("⚠️  Kubernetes events are not available: %v", err)
+		return w
+	}
+	restConfig, err := cfg.RESTClientGetter.ToRESTConfig()
+	if err == nil {
+		w.metadata, err = metadata.NewForConfig(restConfig)
+	}
+	if err == nil {
+		w.mapper, err = cfg.RESTClientGetter.ToRESTMapper()
+	}
+	if err != nil {
+		h.log().Warnf("⚠️  Kubernetes events are not available: %v", err)
+		return w
+	}
+	w.client = client
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.wg.Add(1)
+	go w.poll(ctx)
+	return w
+}
+
+// stop stops logging events.
+func (w *eventWatcher) stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *eventWatcher) poll(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(eventPollInterval)
+	defer ticker.Stop()
+	for {
+		w.logEvents(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logEvents logs the warning events of the release's objects that occurred
+// since the watcher started and weren't logged yet.
+func (w *eventWatcher) logEvents(ctx context.Context) {
+	events, err := w.client.CoreV1().Events(w.namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=" + corev1.EventTypeWarning})
+	if err != nil {
+		w.logger.Debugf("Could not list events: %v", err)
+		return
+	}
+
+	for _, event := range events.Items {
+		if eventTime(event).Before(w.started) {
+			continue
+		}
+		object := event.InvolvedObject
+		key := strings.Join([]string{object.Kind, object.Name, object.FieldPath, event.Reason}, "/")
+		if w.seen[key] || !w.ownedByRelease(ctx, object.APIVersion, object.Kind, object.Name, 0) {
+			continue
+		}
+		w.seen[key] = true
+
+		w.logger.Warnf("⚠️  %s/%s: %s: %s", object.Kind, object.Name, event.Reason, strings.TrimSpace(event.Message))
+		if event.Reason == "BackOff" && object.Kind == "Pod" {
+			if m := containerFieldPath.FindStringSubmatch(object.FieldPath); m != nil {
+				w.logPreviousRun(ctx, object.Name, m[1])
+			}
+		}
+	}
+}
+
+// ownedByRelease reports whether an object of the namespace is a resource of
+// the release, or is controlled by one, following the controller owner
+// references of pods and the objects between them and the release.
+func (w *eventWatcher) ownedByRelease(ctx context.Context, apiVersion, kind, name string, depth int) bool {
+	key := apiVersion + "/" + kind + "/" + name
+	if owned, ok := w.owned[key]; ok {
+		return owned
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false
+	}
+	mapping, err := w.mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return false
+	}
+	object, err := w.metadata.Resource(mapping.Resource).Namespace(w.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		// Objects that are gone don't come back, others are tried again
+		if apierrors.IsNotFound(err) {
+			w.owned[key] = false
+		} else {
+			w.logger.Debugf("Could not get %s %s: %v", kind, name, err)
+		}
+		return false
+	}
+
+	owned := object.Annotations[releaseNameAnnotation] == w.release
+	if owner := metav1.GetControllerOf(object); !owned && owner != nil && depth < maxOwnerDepth {
+		owned = w.ownedByRelease(ctx, owner.APIVersion, owner.Kind, owner.Name, depth+1)
+	}
+	w.owned[key] = owned
+	return owned
+}
+
+// logPreviousRun logs the last lines of the previous run of a container that
+// keeps crashing.
+func (w *eventWatcher) logPreviousRun(ctx context.Context, pod, container string) {
+	tail := int64(rolloutLogLines)
+	opts := &corev1.PodLogOptions{Container: container, TailLines: &tail, Previous: true}
+	logs, err := w.client.CoreV1().Pods(w.namespace).GetLogs(pod, opts).DoRaw(ctx)
+	if err != nil || len(logs) == 0 {
+		return
+	}
+	w.logger.Warnf("   Last log lines of %s/%s:", pod, container)
+	for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+		w.logger.Warnf("   [%s] %s", pod, line)
+	}
+}
+
+// eventTime returns when the event last occurred.
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.EventTime.IsZero():
+		if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+			return event.Series.LastObservedTime.Time
+		}
+		return event.EventTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	default:
+		return event.FirstTimestamp.Time
+	}
+}
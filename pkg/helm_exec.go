@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/term"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+)
+
+// defaultExecCommand is run by exec when no command is given.
+var defaultExecCommand = []string{"/bin/sh"}
+
+// Exec runs command in a ready pod of the deployed release, attached to the
+// terminal, as kubectl exec -it does. Without a command it opens a shell.
+// container selects the container, which defaults to the pod's default
+// container.
+func (h *HelmRunner) Exec(ctx context.Context, container string, command []string) error {
+	if len(command) == 0 {
+		command = defaultExecCommand
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	target, err := h.releaseTarget(ctx, client)
+	if err != nil {
+		return err
+	}
+	pod, err := readyPod(ctx, client, h.namespace(), target.selector)
+	if err != nil {
+		return err
+	}
+	if container == "" {
+		container = defaultContainer(pod)
+	}
+
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+	req := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    !tty, // a TTY merges stderr into stdout
+			TTY:       tty,
+		}, scheme.ParameterCodec)
+
+	restConfig, err := h.cfg.restConfig()
+	if err != nil {
+		return err
+	}
+	executor, err := remotecommand.NewSPDYExecutor(restConfig, http.MethodPost, req.URL())
+	if err != nil {
+		return fmt.Errorf("failed to set up exec: %w", err)
+	}
+
+	log.Infof("💻 Running %v in pod %s, container %s", command, pod.Name, container)
+
+	opts := remotecommand.StreamOptions{Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr, Tty: tty}
+	if tty {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to set up the terminal: %w", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+		opts.Stderr = nil
+		opts.TerminalSizeQueue = terminalSize(ctx)
+	}
+
+	err = executor.StreamWithContext(ctx, opts)
+	var exitErr exec.CodeExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("command exited with code %d", exitErr.Code)
+	} else if err != nil {
+		return fmt.Errorf("exec failed: %w", err)
+	}
+	return nil
+}
+
+// defaultContainer returns the container kubectl would pick: the one named by
+// the kubectl.kubernetes.io/default-container annotation, or the first one.
+func defaultContainer(pod *corev1.Pod) string {
+	if name := pod.Annotations["kubectl.kubernetes.io/default-container"]; name != "" {
+		return name
+	}
+	return pod.Spec.Containers[0].Name
+}
+
+// terminalSizeQueue reports the size of the local terminal once, so the
+// remote terminal starts with the same size.
+type terminalSizeQueue struct {
+	ctx  context.Context
+	sent bool
+}
+
+func terminalSize(ctx context.Context) *terminalSizeQueue {
+	return &terminalSizeQueue{ctx: ctx}
+}
+
+func (q *terminalSizeQueue) Next() *remotecommand.TerminalSize {
+	if q.sent {
+		<-q.ctx.Done()
+		return nil
+	}
+	q.sent = true
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		return nil
+	}
+	return &remotecommand.TerminalSize{Width: uint16(width), Height: uint16(height)}
+}
@@ -0,0 +1,216 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// hookLogLines is how many of the last log lines of a failed hook are
+// included in the deployment error.
+const hookLogLines = 20
+
+// hookWatcher streams the logs of hook pods, such as database migration jobs,
+// while Helm waits for the hooks to complete. Hooks are found by polling the
+// namespace for the pods and jobs the release being deployed declares as
+// hooks, created after the watcher started, allowing for some clock skew with
+// the cluster. The hooks of other releases sharing the namespace are left out.
+type hookWatcher struct {
+	client    kubernetes.Interface
+	releases  *storage.Storage
+	release   string
+	logger    *log.Logger
+	namespace string
+	started   time.Time
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+
+	mu     sync.Mutex
+	seen   map[string]bool
+	tails  map[string][]string // last log lines per hook pod
+	failed []string            // hook pods that failed
+}
+
+// watchHooks starts streaming hook logs of the release's namespace. It never
+// fails the deployment: if the cluster can't be watched, it only warns.
+func (h *HelmRunner) watchHooks(ctx context.Context, cfg *action.Configuration) *hookWatcher {
+	w := &hookWatcher{releases: cfg.Releases, release: h.cfg.ReleaseName(), logger: h.log(), namespace: h.namespace(),
+		started: time.Now().Add(-10 * time.Second), seen: map[string]bool{}, tails: map[string][]string{}}
+
+	client, err := cfg.KubernetesClientSet()
+	if err != nil {
+		h.log().Warnf("⚠️  Hook logs are not available: %v", err)
+		return w
+	}
+	w.client = client
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.wg.Add(1)
+	go w.poll(ctx)
+	return w
+}
+
+// stop stops watching and returns a description of the failed hooks,
+// including their last log lines, or an empty string if none failed.
+func (w *hookWatcher) stop() string {
+	if w.cancel == nil {
+		return ""
+	}
+	w.cancel()
+	w.wg.Wait()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var report strings.Builder
+	for _, pod := range w.failed {
+		fmt.Fprintf(&report, "hook pod %s failed", pod)
+		if tail := w.tails[pod]; len(tail) > 0 {
+			fmt.Fprintf(&report, ", last log lines:\n  %s", strings.Join(tail, "\n  "))
+		}
+		report.WriteString("\n")
+	}
+	return strings.TrimSuffix(report.String(), "\n")
+}
+
+func (w *hookWatcher) poll(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		for _, pod := range w.hookPods(ctx) {
+			w.follow(ctx, pod)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hookPods returns the running or finished pods of hooks created since the
+// watcher started: pods that are hooks themselves and pods of hook jobs. The
+// hooks are read from the release's latest revision, which Helm stores
+// before it runs them.
+func (w *hookWatcher) hookPods(ctx context.Context) []corev1.Pod {
+	rel, err := w.releases.Last(w.release)
+	if err != nil {
+		return nil
+	}
+	hookPods, hookJobs := map[string]bool{}, map[string]bool{}
+	for _, hook := range rel.Hooks {
+		switch hook.Kind {
+		case "Pod":
+			hookPods[hook.Name] = true
+		case "Job":
+			hookJobs[hook.Name] = true
+		}
+	}
+	if len(hookPods) == 0 && len(hookJobs) == 0 {
+		return nil
+	}
+
+	pods, err := w.client.CoreV1().Pods(w.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	jobs, err := w.client.BatchV1().Jobs(w.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+
+	startedJobs := make(map[string]bool)
+	for _, job := range jobs.Items {
+		if hookJobs[job.Name] && job.CreationTimestamp.After(w.started) {
+			startedJobs[job.Name] = true
+		}
+	}
+
+	var hooks []corev1.Pod
+	for _, pod := range pods.Items {
+		if (!hookPods[pod.Name] && !startedJobs[pod.Labels["job-name"]]) || !pod.CreationTimestamp.After(w.started) {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodPending {
+			continue
+		}
+		hooks = append(hooks, pod)
+	}
+	return hooks
+}
+
+// follow streams the logs of every container of the pod, once per pod.
+func (w *hookWatcher) follow(ctx context.Context, pod corev1.Pod) {
+	w.mu.Lock()
+	if w.seen[pod.Name] {
+		w.mu.Unlock()
+		return
+	}
+	w.seen[pod.Name] = true
+	w.mu.Unlock()
+
+	w.logger.Infof("🪝 Running hook: %s", pod.Name)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for _, container := range pod.Spec.Containers {
+			w.streamLogs(ctx, pod.Name, container.Name)
+		}
+		w.checkFailed(pod.Name)
+	}()
+}
+
+func (w *hookWatcher) streamLogs(ctx context.Context, pod, container string) {
+	stream, err := w.client.CoreV1().Pods(w.namespace).GetLogs(pod, &corev1.PodLogOptions{Container: container, Follow: true}).Stream(ctx)
+	if err != nil {
+		w.logger.Debugf("Could not stream logs of hook %s: %v", pod, err)
+		return
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text()
+		w.logger.Infof("   [%s] %s", pod, line)
+
+		w.mu.Lock()
+		tail := append(w.tails[pod], line)
+		if len(tail) > hookLogLines {
+			tail = tail[len(tail)-hookLogLines:]
+		}
+		w.tails[pod] = tail
+		w.mu.Unlock()
+	}
+}
+
+// checkFailed records the pod as failed if it ended in the Failed phase or
+// one of its containers exited with an error.
+func (w *hookWatcher) checkFailed(name string) {
+	pod, err := w.client.CoreV1().Pods(w.namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	failed := pod.Status.Phase == corev1.PodFailed
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			failed = true
+		}
+	}
+	if failed {
+		w.mu.Lock()
+		w.failed = append(w.failed, name)
+		w.mu.Unlock()
+	}
+}
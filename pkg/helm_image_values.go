@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// imagePattern matches the image fields of the containers in a manifest.
+var imagePattern = regexp.MustCompile(`(?m)^\s*(?:-\s+)?image:\s*["']?([^\s"']+)`)
+
+// CheckImageValues verifies that the chart of the artifact's release uses the
+// image.repository and image.tag values Dockwright injects. A chart reading
+// the image from a different values path would silently deploy its default
+// image instead of the one just built.
+func (h *HelmRunner) CheckImageValues() error {
+	if !h.cfg.ShouldRunDockerBuild() {
+		return nil
+	}
+	// Overriding the image on purpose is the user's call
+	for _, key := range []string{"image.repository", "image.tag"} {
+		if _, ok := h.cfg.HelmSet[key]; ok {
+			return nil
+		}
+		if _, ok := h.cfg.HelmSetString[key]; ok {
+			return nil
+		}
+	}
+	return h.eachRelease((*HelmRunner).checkReleaseImageValues)
+}
+
+// manifestImages returns the container images of a manifest, sorted.
+func manifestImages(manifest string) []string {
+	var images []string
+	for _, match := range imagePattern.FindAllStringSubmatch(manifest, -1) {
+		images = append(images, match[1])
+	}
+	slices.Sort(images)
+	return slices.Compact(images)
+}
+
+func (h *HelmRunner) checkReleaseImageValues() error {
+	if h.companion != nil {
+		return nil
+	}
+	repo, err := h.cfg.ImageRepository()
+	if err != nil {
+		return err
+	}
+
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+	rendered, err := h.render(rel)
+	if err != nil {
+		return err
+	}
+
+	images := manifestImages(rendered.Manifest)
+	expected := repo + ":" + h.cfg.tag()
+	if slices.Contains(images, expected) {
+		return nil
+	}
+	for _, image := range images {
+		if strings.HasPrefix(image, repo+":") || strings.HasPrefix(image, repo+"@") {
+			return fmt.Errorf("chart %s uses image.repository but not image.tag: its containers run %s instead of %s. Please use {{ .Values.image.tag }} as the tag of the container image", rel.chartPath, image, expected)
+		}
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("chart %s renders no container image, so the image %s would not be deployed. Please use {{ .Values.image.repository }}:{{ .Values.image.tag }} as the container image", rel.chartPath, expected)
+	}
+	return fmt.Errorf("chart %s does not use the image.repository and image.tag values: its containers run %s instead of %s, the chart's default image would be deployed. Please use {{ .Values.image.repository }}:{{ .Values.image.tag }} as the container image", rel.chartPath, strings.Join(images, ", "), expected)
+}
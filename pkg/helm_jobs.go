@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// jobLogLines is how many log lines of a failed blocking Job are reported.
+const jobLogLines = 50
+
+// waitForBlockingJobs waits until the Jobs matching deploy.blockingJobs have
+// completed, and fails if one of them fails or they don't complete within
+// helm.timeout. Jobs match by name pattern, such as db-migrate-*, or by a
+// key=value label, and must belong to the deployed release or have been
+// created since the deploy started.
+func (h *HelmRunner) waitForBlockingJobs(ctx context.Context, deployed *release.Release, started time.Time) error {
+	if len(h.cfg.DeployBlockingJobs) == 0 {
+		return nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	namespace := h.namespace()
+	list, err := client.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %w", err)
+	}
+
+	objects := manifestObjects(deployed.Manifest)
+	var jobs []string
+	for _, job := range list.Items {
+		_, inRelease := objects["Job/"+job.Name]
+		if !inRelease && job.CreationTimestamp.Time.Before(started) {
+			continue
+		}
+		if matchesBlockingJob(job, h.cfg.DeployBlockingJobs) {
+			jobs = append(jobs, job.Name)
+		}
+	}
+	if len(jobs) == 0 {
+		h.log().Warnf("⚠️  No Job of release %s matches deploy.blockingJobs %v", h.cfg.ReleaseName(), h.cfg.DeployBlockingJobs)
+		return nil
+	}
+	slices.Sort(jobs)
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout())
+	defer cancel()
+
+	for _, name := range jobs {
+		h.log().Infof("⏳ Waiting for Job/%s", name)
+		err := wait.PollUntilContextCancel(ctx, rolloutPollInterval, true, func(ctx context.Context) (bool, error) {
+			job, err := client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			for _, c := range job.Status.Conditions {
+				if c.Status != corev1.ConditionTrue {
+					continue
+				}
+				switch c.Type {
+				case batchv1.JobComplete:
+					return true, nil
+				case batchv1.JobFailed:
+					return false, fmt.Errorf("%s: %s", c.Reason, c.Message)
+				}
+			}
+			return false, nil
+		})
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("Job/%s did not complete within %s", name, h.timeout())
+		} else if err != nil {
+			err = fmt.Errorf("Job/%s failed: %w", name, err)
+		}
+		if err != nil {
+			if logs := h.jobLogs(client, namespace, name); logs != "" {
+				return fmt.Errorf("%w\n%s", err, logs)
+			}
+			return err
+		}
+		h.log().Infof("✓  Job/%s completed", name)
+	}
+	return nil
+}
+
+// matchesBlockingJob reports whether the Job's name matches one of the
+// patterns, or it carries one of the key=value labels.
+func matchesBlockingJob(job batchv1.Job, patterns []string) bool {
+	for _, pattern := range patterns {
+		if key, value, ok := strings.Cut(pattern, "="); ok {
+			if actual, found := job.Labels[key]; found && actual == value {
+				return true
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern, job.Name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// jobLogs returns the last log lines of the pods of the Job.
+func (h *HelmRunner) jobLogs(client kubernetes.Interface, namespace, name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + name})
+	if err != nil {
+		h.log().Debugf("Could not list the pods of Job/%s: %v", name, err)
+		return ""
+	}
+
+	var b strings.Builder
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			tail := int64(jobLogLines)
+			opts := &corev1.PodLogOptions{Container: c.Name, TailLines: &tail}
+			logs, err := client.CoreV1().Pods(namespace).GetLogs(pod.Name, opts).DoRaw(ctx)
+			if err != nil || len(logs) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "pod %s, container %s (%s):\n", pod.Name, c.Name, pod.Status.Phase)
+			for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+				fmt.Fprintf(&b, "  %s\n", line)
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// removedAPI is an API version of a kind that Kubernetes deprecated and
+// later removed.
+type removedAPI struct {
+	apiVersion   string
+	kinds        []string // all kinds of the API version if empty
+	deprecatedIn string
+	removedIn    string
+	replacement  string
+}
+
+// removedAPIs lists the API versions removed from Kubernetes that charts
+// still commonly use, from the Kubernetes deprecated API migration guide.
+var removedAPIs = []removedAPI{
+	{"extensions/v1beta1", nil, "1.8", "1.22", "apps/v1 or networking.k8s.io/v1"},
+	{"apps/v1beta1", nil, "1.9", "1.16", "apps/v1"},
+	{"apps/v1beta2", nil, "1.9", "1.16", "apps/v1"},
+	{"networking.k8s.io/v1beta1", []string{"Ingress", "IngressClass"}, "1.19", "1.22", "networking.k8s.io/v1"},
+	{"rbac.authorization.k8s.io/v1beta1", nil, "1.17", "1.22", "rbac.authorization.k8s.io/v1"},
+	{"apiextensions.k8s.io/v1beta1", nil, "1.16", "1.22", "apiextensions.k8s.io/v1"},
+	{"admissionregistration.k8s.io/v1beta1", nil, "1.16", "1.22", "admissionregistration.k8s.io/v1"},
+	{"scheduling.k8s.io/v1beta1", nil, "1.14", "1.22", "scheduling.k8s.io/v1"},
+	{"certificates.k8s.io/v1beta1", nil, "1.19", "1.22", "certificates.k8s.io/v1"},
+	{"coordination.k8s.io/v1beta1", nil, "1.14", "1.22", "coordination.k8s.io/v1"},
+	{"batch/v1beta1", []string{"CronJob"}, "1.21", "1.25", "batch/v1"},
+	{"policy/v1beta1", []string{"PodDisruptionBudget"}, "1.21", "1.25", "policy/v1"},
+	{"policy/v1beta1", []string{"PodSecurityPolicy"}, "1.21", "1.25", "Pod Security Admission"},
+	{"discovery.k8s.io/v1beta1", nil, "1.21", "1.25", "discovery.k8s.io/v1"},
+	{"events.k8s.io/v1beta1", nil, "1.19", "1.25", "events.k8s.io/v1"},
+	{"node.k8s.io/v1beta1", nil, "1.20", "1.25", "node.k8s.io/v1"},
+	{"autoscaling/v2beta1", nil, "1.22", "1.25", "autoscaling/v2"},
+	{"autoscaling/v2beta2", nil, "1.23", "1.26", "autoscaling/v2"},
+	{"flowcontrol.apiserver.k8s.io/v1beta1", nil, "1.23", "1.26", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta2", nil, "1.26", "1.29", "flowcontrol.apiserver.k8s.io/v1"},
+	{"flowcontrol.apiserver.k8s.io/v1beta3", nil, "1.29", "1.32", "flowcontrol.apiserver.k8s.io/v1"},
+	{"storage.k8s.io/v1beta1", []string{"CSIStorageCapacity"}, "1.24", "1.27", "storage.k8s.io/v1"},
+}
+
+// CheckKubeVersion compares the cluster's Kubernetes version with the
+// kubeVersion constraint of each release's chart, and checks the rendered
+// manifests for API versions the cluster has removed or deprecated. Removed
+// APIs fail the check, deprecated ones only warn.
+func (h *HelmRunner) CheckKubeVersion() error {
+	if h.cfg.DryRun {
+		h.log().Info("⏭️  Skipping Kubernetes version check in dry-run mode")
+		return nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return fmt.Errorf("failed to get the cluster's Kubernetes version: %w", err)
+	}
+	kubeVersion, err := chartutil.ParseKubeVersion(info.GitVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse the cluster's Kubernetes version '%s': %w", info.GitVersion, err)
+	}
+	h.log().Infof("☸️  Cluster runs Kubernetes %s", kubeVersion.Version)
+
+	return h.eachRelease(func(r *HelmRunner) error {
+		r.kubeVersion = kubeVersion
+		return r.checkReleaseKubeVersion()
+	})
+}
+
+func (h *HelmRunner) checkReleaseKubeVersion() error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	if constraint := rel.chart.Metadata.KubeVersion; constraint != "" && !chartutil.IsCompatibleRange(constraint, h.kubeVersion.Version) {
+		return fmt.Errorf("chart %s requires Kubernetes %s, but the cluster runs %s", rel.chart.Name(), constraint, h.kubeVersion.Version)
+	}
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		return err
+	}
+	manifests := []string{rendered.Manifest}
+	for _, hook := range rendered.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+
+	cluster, err := semver.NewVersion(h.kubeVersion.Version)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for _, manifest := range manifests {
+		objects := manifestObjects(manifest)
+		for _, name := range slices.Sorted(maps.Keys(objects)) {
+			apiVersion, _ := objects[name]["apiVersion"].(string)
+			kind, _ := objects[name]["kind"].(string)
+			api, ok := findRemovedAPI(apiVersion, kind)
+			if !ok {
+				continue
+			}
+			switch {
+			case !cluster.LessThan(semver.MustParse(api.removedIn)):
+				removed = append(removed, fmt.Sprintf("%s uses %s, removed in Kubernetes %s. Please use %s", name, apiVersion, api.removedIn, api.replacement))
+			case !cluster.LessThan(semver.MustParse(api.deprecatedIn)):
+				h.log().Warnf("⚠️  %s uses %s, which is deprecated and removed in Kubernetes %s. Please use %s", name, apiVersion, api.removedIn, api.replacement)
+			}
+		}
+	}
+	if len(removed) > 0 {
+		return fmt.Errorf("release %s uses APIs the cluster no longer serves:\n%s", h.cfg.ReleaseName(), strings.Join(removed, "\n"))
+	}
+	return nil
+}
+
+// findRemovedAPI returns the removal details of apiVersion and kind, if it
+// is a removed API.
+func findRemovedAPI(apiVersion, kind string) (removedAPI, bool) {
+	for _, api := range removedAPIs {
+		if api.apiVersion == apiVersion && (len(api.kinds) == 0 || slices.Contains(api.kinds, kind)) {
+			return api, true
+		}
+	}
+	return removedAPI{}, false
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/lint/support"
+)
+
+// Lint lints the chart that would be deployed, as helm lint does, using the
+// same values files and overrides as the deployment, so that template errors
+// are caught before anything is built or pushed.
+func (h *HelmRunner) Lint() error {
+	return h.eachRelease((*HelmRunner).lintRelease)
+}
+
+func (h *HelmRunner) lintRelease() error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	h.log().Infof("🔎 Linting chart: %s", rel.chartPath)
+
+	lint := action.NewLint()
+	lint.Namespace = h.namespace()
+	result := lint.Run([]string{rel.chartPath}, rel.values)
+
+	var problems []string
+	for _, msg := range result.Messages {
+		switch {
+		case msg.Severity >= support.ErrorSev:
+			problems = append(problems, "  - "+msg.Error())
+		case msg.Severity == support.WarningSev:
+			h.log().Warnf("⚠️  %s", msg.Error())
+		}
+	}
+	if len(problems) == 0 && len(result.Errors) > 0 {
+		for _, err := range result.Errors {
+			problems = append(problems, "  - "+err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("helm lint failed for chart %s:\n%s", rel.chartPath, strings.Join(problems, "\n"))
+	}
+	return nil
+}
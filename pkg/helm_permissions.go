@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+)
+
+// deployVerbs are the verbs a deploy needs on every resource it applies.
+var deployVerbs = []string{"create", "update", "patch"}
+
+// CheckPermissions verifies with SelfSubjectAccessReviews that the current
+// kube identity may create and update every kind of resource the releases
+// render, so missing RBAC permissions fail the deploy before anything is
+// built or pushed.
+func (h *HelmRunner) CheckPermissions(ctx context.Context) error {
+	if h.cfg.DryRun {
+		h.log().Info("⏭️  Skipping permission check in dry-run mode")
+		return nil
+	}
+	return h.eachRelease(func(r *HelmRunner) error {
+		return r.checkReleasePermissions(ctx)
+	})
+}
+
+func (h *HelmRunner) checkReleasePermissions(ctx context.Context) error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		return err
+	}
+	manifests := []string{rendered.Manifest}
+	for _, hook := range rendered.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	groups, err := restmapper.GetAPIGroupResources(client.Discovery())
+	if err != nil {
+		return fmt.Errorf("failed to discover the cluster's API resources: %w", err)
+	}
+	mapper := restmapper.NewDiscoveryRESTMapper(groups)
+
+	// Helm stores the release state as secrets in the release namespace
+	resources := map[string]*meta.RESTMapping{
+		"secrets": {Resource: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}, Scope: meta.RESTScopeNamespace},
+	}
+	if h.cfg.KubernetesCreateNamespace {
+		resources["namespaces"] = &meta.RESTMapping{Resource: schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, Scope: meta.RESTScopeRoot}
+	}
+	for _, manifest := range manifests {
+		for _, obj := range manifestObjects(manifest) {
+			apiVersion, _ := obj["apiVersion"].(string)
+			kind, _ := obj["kind"].(string)
+			gv, err := schema.ParseGroupVersion(apiVersion)
+			if err != nil || kind == "" {
+				continue
+			}
+			mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+			if err != nil {
+				// Kinds of CRDs installed by the release itself are not known yet
+				h.log().Debugf("Skipping permission check of %s: %v", kind, err)
+				continue
+			}
+			resources[groupResource(mapping.Resource)] = mapping
+		}
+	}
+
+	var denied []string
+	for _, name := range slices.Sorted(maps.Keys(resources)) {
+		mapping := resources[name]
+		namespace := h.namespace()
+		if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+			namespace = ""
+		}
+		for _, verb := range deployVerbs {
+			allowed, err := canI(ctx, client, verb, mapping.Resource, namespace)
+			if err != nil {
+				return err
+			}
+			if !allowed {
+				denied = append(denied, fmt.Sprintf("%s %s", verb, name))
+			}
+		}
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("the current kube identity is not allowed to %s in namespace %s. Please ask a cluster admin for the missing RBAC permissions", strings.Join(denied, ", "), h.namespace())
+	}
+	h.log().Infof("✅ Allowed to deploy %d resource kind(s) of release %s", len(resources), h.cfg.ReleaseName())
+	return nil
+}
+
+// canI asks the API server whether the current identity may perform verb on
+// resource in namespace, as kubectl auth can-i does.
+func canI(ctx context.Context, client kubernetes.Interface, verb string, resource schema.GroupVersionResource, namespace string) (bool, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     resource.Group,
+				Resource:  resource.Resource,
+			},
+		},
+	}
+	result, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access to %s: %w", groupResource(resource), err)
+	}
+	return result.Status.Allowed, nil
+}
+
+// groupResource returns the resource name as kubectl prints it, such as
+// deployments.apps.
+func groupResource(resource schema.GroupVersionResource) string {
+	return resource.GroupResource().String()
+}
@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CheckNodePlatforms compares the platforms of the artifact's image with the
+// platforms of the schedulable nodes of each environment's cluster, and warns
+// when no node can run the image, such as an amd64-only image headed for an
+// arm64-only cluster. This is a warning, as node pools may be added before
+// the pods are scheduled.
+func (h *HelmRunner) CheckNodePlatforms(ctx context.Context) error {
+	if h.cfg.DryRun {
+		h.log().Info("⏭️  Skipping node platform check in dry-run mode")
+		return nil
+	}
+
+	platforms, err := h.imagePlatforms(ctx)
+	if err != nil {
+		h.log().Debugf("Could not determine the image's platforms: %v", err)
+		return nil
+	}
+	if len(platforms) == 0 {
+		return nil
+	}
+
+	var unrunnable []string
+	for _, cfg := range h.cfg.PerEnvironment() {
+		runnable, err := NewHelmRunner(cfg).checkNodePlatforms(ctx, platforms)
+		if err != nil {
+			return err
+		}
+		if !runnable {
+			unrunnable = append(unrunnable, cfg.KubernetesContext)
+		}
+	}
+	if len(unrunnable) > 0 {
+		return warningf("no node of context(s) %s can run the image's platforms %s", strings.Join(unrunnable, ", "), strings.Join(platforms, ", "))
+	}
+	return nil
+}
+
+// checkNodePlatforms logs which nodes of the cluster can run the image, and
+// returns false if none of them can.
+func (h *HelmRunner) checkNodePlatforms(ctx context.Context, platforms []string) (bool, error) {
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return false, err
+	}
+	nodes, err := client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		h.log().Debugf("Could not list the cluster's nodes: %v", err)
+		return true, nil
+	}
+
+	nodesByPlatform := map[string]int{}
+	for _, node := range nodes.Items {
+		if !node.Spec.Unschedulable {
+			nodesByPlatform[nodePlatform(node)]++
+		}
+	}
+	if len(nodesByPlatform) == 0 {
+		return true, nil
+	}
+
+	var runnable, unrunnable []string
+	for _, platform := range slices.Sorted(maps.Keys(nodesByPlatform)) {
+		description := fmt.Sprintf("%d %s", nodesByPlatform[platform], platform)
+		if slices.Contains(platforms, platform) {
+			runnable = append(runnable, description)
+		} else {
+			unrunnable = append(unrunnable, description)
+		}
+	}
+
+	kubeContext := h.cfg.KubernetesContext
+	switch {
+	case len(runnable) == 0:
+		h.log().Warnf("⚠️  The image is built for %s, but context %s has no schedulable node that can run it: %s node(s)", strings.Join(platforms, ", "), kubeContext, strings.Join(unrunnable, ", "))
+	case len(unrunnable) > 0:
+		h.log().Infof("ℹ️  The image can only run on the %s node(s) of context %s, not on its %s node(s)", strings.Join(runnable, ", "), kubeContext, strings.Join(unrunnable, ", "))
+	default:
+		h.log().Infof("✅ The image can run on all %s node(s) of context %s", strings.Join(runnable, ", "), kubeContext)
+	}
+	return len(runnable) > 0, nil
+}
+
+// imagePlatforms returns the os/arch platforms of the artifact's image: the
+// configured build platforms, the builder's own platform when building for
+// it, or the platforms of the already pushed image otherwise.
+func (h *HelmRunner) imagePlatforms(ctx context.Context) ([]string, error) {
+	if h.cfg.ShouldRunDockerBuild() {
+		if len(h.cfg.DockerPlatforms) > 0 {
+			platforms := make([]string, len(h.cfg.DockerPlatforms))
+			for i, platform := range h.cfg.DockerPlatforms {
+				platforms[i] = osArch(platform)
+			}
+			return platforms, nil
+		}
+		if h.cfg.DockerBuilder == BuilderDocker {
+			out, err := command(ctx, "docker", "version", "--format", "{{.Server.Os}}/{{.Server.Arch}}").Output()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the Docker daemon's platform: %w", err)
+			}
+			return []string{strings.TrimSpace(string(out))}, nil
+		}
+		return []string{runtime.GOOS + "/" + runtime.GOARCH}, nil
+	}
+
+	repo, err := h.cfg.ImageRepository()
+	if err != nil {
+		return nil, err
+	}
+	image := repo + ":" + h.cfg.tag()
+	var out []byte
+	if h.cfg.DockerBuilder == BuilderDocker {
+		out, err = command(ctx, "docker", "buildx", "imagetools", "inspect", "--raw", image).Output()
+	} else {
+		out, err = command(ctx, "skopeo", "inspect", "--raw", "docker://"+image).Output()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect %s: %w", image, err)
+	}
+
+	var index struct {
+		Manifests []struct {
+			Platform struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(out, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse the manifest of %s: %w", image, err)
+	}
+	var platforms []string
+	for _, m := range index.Manifests {
+		if m.Platform.OS == "" || m.Platform.OS == "unknown" {
+			continue // attestations
+		}
+		platforms = append(platforms, m.Platform.OS+"/"+m.Platform.Architecture)
+	}
+	if len(index.Manifests) == 0 {
+		// A single-platform image: its platform is in the image config
+		platform, err := h.imageConfigPlatform(ctx, image)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, platform)
+	}
+	return platforms, nil
+}
+
+// imageConfigPlatform returns the os/arch platform recorded in the config of
+// a single-platform image.
+func (h *HelmRunner) imageConfigPlatform(ctx context.Context, image string) (string, error) {
+	var out []byte
+	var err error
+	if h.cfg.DockerBuilder == BuilderDocker {
+		out, err = command(ctx, "docker", "buildx", "imagetools", "inspect", "--format", "{{json .Image}}", image).Output()
+	} else {
+		out, err = command(ctx, "skopeo", "inspect", "docker://"+image).Output()
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect the config of %s: %w", image, err)
+	}
+
+	// imagetools prints the OCI image config, skopeo its own summary
+	var config struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	}
+	if err := json.Unmarshal(out, &config); err != nil {
+		return "", fmt.Errorf("failed to parse the config of %s: %w", image, err)
+	}
+	if config.OS == "" || config.Architecture == "" {
+		return "", fmt.Errorf("the config of %s records no platform", image)
+	}
+	return config.OS + "/" + config.Architecture, nil
+}
+
+// nodePlatform returns the os/arch platform of a node.
+func nodePlatform(node corev1.Node) string {
+	os, arch := node.Labels[corev1.LabelOSStable], node.Labels[corev1.LabelArchStable]
+	if os == "" {
+		os = node.Status.NodeInfo.OperatingSystem
+	}
+	if arch == "" {
+		arch = node.Status.NodeInfo.Architecture
+	}
+	return os + "/" + arch
+}
+
+// osArch strips the variant from a platform such as linux/arm/v7.
+func osArch(platform string) string {
+	parts := strings.SplitN(platform, "/", 3)
+	if len(parts) < 2 {
+		return platform
+	}
+	return parts[0] + "/" + parts[1]
+}
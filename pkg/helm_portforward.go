@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// privilegedPortOffset is added to a privileged Service port to get the
+// default local port, so that 80 is forwarded from 8080 and 443 from 8443.
+const privilegedPortOffset = 8000
+
+// PortForward forwards a local port to a ready pod of the deployed release
+// until ctx is cancelled, as kubectl port-forward does. ports is
+// "local:remote", a single port used for both, or empty for the port of the
+// release's Service. The remote port is a port of the release's Service if
+// it has one, and is translated to the pod's target port.
+func (h *HelmRunner) PortForward(ctx context.Context, ports string) error {
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	target, err := h.releaseTarget(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	localPort, remotePort, err := parsePortPair(ports)
+	if err != nil {
+		return err
+	}
+	if remotePort == 0 {
+		if target.service == nil || len(target.service.Spec.Ports) == 0 {
+			return fmt.Errorf("release %s has no Service to take the port from. Please give the port as local:remote", h.cfg.ReleaseName())
+		}
+		remotePort = int(target.service.Spec.Ports[0].Port)
+		localPort = defaultLocalPort(remotePort)
+	}
+
+	pod, err := readyPod(ctx, client, h.namespace(), target.selector)
+	if err != nil {
+		return err
+	}
+	podPort, err := target.podPort(pod, remotePort)
+	if err != nil {
+		return err
+	}
+
+	restConfig, err := h.cfg.restConfig()
+	if err != nil {
+		return err
+	}
+	transport, upgrader, err := spdy.RoundTripperFor(restConfig)
+	if err != nil {
+		return err
+	}
+	url := client.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stop := make(chan struct{})
+	ready := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		close(stop)
+	}()
+	forwarder, err := portforward.New(dialer, []string{fmt.Sprintf("%d:%d", localPort, podPort)}, stop, ready, h.cfg.toolOutput(), os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to set up port forwarding: %w", err)
+	}
+	go func() {
+		<-ready
+		if forwarded, err := forwarder.GetPorts(); err == nil && len(forwarded) > 0 {
+			localPort = int(forwarded[0].Local)
+		}
+		log.Infof("🔌 Forwarding localhost:%d → %s:%d (pod %s). Press Ctrl+C to stop", localPort, target, remotePort, pod.Name)
+	}()
+
+	if err := forwarder.ForwardPorts(); err != nil {
+		return fmt.Errorf("port forwarding failed: %w", err)
+	}
+	return nil
+}
+
+// defaultLocalPort returns the local port a Service port is forwarded from
+// when none is given: the same port, unless binding it needs root.
+func defaultLocalPort(port int) int {
+	if port < 1024 {
+		return port + privilegedPortOffset
+	}
+	return port
+}
+
+// releaseTarget is the Service of a release, or its first workload if it has
+// none, whose pods commands like port-forward and exec connect to.
+type releaseTarget struct {
+	name     string
+	service  *corev1.Service
+	selector string
+}
+
+func (t releaseTarget) String() string {
+	return t.name
+}
+
+// releaseTarget finds the Service or workload of the deployed release.
+func (h *HelmRunner) releaseTarget(ctx context.Context, client kubernetes.Interface) (*releaseTarget, error) {
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return nil, err
+	}
+	deployed, err := action.NewGet(cfg).Run(h.cfg.ReleaseName())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release %s: %w", h.cfg.ReleaseName(), err)
+	}
+
+	objects := manifestObjects(deployed.Manifest)
+	names := slices.Sorted(maps.Keys(objects))
+	for _, name := range names {
+		if kind, service, _ := strings.Cut(name, "/"); kind == "Service" {
+			svc, err := client.CoreV1().Services(h.namespace()).Get(ctx, service, metav1.GetOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get Service %s: %w", service, err)
+			}
+			if len(svc.Spec.Selector) == 0 {
+				continue
+			}
+			return &releaseTarget{name: name, service: svc, selector: labels.SelectorFromSet(svc.Spec.Selector).String()}, nil
+		}
+	}
+	for _, name := range names {
+		kind, workloadName, _ := strings.Cut(name, "/")
+		switch kind {
+		case "Deployment", "StatefulSet", "DaemonSet":
+			selector, err := workloadSelector(ctx, client, workload{kind: kind, name: workloadName, namespace: h.namespace()})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get %s: %w", name, err)
+			}
+			return &releaseTarget{name: name, selector: selector}, nil
+		}
+	}
+	return nil, fmt.Errorf("release %s has no Service or workload with pods", h.cfg.ReleaseName())
+}
+
+// podPort translates a port of the target's Service into the port of the
+// pod it routes to. Without a Service, the port is the pod's port.
+func (t releaseTarget) podPort(pod *corev1.Pod, port int) (int, error) {
+	if t.service == nil {
+		return port, nil
+	}
+	for _, servicePort := range t.service.Spec.Ports {
+		if int(servicePort.Port) != port {
+			continue
+		}
+		switch {
+		case servicePort.TargetPort.Type == intstr.String:
+			for _, c := range pod.Spec.Containers {
+				for _, containerPort := range c.Ports {
+					if containerPort.Name == servicePort.TargetPort.StrVal {
+						return int(containerPort.ContainerPort), nil
+					}
+				}
+			}
+			return 0, fmt.Errorf("pod %s has no port named %s", pod.Name, servicePort.TargetPort.StrVal)
+		case servicePort.TargetPort.IntVal != 0:
+			return int(servicePort.TargetPort.IntVal), nil
+		default:
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("%s has no port %d", t.name, port)
+}
+
+// parsePortPair parses "local:remote", ":remote" or a single port used for
+// both. An empty value returns zero ports.
+func parsePortPair(value string) (int, int, error) {
+	if value == "" {
+		return 0, 0, nil
+	}
+	local, remote, ok := strings.Cut(value, ":")
+	if !ok {
+		remote = local
+	}
+	localPort := 0 // an empty local port picks a free one
+	if local != "" {
+		var err error
+		if localPort, err = strconv.Atoi(local); err != nil || localPort < 0 || localPort > 65535 {
+			return 0, 0, fmt.Errorf("invalid local port '%s'", local)
+		}
+	}
+	remotePort, err := strconv.Atoi(remote)
+	if err != nil || remotePort <= 0 || remotePort > 65535 {
+		return 0, 0, fmt.Errorf("invalid remote port '%s'", remote)
+	}
+	return localPort, remotePort, nil
+}
@@ -0,0 +1,145 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// PublishChart packages the project-local chart and pushes it to
+// helm.publishRepository. Unless a version is given, the chart version is
+// derived from git: a semver tag on HEAD is used as is, otherwise the
+// version from Chart.yaml is suffixed with the short commit SHA.
+func (h *HelmRunner) PublishChart(version string) error {
+	if h.cfg.HelmChartPath == "" {
+		return fmt.Errorf("publishing requires a project-local chart, please set helm.chartPath")
+	}
+	if h.cfg.HelmPublishRepository == "" {
+		return fmt.Errorf("publishing requires helm.publishRepository to be set")
+	}
+
+	if version == "" {
+		var err error
+		if version, err = h.chartVersionFromGit(); err != nil {
+			return err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "dockwright-package-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	settings := h.settings()
+	pkg := action.NewPackage()
+	pkg.Version = version
+	pkg.Destination = dir
+	pkg.DependencyUpdate = h.cfg.HelmUpdateDependencies
+	pkg.RepositoryConfig = settings.RepositoryConfig
+	pkg.RepositoryCache = settings.RepositoryCache
+
+	log.Infof("📦 Packaging chart %s as version %s", h.cfg.HelmChartPath, version)
+	archive, err := pkg.Run(h.cfg.HelmChartPath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to package chart %s: %w", h.cfg.HelmChartPath, err)
+	}
+
+	if h.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would push %s to %s", archive, h.cfg.HelmPublishRepository)
+		return nil
+	}
+
+	log.Infof("🚀 Pushing chart to: %s", h.cfg.HelmPublishRepository)
+	if registry.IsOCI(h.cfg.HelmPublishRepository) {
+		err = h.pushOCI(archive)
+	} else {
+		err = h.uploadToRepository(archive)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("✓  Successfully published chart version %s", version)
+	return nil
+}
+
+// chartVersionFromGit derives the chart version from the git tag or commit of HEAD.
+func (h *HelmRunner) chartVersionFromGit() (string, error) {
+	if tag := gitExactTag(); tag != "" {
+		if v, err := semver.StrictNewVersion(strings.TrimPrefix(tag, "v")); err == nil {
+			return v.String(), nil
+		}
+		log.Warnf("⚠️  Tag %s is not a semantic version, using the commit instead", tag)
+	}
+
+	ch, err := loader.LoadDir(h.cfg.HelmChartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load chart %s: %w", h.cfg.HelmChartPath, err)
+	}
+	commit, err := gitCommit()
+	if err != nil {
+		return "", err
+	}
+
+	base, err := semver.NewVersion(ch.Metadata.Version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version '%s' in %s/Chart.yaml: %w", ch.Metadata.Version, h.cfg.HelmChartPath, err)
+	}
+	// The g prefix keeps an all-digit SHA with a leading 0 a valid prerelease
+	return fmt.Sprintf("%d.%d.%d-g%s", base.Major(), base.Minor(), base.Patch(), commit[:7]), nil
+}
+
+// pushOCI pushes the chart archive to the OCI registry.
+func (h *HelmRunner) pushOCI(archive string) error {
+	registryClient, err := h.registryClient()
+	if err != nil {
+		return err
+	}
+
+	push := action.NewPushWithOpts(action.WithPushConfig(&action.Configuration{RegistryClient: registryClient}))
+	push.Settings = h.settings()
+	if _, err := push.Run(archive, h.cfg.HelmPublishRepository); err != nil {
+		return fmt.Errorf("failed to push chart to %s: %w", h.cfg.HelmPublishRepository, err)
+	}
+	return nil
+}
+
+// uploadToRepository uploads the chart archive with the ChartMuseum API,
+// which is also served by Harbor and others. Credentials are read from
+// HELM_REPOSITORY_USERNAME and HELM_REPOSITORY_PASSWORD when set.
+func (h *HelmRunner) uploadToRepository(archive string) error {
+	content, err := os.ReadFile(archive)
+	if err != nil {
+		return err
+	}
+
+	url := strings.TrimSuffix(h.cfg.HelmPublishRepository, "/") + "/api/charts"
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if username := os.Getenv("HELM_REPOSITORY_USERNAME"); username != "" {
+		req.SetBasicAuth(username, os.Getenv("HELM_REPOSITORY_PASSWORD"))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload chart to %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to upload chart to %s: %s", url, resp.Status)
+	}
+	return nil
+}
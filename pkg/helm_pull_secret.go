@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ensurePullSecret creates or updates the docker-registry Secret named by
+// kubernetes.imagePullSecret in the release's namespace from the registry
+// credentials, so pods in a fresh namespace can pull the image.
+func (h *HelmRunner) ensurePullSecret(ctx context.Context) error {
+	name := h.cfg.KubernetesImagePullSecret
+	if name == "" || h.companion != nil {
+		return nil
+	}
+	namespace := h.namespace()
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would create or update image pull secret %s in namespace %s", name, namespace)
+		return nil
+	}
+
+	dockerConfig, err := h.dockerConfigJSON()
+	if err != nil {
+		return err
+	}
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	secrets := client.CoreV1().Secrets(namespace)
+
+	secret, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		h.log().Infof("🔑 Creating image pull secret %s for %s", name, registryHost(h.cfg.DockerHost))
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{"app.kubernetes.io/managed-by": "dockwright"},
+			},
+			Type: corev1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig},
+		}
+		if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create image pull secret %s: %w", name, err)
+		}
+	case err != nil:
+		return fmt.Errorf("failed to get image pull secret %s: %w", name, err)
+	case secret.Type != corev1.SecretTypeDockerConfigJson:
+		return fmt.Errorf("secret %s in namespace %s is of type %s, not %s. Please choose another kubernetes.imagePullSecret", name, namespace, secret.Type, corev1.SecretTypeDockerConfigJson)
+	case !bytes.Equal(secret.Data[corev1.DockerConfigJsonKey], dockerConfig):
+		h.log().Infof("🔑 Updating image pull secret %s for %s", name, registryHost(h.cfg.DockerHost))
+		secret.Data = map[string][]byte{corev1.DockerConfigJsonKey: dockerConfig}
+		if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("failed to update image pull secret %s: %w", name, err)
+		}
+	default:
+		h.log().Debugf("Image pull secret %s is up to date", name)
+	}
+	return nil
+}
+
+// dockerConfigJSON returns the registry credentials in the format of a
+// kubernetes.io/dockerconfigjson Secret.
+func (h *HelmRunner) dockerConfigJSON() ([]byte, error) {
+	username, password, err := registryCredentials()
+	if err != nil {
+		return nil, err
+	}
+	type auth struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Auth     string `json:"auth"`
+	}
+	config := map[string]map[string]auth{
+		"auths": {
+			registryHost(h.cfg.DockerHost): {
+				Username: username,
+				Password: password,
+				Auth:     base64.StdEncoding.EncodeToString([]byte(username + ":" + password)),
+			},
+		},
+	}
+	return json.Marshal(config)
+}
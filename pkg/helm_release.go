@@ -0,0 +1,257 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Rollback rolls the release back to the given revision, or to the previous
+// revision if revision is empty.
+func (h *HelmRunner) Rollback(revision string) error {
+	target := revision
+	if target == "" {
+		target = "previous revision"
+	}
+	h.log().Infof("⏪ Rolling back release %s to %s", h.cfg.ReleaseName(), target)
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would roll back release %s in namespace %s", h.cfg.ReleaseName(), h.namespace())
+		return nil
+	}
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+
+	rollback := action.NewRollback(cfg)
+	if revision != "" {
+		if rollback.Version, err = strconv.Atoi(revision); err != nil {
+			return fmt.Errorf("invalid revision '%s': %w", revision, err)
+		}
+	}
+	rollback.Wait = h.cfg.HelmWait
+	rollback.CleanupOnFail = h.cfg.HelmCleanupOnFail
+	rollback.Timeout = h.timeout()
+
+	if err := rollback.Run(h.cfg.ReleaseName()); err != nil {
+		return fmt.Errorf("helm rollback failed: %w", err)
+	}
+
+	h.log().Infof("✓  Successfully rolled back %s to %s", h.cfg.ReleaseName(), target)
+	return nil
+}
+
+// Uninstall removes the release and, if requested, the namespace it lives in.
+func (h *HelmRunner) Uninstall(deleteNamespace bool) error {
+	if deleteNamespace && h.cfg.KubernetesNamespace == "" {
+		return fmt.Errorf("--delete-namespace requires kubernetes.namespace to be set")
+	}
+
+	h.log().Infof("🗑️  Uninstalling release: %s", h.cfg.ReleaseName())
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would uninstall release %s from namespace %s", h.cfg.ReleaseName(), h.namespace())
+	} else {
+		uninstall := action.NewUninstall(cfg)
+		uninstall.Wait = h.cfg.HelmWait
+		uninstall.Timeout = h.timeout()
+
+		if _, err := uninstall.Run(h.cfg.ReleaseName()); err != nil {
+			return fmt.Errorf("helm uninstall failed: %w", err)
+		}
+		h.log().Infof("✓  Successfully uninstalled %s", h.cfg.ReleaseName())
+	}
+
+	if deleteNamespace {
+		return h.deleteNamespace(cfg)
+	}
+	return nil
+}
+
+// ensureNamespace creates the release's namespace with the configured labels
+// and annotations if it doesn't exist yet and kubernetes.createNamespace is
+// set. Existing namespaces are left as they are.
+func (h *HelmRunner) ensureNamespace(ctx context.Context) error {
+	if !h.cfg.KubernetesCreateNamespace {
+		return nil
+	}
+	namespace := h.namespace()
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would create namespace %s if it does not exist", namespace)
+		return nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	h.log().Infof("📁 Creating namespace: %s", namespace)
+	if _, err := client.CoreV1().Namespaces().Create(ctx, h.namespaceObject(), metav1.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+	}
+	for _, key := range slices.Sorted(maps.Keys(h.cfg.KubernetesNamespaceLabels)) {
+		h.log().Infof("   Label: %s=%s", key, h.cfg.KubernetesNamespaceLabels[key])
+	}
+	return nil
+}
+
+// namespaceObject returns the release's namespace as kubernetes.createNamespace
+// creates it.
+func (h *HelmRunner) namespaceObject() *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        h.namespace(),
+		Labels:      h.cfg.KubernetesNamespaceLabels,
+		Annotations: h.cfg.KubernetesNamespaceAnnotations,
+	}}
+}
+
+func (h *HelmRunner) deleteNamespace(cfg *action.Configuration) error {
+	h.log().Infof("🗑️  Deleting namespace: %s", h.cfg.KubernetesNamespace)
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would delete namespace %s", h.cfg.KubernetesNamespace)
+		return nil
+	}
+
+	client, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	if err := client.CoreV1().Namespaces().Delete(context.Background(), h.cfg.KubernetesNamespace, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("namespace deletion failed: %w", err)
+	}
+
+	h.log().Infof("✓  Successfully deleted namespace %s", h.cfg.KubernetesNamespace)
+	return nil
+}
+
+// HelmRevision is a single revision of a release as reported by helm history.
+type HelmRevision struct {
+	Revision    int    `json:"revision"`
+	Updated     string `json:"updated"`
+	Status      string `json:"status"`
+	Chart       string `json:"chart"`
+	AppVersion  string `json:"app_version"`
+	Description string `json:"description"`
+}
+
+// releaseHistory returns the revisions of the release, oldest first, keeping
+// the latest max revisions, or all of them if max is 0. Helm's History action
+// ignores its Max and returns the revisions in the order the storage driver
+// lists them, where revision 10 comes before revision 2.
+func releaseHistory(cfg *action.Configuration, name string, max int) ([]*release.Release, error) {
+	revisions, err := action.NewHistory(cfg).Run(name)
+	if err != nil {
+		return nil, err
+	}
+	releaseutil.SortByRevision(revisions)
+	if max > 0 && len(revisions) > max {
+		revisions = revisions[len(revisions)-max:]
+	}
+	return revisions, nil
+}
+
+// History returns the revisions of the release, oldest first, keeping at most max revisions.
+func (h *HelmRunner) History(max int) ([]HelmRevision, error) {
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	releases, err := releaseHistory(cfg, h.cfg.ReleaseName(), max)
+	if err != nil {
+		return nil, fmt.Errorf("helm history failed: %w", err)
+	}
+
+	revisions := make([]HelmRevision, 0, len(releases))
+	for _, rel := range releases {
+		revision := HelmRevision{Revision: rel.Version}
+		if rel.Info != nil {
+			revision.Updated = rel.Info.LastDeployed.Format(time.RFC3339)
+			revision.Status = rel.Info.Status.String()
+			revision.Description = rel.Info.Description
+		}
+		if rel.Chart != nil && rel.Chart.Metadata != nil {
+			revision.Chart = fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)
+			revision.AppVersion = rel.Chart.Metadata.AppVersion
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// Test runs the release's test hooks as helm test does. When a test fails, the
+// logs of the failed test pods are printed to help diagnose the failure.
+func (h *HelmRunner) Test() error {
+	h.log().Infof("🧪 Running Helm tests for release: %s", h.cfg.ReleaseName())
+
+	if h.cfg.DryRun {
+		h.log().Infof("   🧪 [DRY-RUN] Would run the test hooks of release %s", h.cfg.ReleaseName())
+		return nil
+	}
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+
+	test := action.NewReleaseTesting(cfg)
+	test.Namespace = h.namespace()
+	test.Timeout = h.timeout()
+
+	rel, err := test.Run(h.cfg.ReleaseName())
+	if err != nil {
+		if rel != nil {
+			h.logFailedTestPods(test, rel)
+		}
+		return fmt.Errorf("helm test failed: %w", err)
+	}
+
+	h.log().Infof("✓  Helm tests passed for %s", h.cfg.ReleaseName())
+	return nil
+}
+
+// logFailedTestPods prints the logs of the test hook pods whose last run failed.
+func (h *HelmRunner) logFailedTestPods(test *action.ReleaseTesting, rel *release.Release) {
+	var failed []string
+	for _, hook := range rel.Hooks {
+		if hook.Kind == "Pod" && hook.LastRun.Phase == release.HookPhaseFailed {
+			h.log().Errorf("❌ Test %s failed", hook.Name)
+			failed = append(failed, hook.Name)
+		}
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	test.Filters[action.IncludeNameFilter] = failed
+	out := h.cfg.terminalOutput()
+	defer flushOutput(out)
+	if err := test.GetPodLogs(out, rel); err != nil {
+		h.log().Warnf("⚠️  Could not fetch logs of the failed test pods: %v", err)
+	}
+}
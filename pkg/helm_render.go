@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// sourcePattern matches the comment helm prefixes each rendered manifest with.
+var sourcePattern = regexp.MustCompile(`(?m)^# Source: (.+)$`)
+
+// Render renders the manifests of every release in-process, as helm template
+// does, using the exact chart, values files and overrides a deploy would use.
+// Manifests are written to stdout, or below outputDir if set.
+func (h *HelmRunner) Render(outputDir string) error {
+	return h.eachRelease(func(r *HelmRunner) error {
+		return r.renderRelease(outputDir)
+	})
+}
+
+func (h *HelmRunner) renderRelease(outputDir string) error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		return err
+	}
+
+	manifests := renderedManifests(rendered)
+	if outputDir == "" {
+		h.log().Infof("📝 Rendering release %s", h.cfg.ReleaseName())
+		for _, manifest := range manifests {
+			fmt.Printf("---\n%s\n", manifest)
+		}
+		return nil
+	}
+
+	dir := filepath.Join(outputDir, h.cfg.EnvName(), h.cfg.ReleaseName())
+	h.log().Infof("📝 Rendering release %s to %s", h.cfg.ReleaseName(), dir)
+	return writeManifests(dir, manifests)
+}
+
+// renderedManifests returns the manifests and hooks of the rendered release,
+// in the order helm template prints them.
+func renderedManifests(rel *release.Release) []string {
+	split := releaseutil.SplitManifests(rel.Manifest)
+	keys := make([]string, 0, len(split))
+	for key := range split {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	manifests := make([]string, 0, len(keys)+len(rel.Hooks))
+	for _, key := range keys {
+		manifests = append(manifests, strings.TrimSpace(split[key]))
+	}
+	for _, hook := range rel.Hooks {
+		manifests = append(manifests, fmt.Sprintf("# Source: %s\n%s", hook.Path, strings.TrimSpace(hook.Manifest)))
+	}
+	return manifests
+}
+
+// writeManifests writes each manifest to the file named after its template
+// below dir, as helm template --output-dir does.
+func writeManifests(dir string, manifests []string) error {
+	written := make(map[string]bool)
+	for _, manifest := range manifests {
+		match := sourcePattern.FindStringSubmatch(manifest)
+		if match == nil {
+			continue
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(match[1]))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if written[path] {
+			flags = os.O_APPEND | os.O_WRONLY
+		}
+		f, err := os.OpenFile(path, flags, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(f, "---\n%s\n", manifest)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written[path] = true
+	}
+	return nil
+}
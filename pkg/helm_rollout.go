@@ -0,0 +1,327 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// rolloutPollInterval is how often the rollout status is checked.
+	rolloutPollInterval = 2 * time.Second
+	// rolloutLogLines is how many log lines of a failing container are reported.
+	rolloutLogLines = 10
+)
+
+// workload is a Deployment, StatefulSet or DaemonSet of a release.
+type workload struct {
+	kind      string
+	name      string
+	namespace string
+}
+
+func (w workload) String() string {
+	return fmt.Sprintf("%s/%s", w.kind, w.name)
+}
+
+// waitForRollout waits until the workloads of the deployed release have
+// rolled out, as kubectl rollout status does, and fails if they don't within
+// helm.timeout.
+func (h *HelmRunner) waitForRollout(ctx context.Context, cfg *action.Configuration, deployed *release.Release) error {
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(deployed.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("failed to parse the manifest of release %s: %w", deployed.Name, err)
+	}
+
+	var workloads []workload
+	for _, info := range resources {
+		switch kind := info.Mapping.GroupVersionKind.Kind; kind {
+		case "Deployment", "StatefulSet", "DaemonSet":
+			workloads = append(workloads, workload{kind: kind, name: info.Name, namespace: info.Namespace})
+		}
+	}
+	if len(workloads) == 0 {
+		return nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout())
+	defer cancel()
+
+	for _, w := range workloads {
+		if err := h.waitForWorkload(ctx, client, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForWorkload waits until the workload has rolled out or ctx is done.
+// The error of a failed rollout describes the workload's unhealthy pods.
+func (h *HelmRunner) waitForWorkload(ctx context.Context, client kubernetes.Interface, w workload) error {
+	h.log().Infof("⏳ Waiting for rollout of %s", w)
+	lastStatus := ""
+	err := wait.PollUntilContextCancel(ctx, rolloutPollInterval, true, func(ctx context.Context) (bool, error) {
+		status, done, err := rolloutStatus(ctx, client, w)
+		if err != nil {
+			return false, err
+		}
+		if status != lastStatus && !done {
+			h.log().Infof("   %s", status)
+			lastStatus = status
+		}
+		return done, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("rollout of %s did not finish within %s: %s", w, h.timeout(), lastStatus)
+	} else if err != nil {
+		err = fmt.Errorf("rollout of %s failed: %w", w, err)
+	}
+	if err != nil {
+		if diagnostics := h.podDiagnostics(client, w); diagnostics != "" {
+			return fmt.Errorf("%w\n%s", err, diagnostics)
+		}
+		return err
+	}
+	h.log().Infof("✓  %s rolled out", w)
+	return nil
+}
+
+// autoRollback rolls a release whose rollout failed back to its last
+// successful revision when deploy.autoRollback is set. The returned error
+// reports both the rollout failure and the outcome of the rollback.
+func (h *HelmRunner) autoRollback(cfg *action.Configuration, deployed *release.Release, rolloutErr error) error {
+	if !h.cfg.DeployAutoRollback {
+		return rolloutErr
+	}
+
+	history := action.NewHistory(cfg)
+	revisions, err := history.Run(deployed.Name)
+	if err != nil {
+		return fmt.Errorf("%w\nautomatic rollback failed: %v", rolloutErr, err)
+	}
+	previous := 0
+	for _, rev := range revisions {
+		if rev.Version < deployed.Version && rev.Version > previous && rev.Info != nil &&
+			(rev.Info.Status == release.StatusSuperseded || rev.Info.Status == release.StatusDeployed) {
+			previous = rev.Version
+		}
+	}
+	if previous == 0 {
+		h.log().Warnf("⚠️  Release %s has no previous successful revision to roll back to", deployed.Name)
+		return rolloutErr
+	}
+
+	h.log().Warnf("⚠️  Deploy failed, rolling %s back to revision %d", deployed.Name, previous)
+	if err := h.Rollback(strconv.Itoa(previous)); err != nil {
+		return fmt.Errorf("%w\nautomatic rollback to revision %d failed: %v", rolloutErr, previous, err)
+	}
+	return fmt.Errorf("%w\nrolled %s back to revision %d", rolloutErr, deployed.Name, previous)
+}
+
+// rollbackFailedUpgrade rolls the release back when Helm itself failed the
+// upgrade, for example on a helm.wait timeout, a failed hook or an
+// interrupted deploy. With helm.atomic, Helm has already rolled it back. An
+// upgrade that failed before recording a revision left the release as it
+// was.
+func (h *HelmRunner) rollbackFailedUpgrade(cfg *action.Configuration, upgradeErr error) error {
+	if !h.cfg.DeployAutoRollback || h.cfg.HelmAtomic {
+		return upgradeErr
+	}
+
+	revisions, err := action.NewHistory(cfg).Run(h.cfg.ReleaseName())
+	if err != nil {
+		return fmt.Errorf("%w\nautomatic rollback failed: %v", upgradeErr, err)
+	}
+	var latest *release.Release
+	for _, rev := range revisions {
+		if latest == nil || rev.Version > latest.Version {
+			latest = rev
+		}
+	}
+	if latest == nil || latest.Info == nil || latest.Info.Status == release.StatusDeployed {
+		return upgradeErr
+	}
+	return h.autoRollback(cfg, latest, upgradeErr)
+}
+
+// podDiagnostics describes the unhealthy pods of the workload, with the
+// reason their containers are waiting or crashed and their last log lines.
+func (h *HelmRunner) podDiagnostics(client kubernetes.Interface, w workload) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	selector, err := workloadSelector(ctx, client, w)
+	if err != nil {
+		h.log().Debugf("Could not read the pod selector of %s: %v", w, err)
+		return ""
+	}
+	pods, err := client.CoreV1().Pods(w.namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		h.log().Debugf("Could not list the pods of %s: %v", w, err)
+		return ""
+	}
+
+	var b strings.Builder
+	for _, pod := range pods.Items {
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.Ready {
+				continue
+			}
+			reason := "not ready"
+			switch {
+			case c.State.Waiting != nil:
+				reason = c.State.Waiting.Reason
+				if c.State.Waiting.Message != "" {
+					reason += ": " + c.State.Waiting.Message
+				}
+			case c.State.Terminated != nil:
+				reason = fmt.Sprintf("%s (exit code %d)", c.State.Terminated.Reason, c.State.Terminated.ExitCode)
+			case c.LastTerminationState.Terminated != nil:
+				reason = fmt.Sprintf("restarted after %s (exit code %d)", c.LastTerminationState.Terminated.Reason, c.LastTerminationState.Terminated.ExitCode)
+			}
+			fmt.Fprintf(&b, "pod %s, container %s: %s\n", pod.Name, c.Name, reason)
+
+			tail := int64(rolloutLogLines)
+			opts := &corev1.PodLogOptions{Container: c.Name, TailLines: &tail, Previous: c.RestartCount > 0}
+			if logs, err := client.CoreV1().Pods(w.namespace).GetLogs(pod.Name, opts).DoRaw(ctx); err == nil && len(logs) > 0 {
+				for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+					fmt.Fprintf(&b, "  %s\n", line)
+				}
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// workloadSelector returns the label selector of the workload's pods.
+func workloadSelector(ctx context.Context, client kubernetes.Interface, w workload) (string, error) {
+	var selector *metav1.LabelSelector
+	apps := client.AppsV1()
+	switch w.kind {
+	case "Deployment":
+		d, err := apps.Deployments(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = d.Spec.Selector
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = s.Spec.Selector
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = ds.Spec.Selector
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	return s.String(), nil
+}
+
+// rolloutStatus returns a description of the workload's rollout progress and
+// whether it is complete. It mirrors the checks of kubectl rollout status.
+func rolloutStatus(ctx context.Context, client kubernetes.Interface, w workload) (string, bool, error) {
+	apps := client.AppsV1()
+	switch w.kind {
+	case "Deployment":
+		d, err := apps.Deployments(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, err
+		}
+		if d.Generation > d.Status.ObservedGeneration {
+			return "Waiting for the deployment spec update to be observed", false, nil
+		}
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+				return "", false, fmt.Errorf("deployment exceeded its progress deadline: %s", c.Message)
+			}
+		}
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		switch {
+		case d.Status.UpdatedReplicas < replicas:
+			return fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, replicas), false, nil
+		case d.Status.Replicas > d.Status.UpdatedReplicas:
+			return fmt.Sprintf("%d old replicas pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), false, nil
+		case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+			return fmt.Sprintf("%d of %d updated replicas available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), false, nil
+		}
+		return "", true, nil
+
+	case "StatefulSet":
+		s, err := apps.StatefulSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, err
+		}
+		if s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+			return "", true, nil // pods are only replaced when deleted
+		}
+		if s.Status.ObservedGeneration == 0 || s.Generation > s.Status.ObservedGeneration {
+			return "Waiting for the statefulset spec update to be observed", false, nil
+		}
+		replicas := int32(1)
+		if s.Spec.Replicas != nil {
+			replicas = *s.Spec.Replicas
+		}
+		if s.Status.ReadyReplicas < replicas {
+			return fmt.Sprintf("%d of %d pods ready", s.Status.ReadyReplicas, replicas), false, nil
+		}
+		if rollingUpdate := s.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+			partitioned := replicas - *rollingUpdate.Partition
+			if s.Status.UpdatedReplicas < partitioned {
+				return fmt.Sprintf("%d of %d partitioned pods updated", s.Status.UpdatedReplicas, partitioned), false, nil
+			}
+			return "", true, nil
+		}
+		if s.Status.UpdateRevision != s.Status.CurrentRevision {
+			return fmt.Sprintf("%d of %d pods updated", s.Status.UpdatedReplicas, replicas), false, nil
+		}
+		return "", true, nil
+
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(w.namespace).Get(ctx, w.name, metav1.GetOptions{})
+		if err != nil {
+			return "", false, err
+		}
+		if ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+			return "", true, nil
+		}
+		if ds.Generation > ds.Status.ObservedGeneration {
+			return "Waiting for the daemonset spec update to be observed", false, nil
+		}
+		desired := ds.Status.DesiredNumberScheduled
+		switch {
+		case ds.Status.UpdatedNumberScheduled < desired:
+			return fmt.Sprintf("%d of %d pods updated", ds.Status.UpdatedNumberScheduled, desired), false, nil
+		case ds.Status.NumberAvailable < desired:
+			return fmt.Sprintf("%d of %d updated pods available", ds.Status.NumberAvailable, desired), false, nil
+		}
+		return "", true, nil
+	}
+	return "", true, nil
+}
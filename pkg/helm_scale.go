@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/action"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Scale sets the replicas of the release's primary workload through its
+// scale subresource, as kubectl scale does, and waits for the rollout unless
+// helm.rolloutStatus is off. The next deploy resets the replicas to the
+// chart's values.
+func (h *HelmRunner) Scale(ctx context.Context, replicas int32) error {
+	if replicas < 0 {
+		return fmt.Errorf("replicas must not be negative, got %d", replicas)
+	}
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+	w, err := h.primaryWorkload(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+
+	current, err := getScale(ctx, client, w)
+	if err != nil {
+		return fmt.Errorf("failed to get the scale of %s: %w", w, err)
+	}
+	log.Infof("📏 Scaling %s from %d to %d replicas", w, current.Spec.Replicas, replicas)
+
+	if h.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would scale %s in namespace %s to %d replicas", w, w.namespace, replicas)
+		return nil
+	}
+
+	current.Spec.Replicas = replicas
+	if err := updateScale(ctx, client, w, current); err != nil {
+		return fmt.Errorf("failed to scale %s: %w", w, err)
+	}
+
+	if h.cfg.HelmRolloutStatus {
+		ctx, cancel := context.WithTimeout(ctx, h.timeout())
+		defer cancel()
+		if err := h.waitForWorkload(ctx, client, w); err != nil {
+			return err
+		}
+	}
+	log.Infof("✓  Scaled %s to %d replicas", w, replicas)
+	return nil
+}
+
+// primaryWorkload returns the Deployment or StatefulSet of the deployed
+// release named like the release, or its only one.
+func (h *HelmRunner) primaryWorkload(cfg *action.Configuration) (workload, error) {
+	deployed, err := action.NewGet(cfg).Run(h.cfg.ReleaseName())
+	if err != nil {
+		return workload{}, fmt.Errorf("failed to get release %s: %w", h.cfg.ReleaseName(), err)
+	}
+
+	var workloads []workload
+	for _, name := range slices.Sorted(maps.Keys(manifestObjects(deployed.Manifest))) {
+		kind, workloadName, _ := strings.Cut(name, "/")
+		if kind != "Deployment" && kind != "StatefulSet" {
+			continue
+		}
+		w := workload{kind: kind, name: workloadName, namespace: h.namespace()}
+		if workloadName == h.cfg.ReleaseName() {
+			return w, nil
+		}
+		workloads = append(workloads, w)
+	}
+
+	switch len(workloads) {
+	case 0:
+		return workload{}, fmt.Errorf("release %s has no Deployment or StatefulSet to scale", h.cfg.ReleaseName())
+	case 1:
+		return workloads[0], nil
+	}
+	names := make([]string, len(workloads))
+	for i, w := range workloads {
+		names[i] = w.String()
+	}
+	return workload{}, fmt.Errorf("release %s has several workloads and none is named like the release: %s", h.cfg.ReleaseName(), strings.Join(names, ", "))
+}
+
+// getScale returns the scale subresource of the workload.
+func getScale(ctx context.Context, client kubernetes.Interface, w workload) (*autoscalingv1.Scale, error) {
+	if w.kind == "StatefulSet" {
+		return client.AppsV1().StatefulSets(w.namespace).GetScale(ctx, w.name, metav1.GetOptions{})
+	}
+	return client.AppsV1().Deployments(w.namespace).GetScale(ctx, w.name, metav1.GetOptions{})
+}
+
+// updateScale updates the scale subresource of the workload.
+func updateScale(ctx context.Context, client kubernetes.Interface, w workload, scale *autoscalingv1.Scale) error {
+	var err error
+	if w.kind == "StatefulSet" {
+		_, err = client.AppsV1().StatefulSets(w.namespace).UpdateScale(ctx, w.name, scale, metav1.UpdateOptions{})
+	} else {
+		_, err = client.AppsV1().Deployments(w.namespace).UpdateScale(ctx, w.name, scale, metav1.UpdateOptions{})
+	}
+	return err
+}
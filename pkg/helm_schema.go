@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ValidateValuesSchema validates the values of the releases against their
+// chart's values.schema.json, if the chart ships one. The values are checked
+// as the deploy would apply them: the chart defaults with all values files,
+// the image values and the --set overrides layered on top, and errors are
+// reported per field.
+func (h *HelmRunner) ValidateValuesSchema() error {
+	return h.eachRelease((*HelmRunner).validateReleaseValues)
+}
+
+func (h *HelmRunner) validateReleaseValues() error {
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return err
+	}
+	defer rel.cleanup()
+	if len(rel.chart.Schema) == 0 {
+		h.log().Info("⏭️  Skipping values schema validation, the chart has no values.schema.json")
+		return nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(rel.chart.Schema))
+	if err != nil {
+		return fmt.Errorf("failed to parse values.schema.json of chart %s: %w", rel.chartPath, err)
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("values.schema.json", doc); err != nil {
+		return fmt.Errorf("invalid values.schema.json in chart %s: %w", rel.chartPath, err)
+	}
+	schema, err := compiler.Compile("values.schema.json")
+	if err != nil {
+		return fmt.Errorf("failed to compile values.schema.json of chart %s: %w", rel.chartPath, err)
+	}
+
+	values, err := chartutil.CoalesceValues(rel.chart, rel.values)
+	if err != nil {
+		return fmt.Errorf("failed to merge the values of release %s: %w", h.cfg.ReleaseName(), err)
+	}
+	if err := validateValues(schema, values); err != nil {
+		return fmt.Errorf("the values of release %s do not match the chart's values.schema.json:\n%w", h.cfg.ReleaseName(), err)
+	}
+	return nil
+}
+
+// validateValues validates values against schema and lists each failing field.
+func validateValues(schema *jsonschema.Schema, values map[string]any) error {
+	// Round-trip through JSON so numbers have the types the validator expects
+	raw, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+
+	err = schema.Validate(doc)
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	var lines []string
+	for _, unit := range validationErr.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		field := strings.ReplaceAll(strings.TrimPrefix(unit.InstanceLocation, "/"), "/", ".")
+		if field == "" {
+			field = "(root)"
+		}
+		lines = append(lines, fmt.Sprintf("  - %s: %s", field, unit.Error))
+	}
+	return errors.New(strings.Join(lines, "\n"))
+}
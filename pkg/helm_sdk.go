@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// helmDefaultTimeout is the timeout helm applies when none is given.
+const helmDefaultTimeout = 5 * time.Minute
+
+// settings returns the Helm environment for the configured context and
+// namespace. Other settings such as the repository cache are read from the
+// usual HELM_* environment variables. Helm talks to the cluster through
+// restClientGetter rather than these settings.
+func (h *HelmRunner) settings() *cli.EnvSettings {
+	settings := cli.New()
+	if len(kubeConfigPaths(h.cfg.KubernetesConfig)) == 1 {
+		settings.KubeConfig = h.cfg.KubernetesConfig
+	}
+	settings.KubeContext = h.cfg.KubernetesContext
+	settings.Debug = h.cfg.Debug
+	settings.SetNamespace(h.namespace())
+	return settings
+}
+
+// restClientGetter gives helm the client configuration of the configured
+// kubeconfig, context and namespace. Helm's own getter only accepts a single
+// kubeconfig file and reads lists from KUBECONFIG, which would have to be
+// set for the whole process. Like it, the discovery client and the REST
+// mapper are created once and cached.
+type restClientGetter struct {
+	config clientcmd.ClientConfig
+
+	mu        sync.Mutex
+	discovery discovery.CachedDiscoveryInterface
+	mapper    meta.RESTMapper
+}
+
+func (g *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return g.config
+}
+
+func (g *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return g.config.ClientConfig()
+}
+
+func (g *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.discoveryClient()
+}
+
+func (g *restClientGetter) discoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	if g.discovery != nil {
+		return g.discovery, nil
+	}
+	config, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	// Discovery fetches a document per API group, as helm's getter does
+	config.Burst = 100
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	g.discovery = memory.NewMemCacheClient(client)
+	return g.discovery, nil
+}
+
+func (g *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.mapper != nil {
+		return g.mapper, nil
+	}
+	client, err := g.discoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(client)
+	g.mapper = restmapper.NewShortcutExpander(mapper, client, nil)
+	return g.mapper, nil
+}
+
+// actionConfig initialises the Helm action configuration talking to the
+// configured cluster. The storage driver is taken from HELM_DRIVER.
+func (h *HelmRunner) actionConfig() (*action.Configuration, error) {
+	getter := &restClientGetter{config: h.cfg.kubeClientConfig()}
+
+	cfg := new(action.Configuration)
+	if err := cfg.Init(getter, h.namespace(), os.Getenv("HELM_DRIVER"), h.log().Debugf); err != nil {
+		return nil, fmt.Errorf("failed to initialise helm: %w", err)
+	}
+
+	registryClient, err := h.registryClient()
+	if err != nil {
+		return nil, err
+	}
+	cfg.RegistryClient = registryClient
+	return cfg, nil
+}
+
+// registryClient returns a client for OCI registries. It authenticates with
+// the Docker registry credentials when they are set, and anonymously otherwise.
+func (h *HelmRunner) registryClient() (*registry.Client, error) {
+	opts := []registry.ClientOption{
+		registry.ClientOptWriter(h.cfg.toolOutput()),
+		registry.ClientOptDebug(h.cfg.Debug),
+		registry.ClientOptCredentialsFile(h.settings().RegistryConfig),
+	}
+	if username, password := os.Getenv("REGISTRY_USERNAME"), os.Getenv("REGISTRY_PASSWORD"); username != "" && password != "" {
+		opts = append(opts, registry.ClientOptBasicAuth(username, password))
+	}
+
+	client, err := registry.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create helm registry client: %w", err)
+	}
+	return client, nil
+}
+
+// postRenderer returns the configured post-renderer, if any.
+func (h *HelmRunner) postRenderer() (postrender.PostRenderer, error) {
+	var renderer postrender.PostRenderer
+	if h.cfg.HelmPostRenderer != "" {
+		var err error
+		if renderer, err = postrender.NewExec(h.cfg.HelmPostRenderer, h.cfg.HelmPostRendererArgs...); err != nil {
+			return nil, fmt.Errorf("invalid helm.postRenderer: %w", err)
+		}
+	}
+	if h.color != "" {
+		renderer = &colorPostRenderer{color: h.color, next: renderer}
+	}
+	return renderer, nil
+}
+
+// timeout returns helm.timeout, or the helm default if unset.
+func (h *HelmRunner) timeout() time.Duration {
+	if h.cfg.HelmTimeout > 0 {
+		return h.cfg.HelmTimeout
+	}
+	return helmDefaultTimeout
+}
+
+// namespace returns the namespace the release is deployed to.
+func (h *HelmRunner) namespace() string {
+	if h.cfg.KubernetesNamespace != "" {
+		return h.cfg.KubernetesNamespace
+	}
+	if namespace, _, err := h.cfg.kubeClientConfig().Namespace(); err == nil && namespace != "" {
+		return namespace
+	}
+	return "default"
+}
+
+// logManifest lists the resources of a rendered release.
+func (h *HelmRunner) logManifest(rel *release.Release) {
+	manifests := releaseutil.SplitManifests(rel.Manifest)
+	keys := make([]string, 0, len(manifests))
+	for key := range manifests {
+		keys = append(keys, key)
+	}
+
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var resources []string
+	for _, key := range keys {
+		var resource struct {
+			Kind     string `yaml:"kind"`
+			Metadata struct {
+				Name string `yaml:"name"`
+			} `yaml:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(manifests[key]), &resource); err != nil || resource.Kind == "" {
+			continue
+		}
+		resources = append(resources, fmt.Sprintf("%s/%s", resource.Kind, resource.Metadata.Name))
+	}
+
+	h.log().Infof("   %d resource(s):", len(resources))
+	for _, resource := range resources {
+		h.log().Infof("     %s", resource)
+	}
+	if len(rel.Hooks) > 0 {
+		hooks := make([]string, 0, len(rel.Hooks))
+		for _, hook := range rel.Hooks {
+			hooks = append(hooks, fmt.Sprintf("%s/%s", hook.Kind, hook.Name))
+		}
+		h.log().Infof("   %d hook(s): %s", len(hooks), strings.Join(hooks, ", "))
+	}
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// serverDryRun submits the rendered manifests of the release to the cluster
+// with a server-side apply in dry-run mode, as kubectl apply --dry-run=server
+// does. The API server validates them against its schemas and runs the
+// admission webhooks, without persisting anything, so rejections that a
+// client-side render can't see fail the dry-run. A namespace that
+// kubernetes.createNamespace would create is submitted in dry-run mode too,
+// but as it doesn't exist, the server can't check the namespaced resources.
+func (h *HelmRunner) serverDryRun(ctx context.Context, rendered *release.Release) error {
+	h.log().Infof("🛰️  Submitting release %s with a server-side dry-run", h.cfg.ReleaseName())
+
+	cfg, err := h.actionConfig()
+	if err != nil {
+		return err
+	}
+	created, err := h.dryRunNamespace(ctx)
+	if err != nil {
+		return err
+	}
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rendered.Manifest), false)
+	if err != nil {
+		return fmt.Errorf("failed to build the manifests of release %s: %w", h.cfg.ReleaseName(), err)
+	}
+
+	var rejected []string
+	skipped := 0
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		if created && info.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			skipped++
+			return nil
+		}
+		data, err := json.Marshal(info.Object)
+		if err != nil {
+			return err
+		}
+
+		force := true
+		helper := resource.NewHelper(info.Client, info.Mapping).DryRun(true).WithFieldManager("dockwright")
+		if _, err := helper.Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{Force: &force}); err != nil {
+			rejected = append(rejected, fmt.Sprintf("%s/%s: %v", info.Mapping.GroupVersionKind.Kind, info.Name, err))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(rejected) > 0 {
+		return fmt.Errorf("the cluster rejected %d resource(s) of release %s:\n%s", len(rejected), h.cfg.ReleaseName(), strings.Join(rejected, "\n"))
+	}
+	if skipped > 0 {
+		h.log().Warnf("⚠️  Namespace %s would be created, so the server could not check the %d namespaced resource(s) of release %s", h.namespace(), skipped, h.cfg.ReleaseName())
+		h.log().Infof("✅ The cluster accepted the %d cluster-scoped resource(s) of release %s", len(resources)-skipped, h.cfg.ReleaseName())
+		return nil
+	}
+	h.log().Infof("✅ The cluster accepted all %d resource(s) of release %s", len(resources), h.cfg.ReleaseName())
+	return nil
+}
+
+// dryRunNamespace submits the release's namespace with a server-side dry-run
+// create when kubernetes.createNamespace would create it, and reports whether
+// it would be created.
+func (h *HelmRunner) dryRunNamespace(ctx context.Context) (bool, error) {
+	if !h.cfg.KubernetesCreateNamespace {
+		return false, nil
+	}
+	namespace := h.namespace()
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return false, err
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		return false, nil
+	} else if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	if _, err := client.CoreV1().Namespaces().Create(ctx, h.namespaceObject(), metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}}); err != nil {
+		return false, fmt.Errorf("the cluster rejected namespace %s: %w", namespace, err)
+	}
+	h.log().Infof("   Namespace %s does not exist yet and would be created", namespace)
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// SmokeTestService probes the release's Service through the API server.
+	SmokeTestService = "service"
+	// SmokeTestIngress probes the host of the release's Ingress.
+	SmokeTestIngress = "ingress"
+
+	// smokeTestRetryDelay is the pause between smoke test requests.
+	smokeTestRetryDelay = 5 * time.Second
+)
+
+// smokeTest probes deploy.smokeTest.path of the deployed release until it
+// answers with the expected status, and fails once the retries are used up.
+// The Service is reached through the API server's service proxy, so the
+// probe works from outside the cluster network; the Ingress through its host.
+func (h *HelmRunner) smokeTest(ctx context.Context, deployed *release.Release) error {
+	if h.cfg.DeploySmokeTestPath == "" || h.companion != nil {
+		return nil
+	}
+
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return err
+	}
+	var probe func(context.Context) (int, string, error)
+	var target string
+	switch h.cfg.DeploySmokeTestTarget {
+	case SmokeTestIngress:
+		url, err := h.ingressURL(ctx, client, deployed)
+		if err != nil {
+			return err
+		}
+		target = url
+		probe = func(ctx context.Context) (int, string, error) { return probeURL(ctx, url) }
+	default:
+		service, err := h.releaseTarget(ctx, client)
+		if err != nil {
+			return err
+		}
+		if service.service == nil || len(service.service.Spec.Ports) == 0 {
+			return fmt.Errorf("release %s has no Service to smoke test. Please set deploy.smokeTest.target to %s", h.cfg.ReleaseName(), SmokeTestIngress)
+		}
+		port := service.service.Spec.Ports[0]
+		scheme := "http"
+		if port.Name == "https" || port.Port == 443 {
+			scheme = "https"
+		}
+		proxy := fmt.Sprintf("%s:%s:%d", scheme, service.service.Name, port.Port)
+		target = fmt.Sprintf("%s%s", service, h.cfg.DeploySmokeTestPath)
+		probe = func(ctx context.Context) (int, string, error) {
+			var status int
+			body, err := client.CoreV1().RESTClient().Get().Namespace(service.service.Namespace).
+				Resource("services").Name(proxy).SubResource("proxy").Suffix(h.cfg.DeploySmokeTestPath).
+				Do(ctx).StatusCode(&status).Raw()
+			if status == 0 && err != nil {
+				return 0, "", err
+			}
+			return status, string(body), nil
+		}
+	}
+
+	h.log().Infof("🩺 Smoke testing %s, expecting HTTP %d", target, h.cfg.DeploySmokeTestStatus)
+	var lastFailure string
+	for attempt := 0; attempt <= h.cfg.DeploySmokeTestRetries; attempt++ {
+		if attempt > 0 {
+			h.log().Infof("   Retrying in %s (%d/%d): %s", smokeTestRetryDelay, attempt, h.cfg.DeploySmokeTestRetries, lastFailure)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(smokeTestRetryDelay):
+			}
+		}
+
+		requestCtx, cancel := context.WithTimeout(ctx, h.cfg.DeploySmokeTestTimeout)
+		status, body, err := probe(requestCtx)
+		cancel()
+		switch {
+		case err != nil:
+			lastFailure = err.Error()
+		case status != h.cfg.DeploySmokeTestStatus:
+			lastFailure = strings.TrimSpace(fmt.Sprintf("HTTP %d %s", status, firstLine(body)))
+		default:
+			h.log().Infof("✓  Smoke test passed: HTTP %d", status)
+			return nil
+		}
+	}
+	return fmt.Errorf("smoke test of %s failed after %d attempt(s): %s", target, h.cfg.DeploySmokeTestRetries+1, lastFailure)
+}
+
+// ingressURL returns the URL of deploy.smokeTest.path on the first host of
+// the release's first Ingress, using https if the host has TLS.
+func (h *HelmRunner) ingressURL(ctx context.Context, client kubernetes.Interface, deployed *release.Release) (string, error) {
+	for _, name := range slices.Sorted(maps.Keys(manifestObjects(deployed.Manifest))) {
+		kind, ingressName, _ := strings.Cut(name, "/")
+		if kind != "Ingress" {
+			continue
+		}
+		ingress, err := client.NetworkingV1().Ingresses(h.namespace()).Get(ctx, ingressName, metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to get %s: %w", name, err)
+		}
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host == "" || strings.HasPrefix(rule.Host, "*") {
+				continue
+			}
+			scheme := "http"
+			for _, tls := range ingress.Spec.TLS {
+				if slices.Contains(tls.Hosts, rule.Host) {
+					scheme = "https"
+				}
+			}
+			return fmt.Sprintf("%s://%s%s", scheme, rule.Host, h.cfg.DeploySmokeTestPath), nil
+		}
+	}
+	return "", fmt.Errorf("release %s has no Ingress with a host to smoke test", h.cfg.ReleaseName())
+}
+
+// probeURL requests url and returns the response's status and body.
+func probeURL(ctx context.Context, url string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, string(body), nil
+}
+
+// firstLine returns the first line of s quoted and shortened for log
+// output, or nothing if s is empty.
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	if line == "" {
+		return ""
+	}
+	if len(line) > 120 {
+		line = line[:120] + "…"
+	}
+	return strconv.Quote(line)
+}
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+// hookPoints are the points of the pipeline hooks can run at.
+var hookPoints = []string{
+	"pre-validation", "post-validation",
+	"pre-docker", "post-docker",
+	"pre-helm", "post-helm",
+}
+
+// Hook is a shell command run before or after a pipeline stage, declared in
+// the hooks section of the config file:
+//
+//	hooks:
+//	  pre-docker:
+//	    - make test
+//	  post-docker:
+//	    - run: ./scripts/warm-cache.sh
+//	      onFailure: warn
+type Hook struct {
+	Run       string `yaml:"run"`
+	OnFailure string `yaml:"onFailure"` // abort (default) or warn
+}
+
+// UnmarshalYAML accepts a plain command as a hook that aborts on failure.
+func (h *Hook) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		h.Run = node.Value
+		return nil
+	}
+	type plain Hook
+	return node.Decode((*plain)(h))
+}
+
+// loadHooks reads the hooks section of the config file.
+func loadHooks() (map[string][]Hook, error) {
+	raw, ok := rawConfigValue("hooks")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var hooks map[string][]Hook
+	if err := yaml.Unmarshal(content, &hooks); err != nil {
+		return nil, fmt.Errorf("invalid hooks section: %w", err)
+	}
+
+	for point, list := range hooks {
+		if !slices.Contains(hookPoints, point) {
+			return nil, fmt.Errorf("unknown hook point 'hooks.%s'. Available hook points: %s", point, strings.Join(hookPoints, ", "))
+		}
+		for i, hook := range list {
+			if hook.Run == "" {
+				return nil, fmt.Errorf("hooks.%s[%d]: run is required", point, i)
+			}
+			if hook.OnFailure != "" && hook.OnFailure != "abort" && hook.OnFailure != "warn" {
+				return nil, fmt.Errorf("hooks.%s[%d]: invalid onFailure '%s', expected 'abort' or 'warn'", point, i, hook.OnFailure)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+// RunHooks runs the hooks of a hook point one after another with sh, followed
+// by the plugin steps registered for it, with the deployment in DOCKWRIGHT_*
+// environment variables. A failing hook aborts the pipeline unless its
+// onFailure is warn. The docker hooks only run when an image is built.
+func (c *Config) RunHooks(ctx context.Context, point string) error {
+	if strings.HasSuffix(point, "-docker") && !c.ShouldRunDockerBuild() {
+		return nil
+	}
+
+	type hookCommand struct {
+		label, onFailure string
+		name             string
+		args             []string
+	}
+	var commands []hookCommand
+	for _, hook := range c.Hooks[point] {
+		commands = append(commands, hookCommand{hook.Run, hook.OnFailure, "sh", []string{"-c", hook.Run}})
+	}
+	for _, plugin := range c.Plugins {
+		for _, step := range plugin.Steps {
+			if step.Hook == point {
+				commands = append(commands, hookCommand{plugin.Name + "/" + step.Name, step.OnFailure, plugin.Path, []string{"step", step.Name}})
+			}
+		}
+	}
+
+	for _, hook := range commands {
+		log.Infof("🪝 Running %s hook: %s", point, hook.label)
+		cmd := command(ctx, hook.name, hook.args...)
+		cmd.Env = append(os.Environ(), c.pipelineEnv()...)
+		cmd.Env = append(cmd.Env, "DOCKWRIGHT_HOOK="+point)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if hook.onFailure == "warn" {
+				log.Warnf("⚠️  %s hook '%s' failed: %v", point, hook.label, err)
+				continue
+			}
+			return fmt.Errorf("%s hook '%s' failed: %w", point, hook.label, err)
+		}
+	}
+	return nil
+}
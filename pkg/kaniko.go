@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// kanikoDefaultExecutor is where the kaniko executor lives in the official image.
+const kanikoDefaultExecutor = "/kaniko/executor"
+
+// kanikoExecutor returns the path of the kaniko executor binary.
+func kanikoExecutor() (string, error) {
+	if path, err := exec.LookPath("executor"); err == nil {
+		return path, nil
+	}
+	if _, err := os.Stat(kanikoDefaultExecutor); err == nil {
+		return kanikoDefaultExecutor, nil
+	}
+	return "", fmt.Errorf("kaniko executor not found in PATH or at %s", kanikoDefaultExecutor)
+}
+
+// runKaniko builds and pushes the image in a single step without a Docker daemon.
+// Registry credentials are handed to kaniko through a temporary docker config.
+func (d *DockerRunner) runKaniko(ctx context.Context, imageTag string) error {
+	username, password, err := registryCredentials()
+	if err != nil {
+		return err
+	}
+
+	args, err := d.kanikoArgs(imageTag)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("🔨 Building and pushing image with kaniko: %s", imageTag)
+	log.Infof("   Build context: %s", d.context)
+	log.Infof("   Target registry: %s", d.cfg.DockerHost)
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: %s %s", kanikoDefaultExecutor, strings.Join(args, " "))
+		return nil
+	}
+
+	configDir, err := writeDockerConfig(d.cfg.DockerHost, username, password)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(configDir)
+
+	if err := runKanikoExecutor(ctx, args, "DOCKER_CONFIG="+configDir); err != nil {
+		return err
+	}
+
+	log.Infof("✓  Successfully built and pushed image: %s", imageTag)
+	if d.cfg.DockerMaxSizeMB > 0 {
+		log.Warnf("⚠️  Image size budget is only enforced with the docker builder, skipping check for %s", imageTag)
+	}
+	return nil
+}
+
+// kanikoArgs returns the executor arguments building imageTag from the runner's context.
+func (d *DockerRunner) kanikoArgs(imageTag string) ([]string, error) {
+	context, err := filepath.Abs(d.context)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve build context: %w", err)
+	}
+
+	// kaniko does not cache base images between runs, so docker.alwaysPull needs no flag here
+	args := []string{
+		"--context", "dir://" + context,
+		"--dockerfile", d.dockerfilePath(),
+		"--destination", imageTag,
+	}
+	if len(d.cfg.DockerPlatforms) == 1 {
+		args = append(args, "--custom-platform", d.cfg.DockerPlatforms[0])
+	}
+	if d.cfg.DockerInsecure {
+		args = append(args, "--insecure", "--skip-tls-verify")
+	}
+	if d.cfg.Debug {
+		args = append(args, "--verbosity=debug")
+	}
+	if d.cfg.DockerMirror != "" {
+		args = append(args, "--registry-mirror", registryHost(d.cfg.DockerMirror))
+		if d.cfg.DockerInsecure {
+			args = append(args, "--insecure-pull", "--skip-tls-verify-pull")
+		}
+	}
+	return args, nil
+}
+
+// runKanikoExecutor runs the kaniko executor with the given arguments and extra environment.
+func runKanikoExecutor(ctx context.Context, args []string, env ...string) error {
+	executor, err := kanikoExecutor()
+	if err != nil {
+		return err
+	}
+
+	cmd := command(ctx, executor, args...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("kaniko build failed: %w", err)
+	}
+	return nil
+}
+
+// writeDockerConfig writes a docker config.json holding credentials for host
+// into a new temporary directory and returns that directory.
+func writeDockerConfig(host, username, password string) (string, error) {
+	dir, err := os.MkdirTemp("", "dockwright-docker-config-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create docker config directory: %w", err)
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	content, err := json.Marshal(map[string]interface{}{
+		"auths": map[string]interface{}{
+			host: map[string]string{"auth": auth},
+		},
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), content, 0o600); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to write docker config: %w", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,173 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	// Registers the legacy auth providers, such as oidc, that kubeconfigs
+	// written before exec credential plugins still use
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+)
+
+// defaultKubeConfigPath returns the kubeconfig kubectl would use: the
+// KUBECONFIG environment variable, which may list several files, or
+// ~/.kube/config.
+func defaultKubeConfigPath() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return env
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// kubeConfigPaths splits a kubeconfig setting into its files. Like
+// KUBECONFIG, it may list several files separated by the OS path list
+// separator (':' on Linux and macOS).
+func kubeConfigPaths(value string) []string {
+	var paths []string
+	for _, path := range filepath.SplitList(value) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// expandKubeConfig expands a leading ~ in each file of a kubeconfig setting,
+// which the shell does for KUBECONFIG but not for values read from
+// dockwright.yaml.
+func expandKubeConfig(value string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return value
+	}
+	paths := filepath.SplitList(value)
+	for i, path := range paths {
+		if path == "~" || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+			paths[i] = filepath.Join(home, path[1:])
+		}
+	}
+	return strings.Join(paths, string(filepath.ListSeparator))
+}
+
+// kubeConfigLoadingRules returns the rules loading the given kubeconfig
+// setting. A single file must exist, while missing files of a list are
+// skipped, as kubectl does.
+func kubeConfigLoadingRules(value string) *clientcmd.ClientConfigLoadingRules {
+	paths := kubeConfigPaths(value)
+	if len(paths) == 1 {
+		return &clientcmd.ClientConfigLoadingRules{ExplicitPath: paths[0]}
+	}
+	return &clientcmd.ClientConfigLoadingRules{Precedence: paths}
+}
+
+// loadKubeConfig reads and merges the files of the given kubeconfig setting.
+func loadKubeConfig(value string) (*clientcmdapi.Config, error) {
+	kubeconfig, err := kubeConfigLoadingRules(value).Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig '%s': %w", value, err)
+	}
+	return kubeconfig, nil
+}
+
+// currentKubeContext returns the current context of the default kubeconfig.
+// As with kubectl, the first file setting a current context wins.
+func currentKubeContext() string {
+	kubeconfig, err := kubeConfigLoadingRules(defaultKubeConfigPath()).Load()
+	if err != nil {
+		return ""
+	}
+	return kubeconfig.CurrentContext
+}
+
+// checkAuthPlugin checks that the exec credential plugin the user of the
+// given context authenticates with, such as aws or gke-gcloud-auth-plugin,
+// is installed. Contexts without an exec plugin pass.
+func checkAuthPlugin(kubeconfig *clientcmdapi.Config, context string) error {
+	kubeContext, ok := kubeconfig.Contexts[context]
+	if !ok {
+		return nil
+	}
+	authInfo, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if ok && authInfo.AuthProvider != nil {
+		return warningf("kubernetes context '%s' uses the deprecated '%s' auth provider, which client-go no longer supports. Please switch to an exec credential plugin", context, authInfo.AuthProvider.Name)
+	}
+	if !ok || authInfo.Exec == nil || authInfo.Exec.Command == "" {
+		return nil
+	}
+
+	if _, err := exec.LookPath(authInfo.Exec.Command); err != nil {
+		hint := authInfo.Exec.InstallHint
+		if hint == "" {
+			hint = fmt.Sprintf("Please install %s to proceed", authInfo.Exec.Command)
+		}
+		return fmt.Errorf("kubernetes context '%s' authenticates with '%s', which is not installed or not found in PATH. %s", context, authInfo.Exec.Command, strings.TrimSpace(hint))
+	}
+	return nil
+}
+
+// kubeClientConfig returns the client configuration of the configured
+// kubeconfig, context and namespace.
+func (c *Config) kubeClientConfig() clientcmd.ClientConfig {
+	overrides := &clientcmd.ConfigOverrides{
+		CurrentContext: c.KubernetesContext,
+		Context:        clientcmdapi.Context{Namespace: c.KubernetesNamespace},
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(kubeConfigLoadingRules(c.KubernetesConfig), overrides)
+}
+
+// restConfig returns the REST configuration of the configured context.
+func (c *Config) restConfig() (*rest.Config, error) {
+	restConfig, err := c.kubeClientConfig().ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure kubernetes client: %w", err)
+	}
+	return restConfig, nil
+}
+
+// KubeClient returns a Kubernetes client for the configured context.
+func (c *Config) KubeClient() (kubernetes.Interface, error) {
+	restConfig, err := c.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// readyPod returns a running and ready pod matching selector in namespace.
+func readyPod(ctx context.Context, client kubernetes.Interface, namespace, selector string) (*corev1.Pod, error) {
+	pods, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for i, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+				return &pods.Items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no ready pod matches %s in namespace %s", selector, namespace)
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// targetContexts returns the Kubernetes contexts the configuration deploys
+// to: those of its environments and of companion releases with a context of
+// their own.
+func (c *Config) targetContexts() []string {
+	var contexts []string
+	for _, cfg := range c.PerEnvironment() {
+		contexts = append(contexts, cfg.KubernetesContext)
+		for _, rel := range cfg.Releases {
+			if rel.Context != "" {
+				contexts = append(contexts, rel.Context)
+			}
+		}
+	}
+	slices.Sort(contexts)
+	return slices.Compact(contexts)
+}
+
+// matchesContext reports whether context matches one of the context names or
+// patterns, such as *-prod.
+func matchesContext(patterns []string, context string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, context); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkContexts refuses contexts outside kubernetes.allowedContexts, and
+// requires the user to type the name of each context in
+// kubernetes.protectedContexts before changing anything in it. Neither
+// --auto-approve nor a missing terminal skip the confirmation, only
+// --allow-protected does. Dry-runs change nothing and need no confirmation.
+func checkContexts(cfg *Config) error {
+	contexts := cfg.targetContexts()
+	if len(cfg.KubernetesAllowedContexts) > 0 {
+		for _, context := range contexts {
+			if !matchesContext(cfg.KubernetesAllowedContexts, context) {
+				return fmt.Errorf("❌ kubernetes context '%s' is not in kubernetes.allowedContexts (%s)", context, strings.Join(cfg.KubernetesAllowedContexts, ", "))
+			}
+		}
+	}
+
+	// Dry-runs and runs skipping the helm stage change nothing in the cluster
+	if cfg.DryRun || cfg.SkipHelm {
+		return nil
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for _, context := range contexts {
+		if !matchesContext(cfg.KubernetesProtectedContexts, context) {
+			continue
+		}
+		if cfg.AllowProtected {
+			log.Warnf("🛡️  Proceeding in protected context %s (--allow-protected)", context)
+			continue
+		}
+
+		log.Warnf("🛡️  Kubernetes context %s is protected", context)
+		fmt.Printf("Type the context name to proceed: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("❌ context %s is protected and its name could not be read: %w. Please pass --allow-protected to proceed without a terminal", context, err)
+		}
+		if strings.TrimSpace(answer) != context {
+			return fmt.Errorf("❌ aborted: '%s' does not match the protected context %s", strings.TrimSpace(answer), context)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// CheckKubeconfigHealth checks the kubeconfig files and the credentials of
+// the contexts the configuration deploys to, which would otherwise only fail
+// mid-deploy with an opaque auth error: expired client certificates and
+// tokens fail the check, while kubeconfig files readable by other users and
+// credentials expiring within kubernetes.certExpiryDays are warnings.
+func (c *Config) CheckKubeconfigHealth() error {
+	var warnings []string
+	checked := map[string]bool{}
+	for _, cfg := range c.PerEnvironment() {
+		targets := []*Config{cfg}
+		for _, rel := range cfg.Releases {
+			if rel.Context != "" {
+				targets = append(targets, cfg.ForRelease(rel))
+			}
+		}
+
+		for _, target := range targets {
+			key := target.KubernetesConfig + "|" + target.KubernetesContext
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+
+			found, err := target.checkKubeconfigHealth()
+			if err != nil {
+				return err
+			}
+			warnings = append(warnings, found...)
+		}
+	}
+
+	slices.Sort(warnings)
+	warnings = slices.Compact(warnings)
+	if len(warnings) > 0 {
+		return warningf("%s", strings.Join(warnings, "\n"))
+	}
+	return nil
+}
+
+func (c *Config) checkKubeconfigHealth() ([]string, error) {
+	var warnings []string
+	if runtime.GOOS != "windows" {
+		for _, path := range kubeConfigPaths(c.KubernetesConfig) {
+			info, err := os.Stat(path)
+			if err == nil && info.Mode().Perm()&0o077 != 0 {
+				warnings = append(warnings, fmt.Sprintf("kubeconfig %s is accessible by other users (mode %04o). Please restrict it with chmod 600 %s", path, info.Mode().Perm(), path))
+			}
+		}
+	}
+
+	kubeconfig, err := loadKubeConfig(c.KubernetesConfig)
+	if err != nil {
+		return nil, err
+	}
+	contextName := c.KubernetesContext
+	if contextName == "" {
+		contextName = kubeconfig.CurrentContext
+	}
+	kubeContext, ok := kubeconfig.Contexts[contextName]
+	if !ok {
+		return warnings, nil
+	}
+	authInfo, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return warnings, nil
+	}
+
+	expiries, err := credentialExpiries(authInfo)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes context '%s': %w", contextName, err)
+	}
+	warnBefore := time.Now().AddDate(0, 0, c.KubernetesCertExpiryDays)
+	for _, credential := range slices.Sorted(maps.Keys(expiries)) {
+		expiry := expiries[credential]
+		switch {
+		case time.Now().After(expiry):
+			return nil, fmt.Errorf("the %s of kubernetes context '%s' expired on %s. Please renew the credentials of user '%s' in the kubeconfig", credential, contextName, expiry.Format(time.DateOnly), kubeContext.AuthInfo)
+		case expiry.Before(warnBefore):
+			days := int(time.Until(expiry).Hours() / 24)
+			warnings = append(warnings, fmt.Sprintf("the %s of kubernetes context '%s' expires in %d day(s), on %s. Please renew it in time", credential, contextName, days, expiry.Format(time.DateOnly)))
+		}
+	}
+	return warnings, nil
+}
+
+// credentialExpiries returns when the client certificate and the bearer
+// token of a kubeconfig user expire, if they do. Tokens that are not JWTs,
+// such as static tokens, have no known expiry.
+func credentialExpiries(authInfo *clientcmdapi.AuthInfo) (map[string]time.Time, error) {
+	expiries := map[string]time.Time{}
+
+	certData := authInfo.ClientCertificateData
+	if len(certData) == 0 && authInfo.ClientCertificate != "" {
+		data, err := os.ReadFile(authInfo.ClientCertificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+		certData = data
+	}
+	if block, _ := pem.Decode(certData); block != nil {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+		expiries["client certificate"] = cert.NotAfter
+	}
+
+	token := authInfo.Token
+	if token == "" && authInfo.TokenFile != "" {
+		data, err := os.ReadFile(authInfo.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if expiry, ok := tokenExpiry(token); ok {
+		expiries["token"] = expiry
+	}
+	return expiries, nil
+}
+
+// tokenExpiry returns the exp claim of a JWT, without verifying it.
+func tokenExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
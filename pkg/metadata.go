@@ -0,0 +1,211 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"os/user"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// Version is the Dockwright version, set at build time with
+// -ldflags "-X github.com/wbr-technologies/dockwright/cli/pkg.Version=...".
+var Version = "dev"
+
+// deploymentMetadata records who deployed a release, when and from which commit.
+type deploymentMetadata struct {
+	Version    string
+	Commit     string
+	Dirty      bool // the working tree had uncommitted changes
+	DeployedBy string
+	DeployedAt time.Time
+}
+
+// newDeploymentMetadata collects the metadata of the current deployment. The
+// commit is left empty outside a git checkout.
+func newDeploymentMetadata(cfg *Config) deploymentMetadata {
+	commit, _ := gitCommit()
+	var dirty bool
+	if commit != "" {
+		changes, _ := cfg.uncommittedChanges()
+		dirty = len(changes) > 0
+	}
+	return deploymentMetadata{
+		Version:    Version,
+		Commit:     commit,
+		Dirty:      dirty,
+		DeployedBy: deployer(),
+		DeployedAt: time.Now().UTC(),
+	}
+}
+
+// deployer identifies the person or pipeline running the deployment:
+// DOCKWRIGHT_DEPLOYER if set, then the CI user, then the git user, then the
+// local user.
+func deployer() string {
+	for _, env := range []string{"DOCKWRIGHT_DEPLOYER", "GITHUB_ACTOR", "GITLAB_USER_LOGIN", "BUILD_REQUESTEDFOREMAIL"} {
+		if value := os.Getenv(env); value != "" {
+			return value
+		}
+	}
+	if out, err := exec.Command("git", "config", "user.email").Output(); err == nil && len(bytes.TrimSpace(out)) > 0 {
+		return string(bytes.TrimSpace(out))
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
+
+// annotations returns the metadata as annotations of the deployed resources.
+// The time is left out: it changes with every deploy, so every resource would
+// differ from its previous revision even when nothing else changed.
+func (m deploymentMetadata) annotations() map[string]string {
+	annotations := map[string]string{
+		"dockwright.io/version":     m.Version,
+		"dockwright.io/deployed-by": m.DeployedBy,
+	}
+	if m.Commit != "" {
+		annotations["dockwright.io/git-commit"] = m.Commit
+	}
+	if m.Dirty {
+		annotations["dockwright.io/git-dirty"] = "true"
+	}
+	return annotations
+}
+
+// invalidLabelChars matches characters not allowed in Kubernetes label values.
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// releaseLabelTime is the layout of the deployed-at release label, as label
+// values can't hold the colons of RFC 3339.
+const releaseLabelTime = "20060102T150405Z"
+
+// releaseLabels returns the metadata as labels of the Helm release. Label
+// values are restricted, so the values are sanitised.
+func (m deploymentMetadata) releaseLabels() map[string]string {
+	labels := map[string]string{
+		"dockwright.io/version":     labelValue(m.Version),
+		"dockwright.io/deployed-by": labelValue(m.DeployedBy),
+		"dockwright.io/deployed-at": m.DeployedAt.Format(releaseLabelTime),
+	}
+	if m.Commit != "" {
+		labels["dockwright.io/git-commit"] = m.Commit
+	}
+	if m.Dirty {
+		labels["dockwright.io/git-dirty"] = "true"
+	}
+	return labels
+}
+
+// labelValue turns s into a valid label value of at most 63 characters.
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-_.")
+}
+
+// metadataPostRenderer adds the deployment metadata annotations to every
+// rendered resource, after running the configured post-renderer, if any.
+// Only the resources themselves are annotated, not pod templates, so
+// deploying unchanged manifests doesn't restart pods.
+type metadataPostRenderer struct {
+	annotations map[string]string
+	next        postrender.PostRenderer
+}
+
+func (p *metadataPostRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	if p.next != nil {
+		var err error
+		if rendered, err = p.next.Run(rendered); err != nil {
+			return nil, err
+		}
+	}
+	return transformManifests(rendered, func(resource *yaml.Node) {
+		annotate(resource, p.annotations)
+	})
+}
+
+// transformManifests calls fn for every resource of the rendered manifests
+// and returns the modified manifests.
+func transformManifests(rendered *bytes.Buffer, fn func(resource *yaml.Node)) (*bytes.Buffer, error) {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	decoder := yaml.NewDecoder(rendered)
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to parse rendered manifests: %w", err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+
+		fn(doc.Content[0])
+		if err := encoder.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// annotate sets the annotations in metadata.annotations of the resource.
+func annotate(resource *yaml.Node, annotations map[string]string) {
+	metadata := mappingValue(resource, "metadata")
+	target := mappingValue(metadata, "annotations")
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		setMappingString(target, key, annotations[key])
+	}
+}
+
+// setMappingString stores the string value under key in the mapping.
+func setMappingString(mapping *yaml.Node, key, value string) {
+	if existing := lookupMapping(mapping, key); existing != nil {
+		existing.SetString(value)
+		return
+	}
+	valueNode := &yaml.Node{}
+	valueNode.SetString(value)
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, valueNode)
+}
+
+// mappingValue returns the mapping stored under key, creating it if needed.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	if value := lookupMapping(mapping, key); value != nil {
+		if value.Kind != yaml.MappingNode {
+			value.Kind, value.Tag, value.Value, value.Content = yaml.MappingNode, "!!map", "", nil
+		}
+		return value
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// lookupMapping returns the value stored under key in the mapping, if any.
+func lookupMapping(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
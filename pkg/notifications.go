@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+// Events a notification can be sent for.
+const (
+	NotifyStarted   = "started"
+	NotifySucceeded = RunSucceeded
+	NotifyFailed    = RunFailed
+)
+
+// notifyEvents are the events a notification can be sent for.
+var notifyEvents = []string{NotifyStarted, NotifySucceeded, NotifyFailed}
+
+// notificationTimeout bounds how long posting a notification or an audit
+// entry may take.
+const notificationTimeout = 10 * time.Second
+
+// defaultNotificationTemplate is the message of notifications without a
+// template of their own.
+const defaultNotificationTemplate = `{{.Icon}} Deploy of {{.Artifact}} to {{.Env}} {{.Event}}
+{{- if .Image}} (image {{.Image}}){{end}}, by {{.Deployer}}
+{{- if .Duration}} after {{.Duration}}{{end}}
+{{- if .Error}}: {{.Error}}{{end}}`
+
+// Notification posts a message about a deploy to a chat or webhook, declared
+// in the notifications section of the config file:
+//
+//	notifications:
+//	  - type: slack
+//	    url: ${SLACK_WEBHOOK_URL}
+//	    events: [succeeded, failed]
+//	  - url: https://deploys.example.com/hook
+type Notification struct {
+	Type     string   `yaml:"type"`     // slack, teams or webhook (default)
+	URL      string   `yaml:"url"`      // environment variables are expanded
+	Events   []string `yaml:"events"`   // defaults to all events
+	Template string   `yaml:"template"` // Go template of the message
+}
+
+// NotificationData is what the message template of a notification is
+// rendered with, and what a webhook notification posts as JSON.
+type NotificationData struct {
+	Event     string   `json:"event"`
+	Icon      string   `json:"-"`
+	Artifact  string   `json:"artifact"`
+	Env       string   `json:"env"`
+	Envs      []string `json:"envs"`
+	Release   string   `json:"release"`
+	Context   string   `json:"context"`
+	Namespace string   `json:"namespace,omitempty"`
+	Image     string   `json:"image,omitempty"`
+	Deployer  string   `json:"deployer"`
+	Commit    string   `json:"commit,omitempty"`
+	Run       string   `json:"run"`
+	Duration  string   `json:"duration,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Message   string   `json:"message"`
+}
+
+// loadNotifications reads the notifications section of the config file.
+func loadNotifications() ([]Notification, error) {
+	raw, ok := rawConfigValue("notifications")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var notifications []Notification
+	if err := yaml.Unmarshal(content, &notifications); err != nil {
+		return nil, fmt.Errorf("invalid notifications section: %w", err)
+	}
+
+	for i, n := range notifications {
+		switch n.Type {
+		case "", "webhook", "slack", "teams":
+		default:
+			return nil, fmt.Errorf("notifications[%d]: unknown type '%s', expected 'slack', 'teams' or 'webhook'", i, n.Type)
+		}
+		if n.URL == "" {
+			return nil, fmt.Errorf("notifications[%d]: url is required", i)
+		}
+		for _, event := range n.Events {
+			if !slices.Contains(notifyEvents, event) {
+				return nil, fmt.Errorf("notifications[%d]: unknown event '%s'. Available events: %s", i, event, strings.Join(notifyEvents, ", "))
+			}
+		}
+		if _, err := template.New("").Parse(n.Template); err != nil {
+			return nil, fmt.Errorf("notifications[%d]: invalid template: %w", i, err)
+		}
+	}
+	return notifications, nil
+}
+
+// notify sends the notifications for an event of the run. Notifications are
+// not sent for dry-runs, and failing to send one is only logged, so that a
+// chat outage never fails a deploy.
+func (r *runRecorder) notify(ctx context.Context, event string) {
+	if len(r.cfg.Notifications) == 0 || r.cfg.DryRun {
+		return
+	}
+
+	data := NotificationData{
+		Event:     event,
+		Icon:      "🚀",
+		Artifact:  r.cfg.ArtifactName,
+		Env:       strings.Join(r.record.Envs, ", "),
+		Envs:      r.record.Envs,
+		Release:   r.record.Release,
+		Context:   r.record.Context,
+		Namespace: r.record.Namespace,
+		Image:     r.record.Image,
+		Deployer:  r.record.User,
+		Commit:    r.record.Commit,
+		Run:       r.record.ID,
+		Error:     r.record.Error,
+	}
+	switch event {
+	case NotifySucceeded:
+		data.Icon, data.Duration = "✅", r.record.Duration().String()
+	case NotifyFailed:
+		data.Icon, data.Duration = "❌", r.record.Duration().String()
+	}
+
+	for _, n := range r.cfg.Notifications {
+		if len(n.Events) > 0 && !slices.Contains(n.Events, event) {
+			continue
+		}
+		if err := n.send(ctx, data); err != nil {
+			log.Warnf("⚠️  Failed to send the %s notification: %v", n.kind(), err)
+			continue
+		}
+		log.Debugf("Sent the %s notification for event %s", n.kind(), event)
+	}
+}
+
+// kind returns the type of the notification.
+func (n Notification) kind() string {
+	if n.Type == "" {
+		return "webhook"
+	}
+	return n.Type
+}
+
+// send renders the message and posts it in the format of the notification's
+// type. Slack and Teams incoming webhooks both accept a plain text message.
+func (n Notification) send(ctx context.Context, data NotificationData) error {
+	text := n.Template
+	if text == "" {
+		text = defaultNotificationTemplate
+	}
+	tmpl, err := template.New("notification").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return err
+	}
+	var message bytes.Buffer
+	if err := tmpl.Execute(&message, data); err != nil {
+		return fmt.Errorf("failed to render the message: %w", err)
+	}
+	data.Message = strings.TrimSpace(message.String())
+
+	var payload interface{} = data
+	if n.kind() != "webhook" {
+		payload = map[string]string{"text": data.Message}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, n.URL, nil, body)
+}
+
+// postJSON posts a JSON body to url, after expanding the environment
+// variables in url and in the values of headers. Errors name only the host,
+// as webhook URLs embed their secret.
+func postJSON(ctx context.Context, rawURL string, headers map[string]string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notificationTimeout)
+	defer cancel()
+	target := os.ExpandEnv(rawURL)
+	if target == "" {
+		return fmt.Errorf("url %s is empty. Please set the environment variable it uses", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid url %s", rawURL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s: %w", req.URL.Host, urlErr.Err)
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", req.URL.Host, resp.Status)
+	}
+	return nil
+}
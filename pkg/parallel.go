@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// log returns the logger of the configuration. Environments deployed in
+// parallel log with their name as prefix, everything else with the default
+// logger.
+func (c *Config) log() *log.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return log.Default()
+}
+
+// log returns the logger of the release's environment.
+func (h *HelmRunner) log() *log.Logger {
+	return h.cfg.log()
+}
+
+// terminalOutput returns where the output of helm meant for the user goes,
+// such as the diff: the terminal, or in a parallel deploy the environment's
+// logger, so that its lines are prefixed like the logs.
+func (c *Config) terminalOutput() io.Writer {
+	if c.logger != nil {
+		return &lineWriter{logger: c.logger}
+	}
+	return os.Stdout
+}
+
+// syncWriter serialises the writes of the environments deployed in parallel,
+// so that their lines don't interleave.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// lineWriter logs every complete line written to it. The writer of the
+// output flushes it when done, to log a last line without a newline.
+type lineWriter struct {
+	logger *log.Logger
+	buf    []byte
+}
+
+// flush logs what is left of an incomplete last line.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.logger.Print(string(bytes.TrimRight(w.buf, "\r")))
+		w.buf = nil
+	}
+}
+
+// flushOutput flushes out once the command writing to it has finished, if it
+// is a lineWriter.
+func flushOutput(out io.Writer) {
+	if w, ok := out.(*lineWriter); ok {
+		w.flush()
+	}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.logger.Print(string(bytes.TrimRight(w.buf[:i], "\r")))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+// parallel reports whether the environments are deployed in parallel. Their
+// approvals would compete for the terminal, so only deploys that ask for none
+// run in parallel.
+func (h *HelmRunner) parallel() bool {
+	if h.cfg.DeployEnvConcurrency <= 1 {
+		return false
+	}
+	if !h.cfg.AutoApprove && !h.cfg.DryRun {
+		log.Warnf("⚠️  Deploying the environments one after another, as their approvals need the terminal. Please pass --auto-approve to deploy them in parallel")
+		return false
+	}
+	return true
+}
+
+// runParallel deploys the environments concurrently, at most
+// deploy.envConcurrency at a time and starting them in the order given.
+// Every line an environment logs is prefixed with its name. Once an
+// environment failed, no further environment is started, while the running
+// ones are left to finish rather than being interrupted mid-upgrade.
+func (h *HelmRunner) runParallel(ctx context.Context, configs []*Config, results []environmentResult) error {
+	log.Infof("🔀 Deploying %d environments, %d at a time", len(configs), min(h.cfg.DeployEnvConcurrency, len(configs)))
+
+	out := &syncWriter{w: os.Stderr}
+	slots := make(chan struct{}, h.cfg.DeployEnvConcurrency)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errs   []error
+		failed atomic.Bool
+	)
+	for i, cfg := range configs {
+		slots <- struct{}{}
+		if failed.Load() || ctx.Err() != nil {
+			break
+		}
+
+		env := cfg.EnvName()
+		cfg.logger = log.Default().WithPrefix(env)
+		cfg.logger.SetOutput(out)
+		cfg.logger.Infof("🌍 Context: %s, namespace: %s", cfg.KubernetesContext, NewHelmRunner(cfg).namespace())
+		results[i].status = "⏳ running"
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			err := NewHelmRunner(cfg).deployCharts(ctx)
+			results[i].duration = time.Since(start).Round(time.Second)
+			if err != nil {
+				results[i].status = "❌ failed"
+				cfg.logger.Errorf("❌ %v", err)
+				failed.Store(true)
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("environment %s: %w", env, err))
+				mu.Unlock()
+				return
+			}
+
+			results[i].status = "✅ deployed"
+			if cfg.DryRun {
+				results[i].status = "🧪 dry-run"
+			}
+			cfg.logger.Infof("✅ Environment %s deployed", env)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,366 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Actions of a release and of its resources in a plan.
+const (
+	PlanInstall = "install"
+	PlanUpgrade = "upgrade"
+	PlanCreate  = "create"
+	PlanUpdate  = "update"
+	PlanDelete  = "delete"
+)
+
+// Plan is everything a deploy would do with the current configuration,
+// computed without changing anything: the images it would build and push,
+// the custom stages it would run, and the releases it would install or
+// upgrade with their resource changes.
+type Plan struct {
+	Artifact string        `json:"artifact"`
+	Commit   string        `json:"commit,omitempty"`
+	Dirty    bool          `json:"dirty,omitempty"`
+	Images   []ImagePlan   `json:"images"`
+	Stages   []StagePlan   `json:"stages,omitempty"`
+	Releases []ReleasePlan `json:"releases"`
+}
+
+// ImagePlan is an image a deploy would build and push.
+type ImagePlan struct {
+	Image     string   `json:"image"`
+	Builder   string   `json:"builder"`
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// StagePlan is a custom stage of the deploy pipeline.
+type StagePlan struct {
+	Name    string `json:"name"`
+	After   string `json:"after"`
+	Run     string `json:"run"`
+	Skipped bool   `json:"skipped,omitempty"` // its when condition is false
+}
+
+// ReleasePlan is a release a deploy would install or upgrade.
+type ReleasePlan struct {
+	Name                 string           `json:"name"`
+	Env                  string           `json:"env,omitempty"`
+	Context              string           `json:"context,omitempty"`
+	Namespace            string           `json:"namespace"`
+	CreateNamespace      bool             `json:"createNamespace,omitempty"`
+	Action               string           `json:"action"`             // install or upgrade
+	Revision             int              `json:"revision,omitempty"` // deployed revision of an upgrade
+	Color                string           `json:"color,omitempty"`    // color deployed by the blue/green strategy
+	Chart                string           `json:"chart"`
+	ChartVersion         string           `json:"chartVersion"`
+	DeployedChartVersion string           `json:"deployedChartVersion,omitempty"`
+	Changes              []ResourceChange `json:"changes"`
+}
+
+// ResourceChange is a resource a release would create, update or delete.
+type ResourceChange struct {
+	Resource string   `json:"resource"` // kind and name
+	Action   string   `json:"action"`
+	Fields   []string `json:"fields,omitempty"` // changed fields of an update, as "path: old → new"
+}
+
+// Plan computes what a deploy would do. Reading the deployed releases needs
+// the cluster, but nothing is built, pushed or changed.
+func (c *Config) Plan(ctx context.Context) (*Plan, error) {
+	metadata := newDeploymentMetadata(c)
+	plan := &Plan{Artifact: c.ArtifactName, Commit: metadata.Commit, Dirty: metadata.Dirty, Images: []ImagePlan{}, Releases: []ReleasePlan{}}
+
+	if c.ShouldRunDockerBuild() {
+		configs := []*Config{c}
+		if c.DockerCompose {
+			services, err := c.ComposeServices()
+			if err != nil {
+				return nil, err
+			}
+			configs = configs[:0]
+			for _, svc := range services {
+				configs = append(configs, c.ForService(svc.Name))
+			}
+		}
+		for _, cfg := range configs {
+			image, err := cfg.ImageTag()
+			if err != nil {
+				return nil, err
+			}
+			plan.Images = append(plan.Images, ImagePlan{Image: image, Builder: cfg.DockerBuilder, Platforms: cfg.DockerPlatforms})
+		}
+	}
+
+	for _, s := range c.Stages {
+		enabled, err := s.enabled(c)
+		if err != nil {
+			return nil, err
+		}
+		plan.Stages = append(plan.Stages, StagePlan{Name: s.Name, After: s.After, Run: s.Run, Skipped: !enabled})
+	}
+
+	err := NewHelmRunner(c).eachRelease(func(r *HelmRunner) error {
+		release, err := r.planRelease(ctx)
+		if err != nil {
+			return err
+		}
+		plan.Releases = append(plan.Releases, *release)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planRelease compares the release as the current chart and values render it
+// with the deployed one. With the blue/green strategy, the inactive color is
+// planned, as that is the release a deploy would upgrade.
+func (h *HelmRunner) planRelease(ctx context.Context) (*ReleasePlan, error) {
+	if h.cfg.HelmStrategy == StrategyBlueGreen && h.companion == nil && h.color == "" {
+		client, err := h.cfg.KubeClient()
+		if err != nil {
+			return nil, err
+		}
+		active, err := h.activeColor(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		next := "blue"
+		if active == "blue" {
+			next = "green"
+		}
+		return h.forColor(next).planRelease(ctx)
+	}
+
+	rel, err := h.prepareRelease()
+	if err != nil {
+		return nil, err
+	}
+	defer rel.cleanup()
+
+	rendered, err := h.render(rel)
+	if err != nil {
+		return nil, err
+	}
+	deployed, err := h.deployedRelease()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the deployed release %s: %w", h.cfg.ReleaseName(), err)
+	}
+
+	plan := &ReleasePlan{
+		Name:         h.cfg.ReleaseName(),
+		Env:          h.cfg.EnvName(),
+		Context:      h.cfg.KubernetesContext,
+		Namespace:    h.namespace(),
+		Action:       PlanInstall,
+		Color:        h.color,
+		Chart:        rel.chart.Name(),
+		ChartVersion: rel.chart.Metadata.Version,
+		Changes:      []ResourceChange{},
+	}
+	var deployedManifest string
+	if deployed != nil {
+		plan.Action, plan.Revision, deployedManifest = PlanUpgrade, deployed.Version, deployed.Manifest
+		if deployed.Chart != nil {
+			plan.DeployedChartVersion = deployed.Chart.Metadata.Version
+		}
+	}
+
+	if h.cfg.KubernetesCreateNamespace {
+		client, err := h.cfg.KubeClient()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := client.CoreV1().Namespaces().Get(ctx, plan.Namespace, metav1.GetOptions{}); apierrors.IsNotFound(err) {
+			plan.CreateNamespace = true
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get namespace %s: %w", plan.Namespace, err)
+		}
+	}
+
+	current, previous := manifestObjects(rendered.Manifest), manifestObjects(deployedManifest)
+	keys := make([]string, 0, len(current)+len(previous))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		obj, inCurrent := current[key]
+		old, inPrevious := previous[key]
+		switch {
+		case !inPrevious:
+			plan.Changes = append(plan.Changes, ResourceChange{Resource: key, Action: PlanCreate})
+		case !inCurrent:
+			plan.Changes = append(plan.Changes, ResourceChange{Resource: key, Action: PlanDelete})
+		case !reflect.DeepEqual(obj, old):
+			var fields []string
+			changedFields("", old, obj, &fields)
+			plan.Changes = append(plan.Changes, ResourceChange{Resource: key, Action: PlanUpdate, Fields: fields})
+		}
+	}
+	return plan, nil
+}
+
+// changedFields records the fields whose value differs between the deployed
+// and the rendered object. Unlike drift detection, fields added or removed by
+// the chart are changes too. Lists of named items are matched by name.
+func changedFields(path string, before, after interface{}, changes *[]string) {
+	beforeMap, beforeIsMap := before.(map[string]interface{})
+	afterMap, afterIsMap := after.(map[string]interface{})
+	if beforeIsMap && afterIsMap {
+		keys := make([]string, 0, len(beforeMap)+len(afterMap))
+		for key := range beforeMap {
+			keys = append(keys, key)
+		}
+		for key := range afterMap {
+			if _, ok := beforeMap[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			changedFields(joinPath(path, key), beforeMap[key], afterMap[key], changes)
+		}
+		return
+	}
+
+	beforeList, beforeIsList := before.([]interface{})
+	afterList, afterIsList := after.([]interface{})
+	if beforeIsList && afterIsList {
+		beforeNames, afterNames := itemNames(beforeList), itemNames(afterList)
+		switch {
+		case beforeNames != nil && afterNames != nil:
+			beforeByName := make(map[string]interface{})
+			for i, name := range beforeNames {
+				beforeByName[name] = beforeList[i]
+			}
+			for i, name := range afterNames {
+				changedFields(fmt.Sprintf("%s[%s]", path, name), beforeByName[name], afterList[i], changes)
+			}
+			for i, name := range beforeNames {
+				if !slices.Contains(afterNames, name) {
+					changedFields(fmt.Sprintf("%s[%s]", path, name), beforeList[i], nil, changes)
+				}
+			}
+		case len(beforeList) == len(afterList):
+			for i := range afterList {
+				changedFields(fmt.Sprintf("%s[%d]", path, i), beforeList[i], afterList[i], changes)
+			}
+		default:
+			*changes = append(*changes, fmt.Sprintf("%s: %d → %d items", path, len(beforeList), len(afterList)))
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, fmt.Sprintf("%s: %s → %s", path, shortValue(before), shortValue(after)))
+	}
+}
+
+// WriteJSON writes the plan as JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// WriteText writes the plan for humans, marking what is created with +,
+// updated with ~ and deleted with -.
+func (p *Plan) WriteText(w io.Writer) {
+	commit := "outside a git checkout"
+	if p.Commit != "" {
+		commit = "at commit " + shortCommit(p.Commit)
+		if p.Dirty {
+			commit += " with uncommitted changes"
+		}
+	}
+	fmt.Fprintf(w, "Plan for %s %s\n\n", p.Artifact, commit)
+
+	fmt.Fprintln(w, "Images:")
+	if len(p.Images) == 0 {
+		fmt.Fprintln(w, "  No image is built, the chart's own image is deployed")
+	}
+	for _, image := range p.Images {
+		builder := image.Builder
+		if len(image.Platforms) > 0 {
+			builder += ", " + strings.Join(image.Platforms, ", ")
+		}
+		fmt.Fprintf(w, "  + build and push %s (%s)\n", image.Image, builder)
+	}
+
+	if len(p.Stages) > 0 {
+		fmt.Fprintln(w, "\nStages:")
+	}
+	for _, stage := range p.Stages {
+		if stage.Skipped {
+			fmt.Fprintf(w, "    skip %s after %s, its when condition is false\n", stage.Name, stage.After)
+			continue
+		}
+		fmt.Fprintf(w, "  + run %s after %s: %s\n", stage.Name, stage.After, stage.Run)
+	}
+
+	counts := map[string]int{}
+	for _, release := range p.Releases {
+		counts[release.Action]++
+		where := []string{}
+		if release.Env != "" {
+			where = append(where, "env "+release.Env)
+		}
+		if release.Context != "" {
+			where = append(where, "context "+release.Context)
+		}
+		where = append(where, "namespace "+release.Namespace)
+		fmt.Fprintf(w, "\nRelease %s (%s):\n", release.Name, strings.Join(where, ", "))
+
+		if release.CreateNamespace {
+			fmt.Fprintf(w, "  + create namespace %s\n", release.Namespace)
+		}
+		switch {
+		case release.Action == PlanInstall:
+			fmt.Fprintf(w, "  + install chart %s %s\n", release.Chart, release.ChartVersion)
+		case release.DeployedChartVersion != release.ChartVersion:
+			fmt.Fprintf(w, "  ~ upgrade revision %d, chart %s %s → %s\n", release.Revision, release.Chart, release.DeployedChartVersion, release.ChartVersion)
+		default:
+			fmt.Fprintf(w, "  ~ upgrade revision %d, chart %s %s\n", release.Revision, release.Chart, release.ChartVersion)
+		}
+		if release.Color != "" {
+			fmt.Fprintf(w, "    as the %s color, then switch the traffic to it\n", release.Color)
+		}
+		if len(release.Changes) == 0 {
+			fmt.Fprintln(w, "    No resource changes")
+		}
+		for _, change := range release.Changes {
+			counts[change.Action]++
+			switch change.Action {
+			case PlanCreate:
+				fmt.Fprintf(w, "    + %s\n", change.Resource)
+			case PlanDelete:
+				fmt.Fprintf(w, "    - %s\n", change.Resource)
+			default:
+				fmt.Fprintf(w, "    ~ %s\n", change.Resource)
+				for _, field := range change.Fields {
+					fmt.Fprintf(w, "        %s\n", field)
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\nPlan: %d image(s) to build and push, %d release(s) to install, %d to upgrade. Resources: %d to create, %d to update, %d to delete.\n",
+		len(p.Images), counts[PlanInstall], counts[PlanUpgrade], counts[PlanCreate], counts[PlanUpdate], counts[PlanDelete])
+}
@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// pluginsDir holds the project's plugins. Plugins installed for all projects
+// live in the dockwright/plugins directory of the user config directory.
+var pluginsDir = filepath.Join(".dockwright", "plugins")
+
+// pluginDescribeTimeout bounds how long a plugin may take to describe itself
+// or to provide credentials.
+const pluginDescribeTimeout = 30 * time.Second
+
+// pluginCredentialEnv maps the credentials a plugin can provide to the
+// environment variables they are otherwise read from.
+var pluginCredentialEnv = map[string][2]string{
+	"registry":        {"REGISTRY_USERNAME", "REGISTRY_PASSWORD"},
+	"helm-repository": {"HELM_REPOSITORY_USERNAME", "HELM_REPOSITORY_PASSWORD"},
+}
+
+// Plugin is an external executable extending the pipeline. Dockwright talks
+// to it by running it with a command, steps and checks get the deployment in
+// DOCKWRIGHT_* environment variables:
+//
+//	<plugin> describe                 prints the plugin's manifest as JSON
+//	<plugin> step <name>              runs a pipeline step, failing with a non-zero exit code
+//	<plugin> check <name>             runs a validation check, like a custom check script
+//	<plugin> credentials <kind>       prints {"username": ..., "password": ...}
+type Plugin struct {
+	Path string
+	pluginManifest
+}
+
+// pluginManifest is what a plugin prints when run with describe.
+type pluginManifest struct {
+	Name  string `json:"name"`
+	Steps []struct {
+		Name      string `json:"name"`
+		Hook      string `json:"hook"`      // hook point the step runs at, e.g. post-helm
+		OnFailure string `json:"onFailure"` // abort (default) or warn
+	} `json:"steps"`
+	Checks      []string `json:"checks"`
+	Credentials []string `json:"credentials"` // registry and/or helm-repository
+}
+
+// loadPlugins describes the plugins of the user config directory, of
+// .dockwright/plugins and of plugins.paths, which may name plugin executables
+// or directories of them.
+func loadPlugins(paths []string) ([]Plugin, error) {
+	var executables []string
+	var dirs []string
+	if configDir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(configDir, "dockwright", "plugins"))
+	}
+	for _, dir := range append(dirs, pluginsDir) {
+		found, err := pluginExecutables(dir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		executables = append(executables, found...)
+	}
+	for _, path := range paths {
+		found, err := pluginExecutables(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s not found. Please check plugins.paths and --plugin", path)
+		}
+		executables = append(executables, found...)
+	}
+
+	var plugins []Plugin
+	for _, path := range executables {
+		plugin, err := describePlugin(path)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(plugins, func(p Plugin) bool { return p.Name == plugin.Name }) {
+			log.Debugf("Ignoring plugin %s, a plugin named %s is already loaded", path, plugin.Name)
+			continue
+		}
+		log.Debugf("Loaded plugin %s from %s", plugin.Name, path)
+		plugins = append(plugins, plugin)
+	}
+	return plugins, nil
+}
+
+// pluginExecutables returns path if it is an executable file, or the
+// executable files in path if it is a directory.
+func pluginExecutables(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins from %s: %w", path, err)
+	}
+	var executables []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			executables = append(executables, filepath.Join(path, entry.Name()))
+		}
+	}
+	return executables, nil
+}
+
+// describePlugin runs a plugin with describe and validates its manifest.
+func describePlugin(path string) (Plugin, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Plugin{}, err
+	}
+	plugin := Plugin{Path: abs}
+	out, err := runPlugin(abs, "describe")
+	if err != nil {
+		return Plugin{}, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if err := json.Unmarshal(out, &plugin.pluginManifest); err != nil {
+		return Plugin{}, fmt.Errorf("plugin %s printed an invalid manifest: %w", path, err)
+	}
+
+	if plugin.Name == "" {
+		return Plugin{}, fmt.Errorf("plugin %s: the manifest has no name", path)
+	}
+	for _, step := range plugin.Steps {
+		if !slices.Contains(hookPoints, step.Hook) {
+			return Plugin{}, fmt.Errorf("plugin %s: step %s has unknown hook point '%s'. Available hook points: %s", plugin.Name, step.Name, step.Hook, strings.Join(hookPoints, ", "))
+		}
+		if step.OnFailure != "" && step.OnFailure != "abort" && step.OnFailure != "warn" {
+			return Plugin{}, fmt.Errorf("plugin %s: step %s has invalid onFailure '%s', expected 'abort' or 'warn'", plugin.Name, step.Name, step.OnFailure)
+		}
+	}
+	for _, kind := range plugin.Credentials {
+		if _, ok := pluginCredentialEnv[kind]; !ok {
+			return Plugin{}, fmt.Errorf("plugin %s: unknown credentials '%s', expected 'registry' or 'helm-repository'", plugin.Name, kind)
+		}
+	}
+	return plugin, nil
+}
+
+// runPlugin runs a plugin command that prints its result to stdout.
+func runPlugin(path string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := command(ctx, path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("%s failed: %w", args[0], err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// applyPluginCredentials sets the registry and chart repository credentials
+// that are not set in the environment from the first plugin providing them.
+func applyPluginCredentials(plugins []Plugin) error {
+	for _, kind := range slices.Sorted(maps.Keys(pluginCredentialEnv)) {
+		env := pluginCredentialEnv[kind]
+		if os.Getenv(env[0]) != "" && os.Getenv(env[1]) != "" {
+			continue
+		}
+		i := slices.IndexFunc(plugins, func(p Plugin) bool { return slices.Contains(p.Credentials, kind) })
+		if i < 0 {
+			continue
+		}
+
+		out, err := runPlugin(plugins[i].Path, "credentials", kind)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", plugins[i].Name, err)
+		}
+		var credentials struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := json.Unmarshal(out, &credentials); err != nil || credentials.Username == "" || credentials.Password == "" {
+			return fmt.Errorf("plugin %s printed invalid %s credentials, expected {\"username\": ..., \"password\": ...}", plugins[i].Name, kind)
+		}
+		os.Setenv(env[0], credentials.Username)
+		os.Setenv(env[1], credentials.Password)
+		log.Infof("🔌 Using the %s credentials of plugin %s", kind, plugins[i].Name)
+	}
+	return nil
+}
+
+// pluginChecks returns a validation check for each check of the plugins.
+func (v *Validator) pluginChecks() []validationCheck {
+	var checks []validationCheck
+	for _, plugin := range v.cfg.Plugins {
+		for _, name := range plugin.Checks {
+			label := plugin.Name + "/" + name
+			checks = append(checks, validationCheck{
+				id:   "plugin-" + plugin.Name + "-" + name,
+				name: "Plugin: " + label,
+				icon: "🔌",
+				fn:   func(ctx context.Context) error { return v.runCheckCommand(ctx, label, plugin.Path, "check", name) },
+			})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// liveImage is the image a deployed release runs.
+type liveImage struct {
+	digest string
+	commit string // the commit the release was deployed from, empty if unknown
+}
+
+// liveImage returns the digest of the artifact's image the deployed release
+// runs, and the commit the release was deployed from. A release that was
+// itself promoted names the digest in its manifest. Otherwise the tag is
+// resolved by the running pods rather than the registry, as the tag may have
+// moved since the pods pulled it. With the blue/green strategy, the active
+// color is read.
+func (h *HelmRunner) liveImage(ctx context.Context) (liveImage, error) {
+	client, err := h.cfg.KubeClient()
+	if err != nil {
+		return liveImage{}, err
+	}
+	if h.cfg.HelmStrategy == StrategyBlueGreen && h.color == "" {
+		active, err := h.activeColor(ctx, client)
+		if err != nil {
+			return liveImage{}, err
+		}
+		if active == "" {
+			return liveImage{}, fmt.Errorf("blue/green Service %s routes to neither color", h.blueGreenService())
+		}
+		return h.forColor(active).liveImage(ctx)
+	}
+
+	deployed, err := h.deployedRelease()
+	if err != nil {
+		return liveImage{}, fmt.Errorf("failed to read release %s: %w", h.cfg.ReleaseName(), err)
+	}
+	if deployed == nil {
+		return liveImage{}, fmt.Errorf("release %s is not deployed in namespace %s of context %s", h.cfg.ReleaseName(), h.namespace(), h.cfg.KubernetesContext)
+	}
+	digest, err := h.liveImageDigest(ctx, client, deployed)
+	if err != nil {
+		return liveImage{}, err
+	}
+	return liveImage{digest: digest, commit: releaseCommit(deployed)}, nil
+}
+
+// liveImageDigest returns the digest of the artifact's image the deployed
+// release runs.
+func (h *HelmRunner) liveImageDigest(ctx context.Context, client kubernetes.Interface, deployed *release.Release) (string, error) {
+	repo, err := h.cfg.ImageRepository()
+	if err != nil {
+		return "", err
+	}
+
+	var images []string
+	digests := map[string]bool{}
+	for _, image := range manifestImages(deployed.Manifest) {
+		if !strings.HasPrefix(image, repo+":") && !strings.HasPrefix(image, repo+"@") {
+			continue
+		}
+		images = append(images, image)
+		if _, digest, ok := strings.Cut(image, "@"); ok {
+			digests[digest] = true
+		}
+	}
+	if len(images) == 0 {
+		return "", fmt.Errorf("release %s does not run the image %s", h.cfg.ReleaseName(), repo)
+	}
+
+	if len(digests) == 0 {
+		target, err := h.releaseTarget(ctx, client)
+		if err != nil {
+			return "", err
+		}
+		pods, err := client.CoreV1().Pods(h.namespace()).List(ctx, metav1.ListOptions{LabelSelector: target.selector})
+		if err != nil {
+			return "", fmt.Errorf("failed to list the pods of %s: %w", target, err)
+		}
+		for _, pod := range pods.Items {
+			specImages := map[string]string{}
+			for _, c := range pod.Spec.Containers {
+				specImages[c.Name] = c.Image
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if !slices.Contains(images, specImages[status.Name]) {
+					continue
+				}
+				if _, digest, ok := strings.Cut(status.ImageID, "@"); ok {
+					digests[digest] = true
+				}
+			}
+		}
+	}
+
+	switch len(digests) {
+	case 0:
+		return "", fmt.Errorf("no running pod of release %s reports the digest of %s", h.cfg.ReleaseName(), strings.Join(images, ", "))
+	case 1:
+		for digest := range digests {
+			return digest, nil
+		}
+	}
+	return "", fmt.Errorf("the pods of release %s run %d different images of %s (%s), a rollout may be in progress. Please promote once it has finished",
+		h.cfg.ReleaseName(), len(digests), repo, strings.Join(slices.Sorted(maps.Keys(digests)), ", "))
+}
+
+// pinImage makes the configuration deploy the promoted image instead of
+// building one. The tag stays in the reference for readability, but the
+// cluster pulls the digest. Approval tokens approve the commit the image was
+// deployed from rather than HEAD.
+func (c *Config) pinImage(image liveImage) {
+	c.tagOverride = "latest@" + image.digest
+	c.SkipDocker = true
+	c.imageCommit = image.commit
+}
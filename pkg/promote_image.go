@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+)
+
+// PromoteImage copies the image at from to the reference to without rebuilding it,
+// so that the exact digest tested in one registry is what ships from the other.
+// The manifest (or manifest list) is copied byte-for-byte, so the digest is preserved.
+func (d *DockerRunner) PromoteImage(ctx context.Context, from, to string) error {
+	log.Infof("🚚 Promoting image")
+	log.Infof("   From: %s", from)
+	log.Infof("   To:   %s", to)
+
+	if d.cfg.DockerBuilder == BuilderDocker {
+		return d.promoteWithBuildx(ctx, from, to)
+	}
+	return d.promoteWithSkopeo(ctx, from, to)
+}
+
+func (d *DockerRunner) promoteWithBuildx(ctx context.Context, from, to string) error {
+	for _, host := range promotionHosts(from, to) {
+		if err := d.loginTo(ctx, host); err != nil {
+			return fmt.Errorf("docker login failed: %w", err)
+		}
+	}
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: docker buildx imagetools create -t %s %s@<digest>", to, imageRefRepository(from))
+		return nil
+	}
+
+	// Copying from the digest reference makes imagetools push the source manifest
+	// as is; a tag reference would be re-wrapped into a new index.
+	fromDigest, err := d.remoteDigest(ctx, from)
+	if err != nil {
+		return err
+	}
+
+	cmd := command(ctx, "docker", "buildx", "imagetools", "create", "-t", to, imageRefRepository(from)+"@"+fromDigest)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("image copy failed: %w", err)
+	}
+
+	toDigest, err := d.remoteDigest(ctx, to)
+	if err != nil {
+		return err
+	}
+	if fromDigest != toDigest {
+		return fmt.Errorf("promoted image digest %s does not match source digest %s", toDigest, fromDigest)
+	}
+
+	log.Infof("✓  Successfully promoted image: %s@%s", to, toDigest)
+	return nil
+}
+
+// promoteWithSkopeo copies the image with skopeo, which needs no Docker daemon.
+func (d *DockerRunner) promoteWithSkopeo(ctx context.Context, from, to string) error {
+	username, password, err := registryCredentials()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"copy", "--all", "--preserve-digests"}
+	if d.cfg.DockerInsecure {
+		args = append(args, "--src-tls-verify=false", "--dest-tls-verify=false")
+	}
+	args = append(args, "docker://"+from, "docker://"+to)
+
+	if d.cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: skopeo %s --src-creds %s:*** --dest-creds %s:***", strings.Join(args, " "), username, username)
+		return nil
+	}
+
+	creds := username + ":" + password
+	cmd := command(ctx, "skopeo", append(args, "--src-creds", creds, "--dest-creds", creds)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("image copy failed: %w", err)
+	}
+
+	log.Infof("✓  Successfully promoted image: %s", to)
+	return nil
+}
+
+// remoteDigest returns the digest of the manifest (list) at ref in its registry.
+func (d *DockerRunner) remoteDigest(ctx context.Context, ref string) (string, error) {
+	out, err := command(ctx, "docker", "buildx", "imagetools", "inspect", "--format", "{{.Manifest.Digest}}", ref).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// promotionHosts returns the distinct registry hosts of the source and destination.
+func promotionHosts(from, to string) []string {
+	hosts := []string{imageRefHost(from)}
+	if host := imageRefHost(to); host != hosts[0] {
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// imageRefRepository strips the tag and digest from an image reference.
+func imageRefRepository(ref string) string {
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
+
+// imageRefHost returns the registry host of an image reference, defaulting to Docker Hub.
+func imageRefHost(ref string) string {
+	first, _, found := strings.Cut(ref, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
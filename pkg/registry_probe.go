@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// registryProbeTimeout bounds each request of the registry probe.
+const registryProbeTimeout = 10 * time.Second
+
+// ProbeRegistry authenticates against docker.host with the registry
+// credentials, the way docker login does: it pings the registry's /v2/
+// endpoint and, if the registry hands out tokens, requests a push token for
+// the artifact's repository. A wrong password or an unreachable registry
+// then fails the validation instead of the push after a long build.
+func (c *Config) ProbeRegistry() error {
+	if c.DryRun {
+		log.Info("⏭️  Skipping registry probe in dry-run mode")
+		return nil
+	}
+	username, password, err := registryCredentials()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: registryProbeTimeout}
+	if c.DockerInsecure {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	host := registryHost(c.DockerHost)
+	resp, err := client.Get("https://" + host + "/v2/")
+	if err != nil && c.DockerInsecure {
+		resp, err = client.Get("http://" + host + "/v2/")
+	}
+	if err != nil {
+		return fmt.Errorf("registry %s is unreachable: %w. Please check docker.host and the network connection", host, err)
+	}
+	resp.Body.Close()
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// An open registry, nothing to authenticate against
+		return nil
+	case resp.StatusCode != http.StatusUnauthorized:
+		return fmt.Errorf("registry %s answered /v2/ with HTTP %d, it may not be a Docker registry. Please check docker.host", host, resp.StatusCode)
+	case strings.HasPrefix(strings.ToLower(challenge), "bearer "):
+		return c.probeRegistryToken(client, host, challenge, username, password)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, resp.Request.URL.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+	return checkRegistryAuth(client, req, host)
+}
+
+// probeRegistryToken requests a push token from the token service named in a
+// Bearer challenge, such as realm="https://auth.docker.io/token".
+func (c *Config) probeRegistryToken(client *http.Client, host, challenge, username, password string) error {
+	params := challengeParams(challenge)
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("registry %s sent an invalid token realm '%s'", host, params["realm"])
+	}
+
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if repo, err := c.ImageRepository(); err == nil {
+		query.Set("scope", fmt.Sprintf("repository:%s:pull,push", strings.TrimPrefix(repo, c.DockerHost+"/")))
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(username, password)
+	return checkRegistryAuth(client, req, host)
+}
+
+// checkRegistryAuth sends an authenticated request to the registry and fails
+// if the credentials are rejected.
+func checkRegistryAuth(client *http.Client, req *http.Request, host string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("registry %s is unreachable: %w. Please check docker.host and the network connection", host, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		log.Debugf("Authenticated against registry %s", host)
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		username, _, _ := req.BasicAuth()
+		return fmt.Errorf("registry %s rejected the credentials of %s (HTTP %d). Please check REGISTRY_USERNAME and REGISTRY_PASSWORD", host, username, resp.StatusCode)
+	default:
+		return fmt.Errorf("registry %s failed to authenticate: HTTP %d %s", host, resp.StatusCode, firstLine(string(body)))
+	}
+}
+
+// challengeParams parses the parameters of a WWW-Authenticate challenge such
+// as Bearer realm="https://auth.example.com/token",service="registry".
+func challengeParams(challenge string) map[string]string {
+	params := map[string]string{}
+	_, rest, _ := strings.Cut(challenge, " ")
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[strings.ToLower(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return params
+}
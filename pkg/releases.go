@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CompanionRelease is an additional chart deployed together with the
+// artifact, read from the helm.releases section of .dockwright/config.yaml:
+//
+//	helm:
+//	  releases:
+//	    - name: "{{.ArtifactName}}-cron"
+//	      chartPath: ./charts/cron
+//	      values: [.dockwright/helm/cron.values.yaml]
+//
+// or from the releases of .dockwright/releases.yaml, which may also place a
+// release in another namespace or context and order it after other releases.
+type CompanionRelease struct {
+	Name         string            `yaml:"name"`
+	ChartPath    string            `yaml:"chartPath"`
+	Repository   string            `yaml:"repository"`
+	Chart        string            `yaml:"chart"`
+	ChartVersion string            `yaml:"chartVersion"`
+	Values       []string          `yaml:"values"`
+	Set          map[string]string `yaml:"set"`
+	SetString    map[string]string `yaml:"setString"`
+	SetFile      map[string]string `yaml:"setFile"`
+	Namespace    string            `yaml:"namespace"`
+	Context      string            `yaml:"context"`
+	Labels       map[string]string `yaml:"labels"`
+	Needs        []string          `yaml:"needs"`
+}
+
+// releasesManifest is the path of the optional multi-release manifest.
+var releasesManifest = filepath.Join(".dockwright", "releases.yaml")
+
+// loadCompanionReleases reads the helm.releases section of the config file,
+// followed by the releases of .dockwright/releases.yaml.
+func loadCompanionReleases() ([]CompanionRelease, error) {
+	var releases []CompanionRelease
+
+	if raw, ok := rawConfigValue("helm.releases"); ok && raw != nil {
+		content, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(content, &releases); err != nil {
+			return nil, fmt.Errorf("invalid helm.releases section: %w", err)
+		}
+		if err := validateCompanionReleases("helm.releases", releases); err != nil {
+			return nil, err
+		}
+	}
+
+	content, err := os.ReadFile(releasesManifest)
+	if os.IsNotExist(err) {
+		return releases, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", releasesManifest, err)
+	}
+
+	var manifest struct {
+		Releases []CompanionRelease `yaml:"releases"`
+	}
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", releasesManifest, err)
+	}
+	if err := validateCompanionReleases(releasesManifest+": releases", manifest.Releases); err != nil {
+		return nil, err
+	}
+	return append(releases, manifest.Releases...), nil
+}
+
+func validateCompanionReleases(source string, releases []CompanionRelease) error {
+	for i, rel := range releases {
+		if rel.Name == "" {
+			return fmt.Errorf("%s[%d]: name is required", source, i)
+		}
+		if (rel.ChartPath == "") == (rel.Chart == "") {
+			return fmt.Errorf("%s[%d]: exactly one of chartPath and chart must be set", source, i)
+		}
+	}
+	return nil
+}
+
+// ForRelease returns a copy of the configuration that deploys the given
+// companion release instead of the artifact's own chart.
+func (c *Config) ForRelease(rel CompanionRelease) *Config {
+	relCfg := *c
+	relCfg.HelmReleaseName = rel.Name
+	relCfg.HelmChartPath = rel.ChartPath
+	relCfg.HelmRepository = rel.Repository
+	relCfg.HelmChart = rel.Chart
+	relCfg.HelmChartVersion = rel.ChartVersion
+	relCfg.HelmSet = rel.Set
+	relCfg.HelmSetString = rel.SetString
+	relCfg.HelmSetFile = rel.SetFile
+	if rel.Namespace != "" {
+		relCfg.KubernetesNamespace = rel.Namespace
+	}
+	if rel.Context != "" {
+		relCfg.KubernetesContext = rel.Context
+	}
+	relCfg.Releases = nil
+	return &relCfg
+}
+
+// orderedReleases returns the companion releases in deployment order: each
+// release comes after the releases it needs and otherwise keeps its
+// configured position. Needs refer to rendered release names.
+func (c *Config) orderedReleases() ([]CompanionRelease, error) {
+	byName := make(map[string]int, len(c.Releases))
+	for i, rel := range c.Releases {
+		byName[c.ForRelease(rel).ReleaseName()] = i
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(c.Releases))
+	ordered := make([]CompanionRelease, 0, len(c.Releases))
+
+	var visit func(i int, path []string) error
+	visit = func(i int, path []string) error {
+		name := c.ForRelease(c.Releases[i]).ReleaseName()
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("release %s depends on itself: %v", name, append(path, name))
+		}
+
+		state[i] = visiting
+		for _, need := range c.Releases[i].Needs {
+			j, ok := byName[need]
+			if !ok {
+				return fmt.Errorf("release %s needs unknown release %s", name, need)
+			}
+			if err := visit(j, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[i] = done
+		ordered = append(ordered, c.Releases[i])
+		return nil
+	}
+
+	for i := range c.Releases {
+		if err := visit(i, nil); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
+
+// selects reports whether a release with the given name and labels matches
+// --selector. Every release implicitly has a name label.
+func (c *Config) selects(name string, labels map[string]string) bool {
+	for key, value := range c.Selector {
+		actual, ok := labels[key]
+		if key == "name" && !ok {
+			actual, ok = name, true
+		}
+		if !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// selectedReleases returns the companion releases matching --selector, in
+// deployment order.
+func (c *Config) selectedReleases() ([]CompanionRelease, error) {
+	ordered, err := c.orderedReleases()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(ordered, func(rel CompanionRelease) bool {
+		return !c.selects(c.ForRelease(rel).ReleaseName(), rel.Labels)
+	}), nil
+}
+
+// companionValuesFiles returns the values files configured for a companion
+// release. The list is a copy, as rendering the values templates replaces
+// its entries with temporary files that are deleted after each pass.
+func (h *HelmRunner) companionValuesFiles() ([]string, error) {
+	for _, file := range h.companion.Values {
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("values file of release %s not found at path: %s", h.cfg.ReleaseName(), file)
+		}
+		h.log().Infof("📄 Found release values file: %s", file)
+	}
+	return slices.Clone(h.companion.Values), nil
+}
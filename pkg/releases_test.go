@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCompanionValuesFilesSurviveTemplating(t *testing.T) {
+	t.Chdir(t.TempDir())
+	values := "cron.values.yaml"
+	if err := os.WriteFile(values, []byte("name: {{ .ArtifactName }}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{ArtifactName: "demo", HelmTemplateValues: true}
+	h := &HelmRunner{cfg: cfg, companion: &CompanionRelease{Name: "cron", Values: []string{values}}}
+	for pass := 1; pass <= 2; pass++ {
+		files, cleanup, err := h.collectValuesFiles()
+		if err != nil {
+			t.Fatalf("pass %d: %v", pass, err)
+		}
+		if len(files) != 1 || files[0] == values {
+			t.Fatalf("pass %d: expected the rendered values file, got %v", pass, files)
+		}
+		cleanup()
+		if h.companion.Values[0] != values {
+			t.Fatalf("pass %d: companion values changed to %v", pass, h.companion.Values)
+		}
+	}
+}
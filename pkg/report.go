@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// Results of a pipeline stage in the report.
+const (
+	StageSucceeded = "succeeded"
+	StageFailed    = "failed"
+	StageSkipped   = "skipped"
+)
+
+// RunReport is the machine-readable outcome of a deploy or build run, written
+// to report.file for release automation to pick up.
+type RunReport struct {
+	RunRecord
+	FinishedAt time.Time              `json:"finishedAt"`
+	Stages     []StageReport          `json:"stages"`
+	Releases   []ReleaseReport        `json:"releases"`
+	Config     map[string]interface{} `json:"config"` // resolved value of every setting, by config path
+}
+
+// StageReport is how a pipeline stage of a run went.
+type StageReport struct {
+	Name    string  `json:"name"`
+	Result  string  `json:"result"`
+	Reason  string  `json:"reason,omitempty"` // why the stage was skipped
+	Seconds float64 `json:"durationSeconds"`
+}
+
+// ReleaseReport is a Helm release deployed by a run.
+type ReleaseReport struct {
+	Name         string `json:"name"`
+	Env          string `json:"env,omitempty"`
+	Context      string `json:"context,omitempty"`
+	Namespace    string `json:"namespace"`
+	Chart        string `json:"chart"`
+	ChartVersion string `json:"chartVersion"`
+	AppVersion   string `json:"appVersion,omitempty"`
+	Revision     int    `json:"revision,omitempty"` // not set for dry-runs
+	Status       string `json:"status"`
+}
+
+// stage records how a pipeline stage went.
+func (r *runRecorder) stage(name, result, reason string, started time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stages = append(r.stages, StageReport{Name: name, Result: result, Reason: reason, Seconds: time.Since(started).Seconds()})
+}
+
+// deployed records a release deployed by the run. Dry-runs record the release
+// they would have deployed, without a revision. Commands that don't record
+// their runs have no recorder.
+func (r *runRecorder) deployed(h *HelmRunner, rel *release.Release) {
+	if r == nil {
+		return
+	}
+	report := ReleaseReport{
+		Name:         rel.Name,
+		Env:          h.cfg.EnvName(),
+		Context:      h.cfg.KubernetesContext,
+		Namespace:    h.namespace(),
+		Chart:        rel.Chart.Name(),
+		ChartVersion: rel.Chart.Metadata.Version,
+		AppVersion:   rel.Chart.Metadata.AppVersion,
+		Status:       "dry-run",
+	}
+	if !h.cfg.DryRun {
+		report.Revision, report.Status = rel.Version, rel.Info.Status.String()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.releases = append(r.releases, report)
+}
+
+// writeReport writes the report of the finished run to report.file.
+func (r *runRecorder) writeReport() error {
+	report := RunReport{
+		RunRecord:  r.record,
+		FinishedAt: r.record.StartedAt.Add(time.Duration(r.record.Seconds * float64(time.Second))),
+		Stages:     r.stages,
+		Releases:   r.releases,
+		Config:     r.cfg.resolvedValues(),
+	}
+	if report.Stages == nil {
+		report.Stages = []StageReport{}
+	}
+	if report.Releases == nil {
+		report.Releases = []ReleaseReport{}
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.cfg.ReportFile), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.cfg.ReportFile, append(content, '\n'), 0o644)
+}
+
+// resolvedValues returns the value of every setting by its config path, as
+// resolved from the flags, the config file and the defaults. Durations are
+// written as in the config file.
+func (c *Config) resolvedValues() map[string]interface{} {
+	v := reflect.ValueOf(c).Elem()
+	values := make(map[string]interface{})
+	for _, field := range ConfigFields() {
+		f := v.FieldByName(cases.Title(language.Und, cases.NoLower).String(field.Name))
+		if !f.IsValid() {
+			continue
+		}
+		if d, ok := f.Interface().(time.Duration); ok {
+			values[field.ConfigPath] = d.String()
+			continue
+		}
+		values[field.ConfigPath] = f.Interface()
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+)
+
+// runDigest returns the digest of the configuration, leaving out the flags
+// that only change how a run behaves, not what it deploys.
+func (c *Config) runDigest() (string, error) {
+	cfg := *c
+	cfg.Debug, cfg.AllowProtected, cfg.Strict, cfg.Offline, cfg.Resume, cfg.AutoApprove = false, false, false, false, false, false
+	cfg.SkipDocker, cfg.SkipHelm = false, false
+	cfg.ValidationTimeout, cfg.DockerBuildTimeout, cfg.HelmWorkflowTimeout, cfg.DeployTimeout = 0, 0, 0, 0
+	cfg.RetryAttempts, cfg.RetryBackoff, cfg.RetryErrors, cfg.RetrySteps = 0, 0, nil, nil
+	cfg.ReportFile, cfg.Notifications = "", nil
+	cfg.AuditBackends, cfg.AuditRequired = nil, false
+	return cfg.Digest()
+}
+
+// Resume continues the most recent deploy of the same release from the
+// stage it failed at: the stages it completed are skipped by this run. It
+// refuses to resume when the commit, the working tree or the configuration
+// changed since, as the image built then would no longer match.
+func (r *runRecorder) Resume() error {
+	if r.cfg.HistoryFile == "" {
+		return fmt.Errorf("--resume needs the run history, but history.file is empty")
+	}
+	runs, err := ReadRuns(r.cfg.HistoryFile)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(runs, func(run RunRecord) bool {
+		return run.Command == r.record.Command && run.Release == r.record.Release &&
+			slices.Equal(run.Envs, r.record.Envs) && run.Context == r.record.Context &&
+			run.Namespace == r.record.Namespace && run.DryRun == r.record.DryRun
+	})
+	if i < 0 {
+		return fmt.Errorf("there is no previous run of release %s to resume in %s. In CI, keep history.file on a volume the jobs share", r.record.Release, r.cfg.HistoryFile)
+	}
+	previous := runs[i]
+	switch {
+	case previous.Result != RunFailed:
+		return fmt.Errorf("the last run of release %s, %s, did not fail, so there is nothing to resume", r.record.Release, previous.ID)
+	case previous.Commit != r.record.Commit:
+		return fmt.Errorf("cannot resume run %s: it deployed commit %s, but HEAD is now %s. Please deploy without --resume", previous.ID, shortCommit(previous.Commit), shortCommit(r.record.Commit))
+	case previous.Dirty || r.record.Dirty:
+		return fmt.Errorf("cannot resume run %s: the git working tree had uncommitted changes, so the image may differ. Please deploy without --resume", previous.ID)
+	case r.record.Digest == "" || previous.Digest == "":
+		return fmt.Errorf("cannot resume run %s: the configuration digest is unknown, so it can't be checked for changes. Please deploy without --resume", previous.ID)
+	case previous.Digest != r.record.Digest:
+		return fmt.Errorf("cannot resume run %s: the configuration changed since. Please deploy without --resume", previous.ID)
+	case len(previous.Completed) == 0:
+		return fmt.Errorf("cannot resume run %s: it failed before completing a stage. Please deploy without --resume", previous.ID)
+	}
+
+	r.record.ResumedFrom = previous.ID
+	r.record.Completed = slices.Clone(previous.Completed)
+	return nil
+}
+
+// shortCommit abbreviates a commit SHA like git does.
+func shortCommit(commit string) string {
+	if commit == "" {
+		return "none"
+	}
+	return commit[:min(len(commit), 7)]
+}
@@ -0,0 +1,148 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// retrySteps are the pipeline steps that talk to a registry or the cluster
+// and are retried according to the retry policy.
+var retrySteps = []string{"login", "push", "chart", "upgrade"}
+
+// defaultRetryErrors match the errors of a flaky network, registry or API
+// server. They are retried unless retry.errors is set.
+var defaultRetryErrors = []string{
+	`(?i)connection (reset|refused)`,
+	`(?i)i/o timeout`,
+	`(?i)TLS handshake timeout`,
+	`(?i)unexpected EOF`,
+	`(?i)too many requests|toomanyrequests|\b429\b`,
+	`(?i)\b50[234]\b|bad gateway|service unavailable|gateway timeout`,
+	`(?i)etcdserver: (request timed out|leader changed)`,
+}
+
+// RetryPolicy is how often a step is attempted, and which of its errors are
+// retried. The retry section of the config file sets the policy of all
+// steps, and steps can override parts of it:
+//
+//	retry:
+//	  attempts: 3
+//	  backoff: 5s
+//	  steps:
+//	    push:
+//	      attempts: 5
+//	    upgrade:
+//	      errors: ["etcdserver: request timed out"]
+type RetryPolicy struct {
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+	Errors   []string      `yaml:"errors"`
+}
+
+// loadRetrySteps reads the per-step policies of the retry section.
+func loadRetrySteps() (map[string]RetryPolicy, error) {
+	raw, ok := rawConfigValue("retry.steps")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var steps map[string]RetryPolicy
+	if err := yaml.Unmarshal(content, &steps); err != nil {
+		return nil, fmt.Errorf("invalid retry.steps section: %w", err)
+	}
+
+	for step, policy := range steps {
+		if !slices.Contains(retrySteps, step) {
+			return nil, fmt.Errorf("unknown step 'retry.steps.%s'. Available steps: %s", step, strings.Join(retrySteps, ", "))
+		}
+		if err := policy.validate(); err != nil {
+			return nil, fmt.Errorf("retry.steps.%s: %w", step, err)
+		}
+	}
+	return steps, nil
+}
+
+// retryPolicy returns the policy of a step: the step's own settings, falling
+// back to the ones of the retry section.
+func (c *Config) retryPolicy(step string) RetryPolicy {
+	policy := c.RetrySteps[step]
+	if policy.Attempts == 0 {
+		policy.Attempts = c.RetryAttempts
+	}
+	if policy.Backoff == 0 {
+		policy.Backoff = c.RetryBackoff
+	}
+	if len(policy.Errors) == 0 {
+		policy.Errors = c.RetryErrors
+	}
+	if len(policy.Errors) == 0 {
+		policy.Errors = defaultRetryErrors
+	}
+	return policy
+}
+
+// validate checks the settings of a policy.
+func (p RetryPolicy) validate() error {
+	if p.Attempts < 0 || p.Backoff < 0 {
+		return fmt.Errorf("attempts and backoff must not be negative")
+	}
+	for _, pattern := range p.Errors {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid error pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// retryable reports whether err matches one of the policy's error patterns.
+func (p RetryPolicy) retryable(err error) bool {
+	return slices.ContainsFunc(p.Errors, func(pattern string) bool {
+		matched, _ := regexp.MatchString(pattern, err.Error())
+		return matched
+	})
+}
+
+// retry runs a step until it succeeds, fails with an error its policy does
+// not retry, or used up its attempts. The delay between attempts starts at
+// the policy's backoff and doubles with every retry.
+func (c *Config) retry(ctx context.Context, step string, fn func() error) error {
+	policy := c.retryPolicy(step)
+	delay := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.Attempts || ctx.Err() != nil || !policy.retryable(err) {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+
+		c.log().Warnf("⚠️  The %s step failed, retrying in %s (%d/%d): %v", step, delay, attempt, policy.Attempts-1, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// outputError adds the last line a failed command printed to stderr to its
+// error, so that the retry patterns can match it.
+func outputError(err error, stderr *bytes.Buffer) error {
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); err != nil && last != "" {
+		return fmt.Errorf("%w: %s", err, last)
+	}
+	return err
+}
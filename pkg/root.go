@@ -2,8 +2,17 @@ package pkg
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
@@ -11,8 +20,9 @@ import (
 
 var (
 	rootCmd = &cobra.Command{
-		Use:   "dockwright",
-		Short: "Dockwright is a modular CLI for Docker & Helm orchestration",
+		Use:     "dockwright",
+		Short:   "Dockwright is a modular CLI for Docker & Helm orchestration",
+		Version: Version,
 	}
 
 	deployCmd = &cobra.Command{
@@ -21,25 +31,264 @@ var (
 		SilenceUsage: true,
 		RunE:         runDeploy,
 	}
+
+	validateCmd = &cobra.Command{
+		Use:          "validate",
+		Short:        "Run the validation checks without deploying",
+		SilenceUsage: true,
+		RunE:         runValidate,
+	}
+
+	rollbackCmd = &cobra.Command{
+		Use:          "rollback [revision]",
+		Short:        "Roll the release back to a previous revision",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runRollback,
+	}
+
+	renderCmd = &cobra.Command{
+		Use:          "render",
+		Aliases:      []string{"template"},
+		Short:        "Render the manifests the deployment would apply",
+		SilenceUsage: true,
+		RunE:         runRender,
+	}
+
+	uninstallCmd = &cobra.Command{
+		Use:          "uninstall",
+		Aliases:      []string{"destroy"},
+		Short:        "Remove the release from the cluster",
+		SilenceUsage: true,
+		RunE:         runUninstall,
+	}
+
+	historyCmd = &cobra.Command{
+		Use:          "history",
+		Short:        "Show the revisions of the release",
+		SilenceUsage: true,
+		RunE:         runHistory,
+	}
+
+	driftCmd = &cobra.Command{
+		Use:          "drift",
+		Short:        "Report changes made to the deployed resources outside of Dockwright",
+		SilenceUsage: true,
+		RunE:         runDrift,
+	}
+
+	planCmd = &cobra.Command{
+		Use:          "plan",
+		Short:        "Show everything a deploy would do, without changing anything",
+		SilenceUsage: true,
+		RunE:         runPlan,
+	}
+
+	portForwardCmd = &cobra.Command{
+		Use:          "port-forward [local:remote]",
+		Short:        "Forward a local port to a pod of the deployed release",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE:         runPortForward,
+	}
+
+	execCmd = &cobra.Command{
+		Use:          "exec [-- command...]",
+		Short:        "Run a command or open a shell in a pod of the deployed release",
+		SilenceUsage: true,
+		RunE:         runExec,
+	}
+
+	scaleCmd = &cobra.Command{
+		Use:          "scale",
+		Short:        "Set the replicas of the release's primary workload",
+		SilenceUsage: true,
+		RunE:         runScale,
+	}
+
+	chartCmd = &cobra.Command{
+		Use:   "chart",
+		Short: "Manage the project-local Helm chart",
+	}
+
+	chartPublishCmd = &cobra.Command{
+		Use:          "publish",
+		Short:        "Package the project-local chart and push it to the chart repository",
+		SilenceUsage: true,
+		RunE:         runChartPublish,
+	}
+
+	buildCmd = &cobra.Command{
+		Use:          "build",
+		Short:        "Build the image without deploying it",
+		SilenceUsage: true,
+		RunE:         runBuild,
+	}
+
+	promoteImageCmd = &cobra.Command{
+		Use:          "promote-image",
+		Short:        "Copy an existing image to another reference without rebuilding it",
+		SilenceUsage: true,
+		RunE:         runPromoteImage,
+	}
+
+	promoteCmd = &cobra.Command{
+		Use:          "promote",
+		Short:        "Deploy the image running in one environment to another without rebuilding it",
+		SilenceUsage: true,
+		RunE:         runPromote,
+	}
+
+	runsCmd = &cobra.Command{
+		Use:   "runs",
+		Short: "Query the recorded runs of deploy, build, promote, rollback, uninstall and scale",
+	}
+
+	runsListCmd = &cobra.Command{
+		Use:          "list",
+		Short:        "List the recorded runs, most recent first",
+		SilenceUsage: true,
+		RunE:         runRunsList,
+	}
+
+	runsShowCmd = &cobra.Command{
+		Use:          "show <id>",
+		Short:        "Show a recorded run",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runRunsShow,
+	}
+
+	approveCmd = &cobra.Command{
+		Use:          "approve",
+		Short:        "Print the token approving a deploy of a commit to protected environments",
+		SilenceUsage: true,
+		RunE:         runApprove,
+	}
+
+	devCmd = &cobra.Command{
+		Use:          "dev",
+		Short:        "Rebuild and redeploy the artifact whenever its sources change",
+		SilenceUsage: true,
+		RunE:         runDev,
+	}
+
+	pruneCmd = &cobra.Command{
+		Use:          "prune",
+		Short:        "Remove old locally-built images of the artifact",
+		SilenceUsage: true,
+		RunE:         runPrune,
+	}
 )
 
 func init() {
+	rootCmd.PersistentFlags().BoolP("debug", "v", false, "Show debug logs and the full output of helm and docker")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if debug, _ := cmd.Flags().GetBool("debug"); debug {
+			log.SetLevel(log.DebugLevel)
+		}
+	}
+
 	rootCmd.AddCommand(deployCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(uninstallCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(driftCmd)
+	rootCmd.AddCommand(planCmd)
+	rootCmd.AddCommand(devCmd)
+	rootCmd.AddCommand(portForwardCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(scaleCmd)
+	rootCmd.AddCommand(chartCmd)
+	chartCmd.AddCommand(chartPublishCmd)
+	rootCmd.AddCommand(buildCmd)
+	rootCmd.AddCommand(promoteImageCmd)
+	rootCmd.AddCommand(promoteCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(runsCmd)
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsShowCmd)
 
-	// Dynamically register flags from ConfigFields
+	addConfigFlags(deployCmd)
+	addConfigFlags(validateCmd)
+	addConfigFlags(rollbackCmd)
+	addConfigFlags(renderCmd)
+	addConfigFlags(uninstallCmd)
+	addConfigFlags(historyCmd)
+	addConfigFlags(driftCmd)
+	addConfigFlags(planCmd)
+	addConfigFlags(devCmd)
+	addConfigFlags(portForwardCmd)
+	addConfigFlags(execCmd)
+	addConfigFlags(scaleCmd)
+	addConfigFlags(chartPublishCmd)
+	addConfigFlags(buildCmd)
+	addConfigFlags(promoteImageCmd)
+	addConfigFlags(promoteCmd)
+	addConfigFlags(pruneCmd)
+	addConfigFlags(approveCmd)
+	addConfigFlags(runsListCmd)
+	addConfigFlags(runsShowCmd)
+	renderCmd.Flags().String("output-dir", "", "Write the manifests to this directory instead of stdout")
+	uninstallCmd.Flags().Bool("delete-namespace", false, "Also delete the release's namespace (kubernetes.namespace)")
+	validateCmd.Flags().StringP("output", "o", "table", "Output format: table, json or sarif")
+	historyCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	planCmd.Flags().StringP("output", "o", "text", "Output format: text or json")
+	historyCmd.Flags().Int("max", 10, "Maximum number of revisions to show (0 for all)")
+	chartPublishCmd.Flags().String("version", "", "Chart version to publish (defaults to the git tag or the commit)")
+	buildCmd.Flags().String("output", "", "Write the image to this tarball instead of pushing it")
+	promoteImageCmd.Flags().String("from", "", "Source image reference (defaults to the artifact's latest image)")
+	promoteImageCmd.Flags().String("to", "", "Destination image reference")
+	promoteCmd.Flags().String("from", "", "Environment whose running image is promoted")
+	promoteCmd.Flags().String("to", "", "Environment the image is deployed to")
+	runsListCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	runsListCmd.Flags().Int("max", 20, "Maximum number of runs to show (0 for all)")
+	runsListCmd.Flags().Bool("failed", false, "Only show failed runs")
+	runsShowCmd.Flags().StringP("output", "o", "table", "Output format: table or json")
+	pruneCmd.Flags().Int("keep", 3, "Number of most recent images to keep")
+	approveCmd.Flags().String("commit", "HEAD", "Commit, branch or tag to approve")
+	approveCmd.Flags().Bool("generate-key", false, "Print a new key pair for approval tokens")
+	execCmd.Flags().StringP("container", "c", "", "Container to run the command in (defaults to the pod's default container)")
+	for _, cmd := range []*cobra.Command{deployCmd, promoteCmd, rollbackCmd, uninstallCmd, scaleCmd} {
+		cmd.Flags().Bool("allow-protected", false, "Proceed in protected Kubernetes contexts without typing their name")
+	}
+	for _, cmd := range []*cobra.Command{deployCmd, validateCmd, buildCmd} {
+		cmd.Flags().Bool("strict", false, "Fail the validation on warnings too")
+	}
+	deployCmd.Flags().Bool("skip-docker", false, "Skip the docker stage in this run, deploying the image pushed before")
+	deployCmd.Flags().Bool("skip-helm", false, "Skip the helm stage in this run, only building and pushing the image")
+	deployCmd.Flags().Bool("resume", false, "Continue the failed previous deploy, skipping the stages it completed")
+	validateCmd.Flags().Bool("offline", false, "Skip the checks that need the network, the cluster or the Docker daemon")
+	scaleCmd.Flags().Int32("replicas", -1, "Number of replicas to scale to")
+	_ = scaleCmd.MarkFlagRequired("replicas")
+}
+
+// addConfigFlags dynamically registers flags from ConfigFields on the given command.
+func addConfigFlags(cmd *cobra.Command) {
 	for _, field := range ConfigFields() {
-		deployCmd.Flags().String(field.Flag, field.Default, field.Description)
+		if field.Repeatable {
+			cmd.Flags().StringArray(field.Flag, nil, field.Description)
+			continue
+		}
+		cmd.Flags().String(field.Flag, field.Default, field.Description)
 	}
 }
 
-// Execute runs the root command.
+// Execute runs the root command. Interrupting the process cancels the command's context.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		stop()
 		os.Exit(1)
 	}
 }
 
-func runDeploy(cmd *cobra.Command, args []string) error {
+func runDeploy(cmd *cobra.Command, args []string) (err error) {
 	// Configure logger
 	log.SetTimeFormat("")
 
@@ -52,30 +301,39 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	}
 	cfg.LogSummary()
 
-	// User confirmation
-	if !cfg.AutoApprove && !cfg.DryRun {
-		fmt.Print("Please confirm the configuration above. Press Enter to proceed with deployment: ")
-		reader := bufio.NewReader(os.Stdin)
-		_, err = reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("failed to read user input: %w", err)
+	if err := checkContexts(cfg); err != nil {
+		return err
+	}
+	run := cfg.StartRun("deploy")
+	if cfg.Resume {
+		if err := run.Resume(); err != nil {
+			return fmt.Errorf("❌ %w", err)
 		}
+		log.Infof("⏩ Resuming run %s", run.record.ResumedFrom)
+	}
+	if err := run.auditStart(cmd.Context()); err != nil {
+		return err
 	}
+	run.notify(cmd.Context(), NotifyStarted)
+	defer func() {
+		run.Finish(err)
+		run.auditFinish(cmd.Context())
+		run.notify(cmd.Context(), run.record.Result)
+	}()
+
+	ctx, cancel := cfg.deployContext(cmd.Context())
+	defer cancel()
 
 	// Step 2: Validation
 	logSection(2, "VALIDATION", "✓")
 
 	validator := NewValidator(cfg)
-	results, err := validator.ValidateAll()
-	for _, r := range results {
-		if r.Err != nil {
-			log.Errorf("❌ Validation error in %s", r.Name)
-			return err
-		} else {
-			log.Info(r.Message)
-		}
+	if err := runStage(ctx, cfg, run, "validation", func(ctx context.Context) error {
+		return logValidationResults(validator.WithContext(ctx).ValidateAll())
+	}); err != nil {
+		return err
 	}
-	if err != nil {
+	if err := runCustomStages(ctx, cfg, run, "validation"); err != nil {
 		return err
 	}
 
@@ -83,16 +341,32 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	logSection(3, "DOCKER WORKFLOW", "🐳")
 
 	dockerRunner := NewDockerRunner(cfg)
-	if err := dockerRunner.Run(); err != nil {
-		return fmt.Errorf("❌ docker workflow failed: %w", err)
+	if err := runStage(ctx, cfg, run, "docker", func(ctx context.Context) error {
+		if err := dockerRunner.Run(ctx); err != nil {
+			return fmt.Errorf("❌ docker workflow failed: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := runCustomStages(ctx, cfg, run, "docker"); err != nil {
+		return err
 	}
 
 	// Step 4: Helm Workflow
 	logSection(4, "HELM WORKFLOW", "⎈")
 
 	helmRunner := NewHelmRunner(cfg)
-	if err := helmRunner.Run(); err != nil {
-		return fmt.Errorf("❌ helm workflow failed: %w", err)
+	if err := runStage(ctx, cfg, run, "helm", func(ctx context.Context) error {
+		if err := helmRunner.Run(ctx); err != nil {
+			return fmt.Errorf("❌ helm workflow failed: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := runCustomStages(ctx, cfg, run, "helm"); err != nil {
+		return err
 	}
 
 	// Complete
@@ -101,6 +375,888 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// runStage runs a pipeline stage between its pre- and post-hooks and records
+// its completion, unless the run resumes a run that already completed it.
+// The stage, hooks included, is cancelled once its timeout expires.
+func runStage(ctx context.Context, cfg *Config, run *runRecorder, stage string, fn func(ctx context.Context) error) error {
+	started := time.Now()
+	if cfg.SkipsStage(stage) {
+		log.Infof("⏭️  Skipping the %s stage, --skip-%s is set", stage, stage)
+		run.stage(stage, StageSkipped, "--skip-"+stage, started)
+		return nil
+	}
+	if run.completed(stage) {
+		log.Infof("⏭️  Skipping the %s stage, completed by run %s", stage, run.record.ResumedFrom)
+		run.stage(stage, StageSkipped, "completed by run "+run.record.ResumedFrom, started)
+		return nil
+	}
+	ctx, cancel := cfg.stageContext(ctx, stage)
+	defer cancel()
+
+	err := cfg.RunHooks(ctx, "pre-"+stage)
+	if err != nil {
+		err = fmt.Errorf("❌ %w", err)
+	} else if err = fn(ctx); err == nil {
+		if err = cfg.RunHooks(ctx, "post-"+stage); err != nil {
+			err = fmt.Errorf("❌ %w", err)
+		}
+	}
+	if err != nil {
+		run.stage(stage, StageFailed, "", started)
+		return stageError(ctx, err)
+	}
+	run.stage(stage, StageSucceeded, "", started)
+	run.complete(stage)
+	return nil
+}
+
+// stageError adds why a stage's context was cancelled to the error it failed
+// with, as a timed out command only reports being killed.
+func stageError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && !strings.Contains(err.Error(), cause.Error()) {
+		return fmt.Errorf("%w: %v", err, cause)
+	}
+	return err
+}
+
+func runRollback(cmd *cobra.Command, args []string) (err error) {
+	log.SetTimeFormat("")
+
+	var revision string
+	if len(args) > 0 {
+		revision = args[0]
+		if _, err := strconv.Atoi(revision); err != nil {
+			return fmt.Errorf("❌ revision must be a number, got '%s'", revision)
+		}
+	}
+
+	// Step 1: Configuration
+	logSection(1, "CONFIGURATION", "⚙️")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	cfg.LogSummary()
+
+	if err := confirm(cfg, "Please confirm the configuration above. Press Enter to proceed with the rollback: "); err != nil {
+		return err
+	}
+	if err := checkContexts(cfg); err != nil {
+		return err
+	}
+	run := cfg.StartRun("rollback")
+	if err := run.auditStart(cmd.Context()); err != nil {
+		return err
+	}
+	defer func() {
+		run.Finish(err)
+		run.auditFinish(cmd.Context())
+	}()
+
+	// Step 2: Rollback
+	logSection(2, "HELM ROLLBACK", "⎈")
+
+	if err := NewHelmRunner(cfg).Rollback(revision); err != nil {
+		return fmt.Errorf("❌ rollback failed: %w", err)
+	}
+
+	logSection(0, "ROLLBACK COMPLETE", "🎉")
+
+	return nil
+}
+
+func runRender(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	outputDir, err := cmd.Flags().GetString("output-dir")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	// Rendering changes nothing, so there is nothing to confirm
+	cfg.AutoApprove = true
+
+	if err := NewHelmRunner(cfg).Render(outputDir); err != nil {
+		return fmt.Errorf("❌ render failed: %w", err)
+	}
+	return nil
+}
+
+func runUninstall(cmd *cobra.Command, args []string) (err error) {
+	log.SetTimeFormat("")
+
+	deleteNamespace, err := cmd.Flags().GetBool("delete-namespace")
+	if err != nil {
+		return err
+	}
+
+	// Step 1: Configuration
+	logSection(1, "CONFIGURATION", "⚙️")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	cfg.LogSummary()
+
+	prompt := fmt.Sprintf("Release %s will be removed. Press Enter to proceed: ", cfg.ReleaseName())
+	if deleteNamespace {
+		prompt = fmt.Sprintf("Release %s and namespace %s will be removed. Press Enter to proceed: ", cfg.ReleaseName(), cfg.KubernetesNamespace)
+	}
+	if err := confirm(cfg, prompt); err != nil {
+		return err
+	}
+	if err := checkContexts(cfg); err != nil {
+		return err
+	}
+	run := cfg.StartRun("uninstall")
+	if err := run.auditStart(cmd.Context()); err != nil {
+		return err
+	}
+	defer func() {
+		run.Finish(err)
+		run.auditFinish(cmd.Context())
+	}()
+
+	// Step 2: Uninstall
+	logSection(2, "HELM UNINSTALL", "⎈")
+
+	if err := NewHelmRunner(cfg).Uninstall(deleteNamespace); err != nil {
+		return fmt.Errorf("❌ uninstall failed: %w", err)
+	}
+
+	logSection(0, "UNINSTALL COMPLETE", "🧹")
+
+	return nil
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output != "table" && output != "json" && output != "sarif" {
+		return fmt.Errorf("❌ --output must be 'table', 'json' or 'sarif', got '%s'", output)
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+
+	results, err := NewValidator(cfg).ValidateAll()
+	report := NewValidationReport(results, err)
+	switch output {
+	case "json":
+		if writeErr := report.WriteJSON(os.Stdout); writeErr != nil {
+			return writeErr
+		}
+	case "sarif":
+		if writeErr := report.WriteSARIF(os.Stdout); writeErr != nil {
+			return writeErr
+		}
+	default:
+		return logValidationResults(results, err)
+	}
+	return err
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("❌ --output must be 'table' or 'json', got '%s'", output)
+	}
+	max, err := cmd.Flags().GetInt("max")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	revisions, err := NewHelmRunner(cfg).History(max)
+	if err != nil {
+		return fmt.Errorf("❌ failed to read release history: %w", err)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(revisions)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tDEPLOYED\tSTATUS\tCHART\tAPP VERSION\tDESCRIPTION")
+	for _, r := range revisions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", r.Revision, r.Updated, r.Status, r.Chart, r.AppVersion, r.Description)
+	}
+	return w.Flush()
+}
+
+func runRunsList(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("❌ --output must be 'table' or 'json', got '%s'", output)
+	}
+	max, err := cmd.Flags().GetInt("max")
+	if err != nil {
+		return err
+	}
+	failed, err := cmd.Flags().GetBool("failed")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	runs, err := ReadRuns(cfg.HistoryFile)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	// Without --env, the runs of all environments are listed
+	filterEnvs := cmd.Flags().Changed("env")
+	runs = slices.DeleteFunc(runs, func(run RunRecord) bool {
+		if failed && run.Result != RunFailed {
+			return true
+		}
+		return filterEnvs && !slices.ContainsFunc(run.Envs, func(env string) bool { return slices.Contains(cfg.Env, env) })
+	})
+	if max > 0 && len(runs) > max {
+		runs = runs[:max]
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(runs)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tCOMMAND\tENV\tRESULT\tDURATION\tCOMMIT\tUSER")
+	for _, run := range runs {
+		command := run.Command
+		if run.DryRun {
+			command += " (dry-run)"
+		}
+		commit := run.Commit[:min(len(run.Commit), 7)]
+		if run.Dirty {
+			commit += "+dirty"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", run.ID, run.StartedAt.Local().Format(time.DateTime), command, strings.Join(run.Envs, ","), run.Result, run.Duration(), commit, run.User)
+	}
+	return w.Flush()
+}
+
+func runRunsShow(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output != "table" && output != "json" {
+		return fmt.Errorf("❌ --output must be 'table' or 'json', got '%s'", output)
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	runs, err := ReadRuns(cfg.HistoryFile)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	run, err := FindRun(runs, args[0])
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(run)
+	}
+
+	commit := run.Commit
+	if run.Dirty {
+		commit += " (uncommitted changes)"
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	for _, row := range [][2]string{
+		{"ID", run.ID},
+		{"Command", run.Command},
+		{"Result", run.Result},
+		{"Error", strings.ReplaceAll(run.Error, "\n", "\n\t")},
+		{"Started", run.StartedAt.Local().Format(time.DateTime)},
+		{"Duration", run.Duration().String()},
+		{"User", run.User},
+		{"Dockwright", run.Version},
+		{"Commit", commit},
+		{"Image", run.Image},
+		{"Image digest", run.ImageDigest},
+		{"Environments", strings.Join(run.Envs, ", ")},
+		{"Context", run.Context},
+		{"Namespace", run.Namespace},
+		{"Release", run.Release},
+		{"Dry-run", strconv.FormatBool(run.DryRun)},
+	} {
+		if row[1] != "" {
+			fmt.Fprintf(w, "%s:\t%s\n", row[0], row[1])
+		}
+	}
+	return w.Flush()
+}
+
+func runPortForward(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	var ports string
+	if len(args) > 0 {
+		ports = args[0]
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	if err := NewHelmRunner(cfg).PortForward(cmd.Context(), ports); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	return nil
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	container, err := cmd.Flags().GetString("container")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	if err := NewHelmRunner(cfg).Exec(cmd.Context(), container, args); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	return nil
+}
+
+func runScale(cmd *cobra.Command, args []string) (err error) {
+	log.SetTimeFormat("")
+
+	replicas, err := cmd.Flags().GetInt32("replicas")
+	if err != nil {
+		return err
+	}
+
+	// Step 1: Configuration
+	logSection(1, "CONFIGURATION", "⚙️")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	cfg.LogSummary()
+
+	if err := confirm(cfg, fmt.Sprintf("Release %s will be scaled to %d replicas. Press Enter to proceed: ", cfg.ReleaseName(), replicas)); err != nil {
+		return err
+	}
+	if err := checkContexts(cfg); err != nil {
+		return err
+	}
+	run := cfg.StartRun("scale")
+	if err := run.auditStart(cmd.Context()); err != nil {
+		return err
+	}
+	defer func() {
+		run.Finish(err)
+		run.auditFinish(cmd.Context())
+	}()
+
+	// Step 2: Scale
+	logSection(2, "SCALE", "📏")
+
+	if err := NewHelmRunner(cfg).Scale(cmd.Context(), replicas); err != nil {
+		return fmt.Errorf("❌ scale failed: %w", err)
+	}
+
+	logSection(0, "SCALE COMPLETE", "🎉")
+
+	return nil
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	// Drift detection changes nothing, so there is nothing to confirm
+	cfg.AutoApprove = true
+
+	if err := NewHelmRunner(cfg).DetectDrift(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	return nil
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+	if output != "text" && output != "json" {
+		return fmt.Errorf("❌ --output must be 'text' or 'json', got '%s'", output)
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	// Planning changes nothing, so there is nothing to confirm
+	cfg.AutoApprove = true
+
+	plan, err := cfg.Plan(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("❌ plan failed: %w", err)
+	}
+	if output == "json" {
+		return plan.WriteJSON(os.Stdout)
+	}
+	plan.WriteText(os.Stdout)
+	return nil
+}
+
+func runDev(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if cfg, err = cfg.SingleEnvironment(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	cfg.LogSummary()
+
+	// The loop redeploys on every save, so it is kept away from the
+	// environments and contexts that guard their deploys
+	if env := cfg.EnvName(); cfg.protectsEnv(env) {
+		return fmt.Errorf("❌ environment %s is protected, dockwright dev only deploys to development environments", env)
+	}
+	for _, context := range cfg.targetContexts() {
+		if matchesContext(cfg.KubernetesProtectedContexts, context) {
+			return fmt.Errorf("❌ kubernetes context %s is protected, dockwright dev only deploys to development clusters", context)
+		}
+	}
+	if err := checkContexts(cfg); err != nil {
+		return err
+	}
+	cfg.AutoApprove = true
+
+	logSection(0, "VALIDATION", "✓")
+	if err := logValidationResults(NewValidator(cfg).WithContext(cmd.Context()).ValidateAll()); err != nil {
+		return err
+	}
+
+	return newDevLoop(cfg).run(cmd.Context())
+}
+
+func runChartPublish(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	version, err := cmd.Flags().GetString("version")
+	if err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+
+	if err := confirm(cfg, fmt.Sprintf("Publish chart %s to %s? Press Enter to proceed: ", cfg.HelmChartPath, cfg.HelmPublishRepository)); err != nil {
+		return err
+	}
+
+	if err := NewHelmRunner(cfg).PublishChart(version); err != nil {
+		return fmt.Errorf("❌ chart publishing failed: %w", err)
+	}
+	return nil
+}
+
+func runBuild(cmd *cobra.Command, args []string) (err error) {
+	log.SetTimeFormat("")
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return err
+	}
+
+	// Step 1: Configuration
+	logSection(1, "CONFIGURATION", "⚙️")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	cfg.LogSummary()
+
+	if err := confirm(cfg, "Please confirm the configuration above. Press Enter to proceed with the build: "); err != nil {
+		return err
+	}
+	run := cfg.StartRun("build")
+	defer func() { run.Finish(err) }()
+	ctx := cmd.Context()
+
+	// Step 2: Validation
+	logSection(2, "VALIDATION", "✓")
+
+	validator := NewValidator(cfg)
+	if err := runStage(ctx, cfg, run, "validation", func(ctx context.Context) error {
+		return logValidationResults(validator.WithContext(ctx).ValidateBuild())
+	}); err != nil {
+		return err
+	}
+
+	// Step 3: Docker Workflow
+	logSection(3, "DOCKER WORKFLOW", "🐳")
+
+	dockerRunner := NewDockerRunner(cfg)
+	if err := runStage(ctx, cfg, run, "docker", func(ctx context.Context) error {
+		if output != "" {
+			err = dockerRunner.Export(ctx, output)
+		} else {
+			err = dockerRunner.Run(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("❌ docker workflow failed: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	logSection(0, "BUILD COMPLETE", "🎉")
+
+	return nil
+}
+
+func runPromoteImage(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return err
+	}
+	if to == "" {
+		return fmt.Errorf("❌ --to is required")
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+
+	if from == "" {
+		if from, err = cfg.ImageTag(); err != nil {
+			return fmt.Errorf("❌ --from is required: %w", err)
+		}
+	}
+
+	if err := confirm(cfg, fmt.Sprintf("Promote %s to %s? Press Enter to proceed: ", from, to)); err != nil {
+		return err
+	}
+
+	if err := NewDockerRunner(cfg).PromoteImage(cmd.Context(), from, to); err != nil {
+		return fmt.Errorf("❌ image promotion failed: %w", err)
+	}
+	return nil
+}
+
+func runPromote(cmd *cobra.Command, args []string) (err error) {
+	log.SetTimeFormat("")
+
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return err
+	}
+	if from == "" || to == "" {
+		return fmt.Errorf("❌ --from and --to are required")
+	}
+	if from == to {
+		return fmt.Errorf("❌ --from and --to must be different environments, got %s twice", from)
+	}
+
+	// Step 1: Configuration
+	logSection(1, "CONFIGURATION", "⚙️")
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	cfg.Env = []string{from, to}
+	if err := cfg.validateEnvNames(); err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	if !cfg.ShouldRunDockerBuild() {
+		return fmt.Errorf("❌ the artifact builds no image, so there is nothing to promote")
+	}
+	if cfg.DockerCompose {
+		return fmt.Errorf("❌ promoting the images of a compose project is not supported, please deploy it instead")
+	}
+	source, target := cfg.ForEnv(from), cfg.ForEnv(to)
+	target.LogSummary()
+
+	// Step 2: Promoted image
+	logSection(2, "PROMOTED IMAGE", "🔎")
+
+	live, err := NewHelmRunner(source).liveImage(cmd.Context())
+	if err != nil {
+		return fmt.Errorf("❌ failed to read the image running in %s: %w", from, err)
+	}
+	if live.commit == "" && target.protectsEnv(to) && !target.AllowProtected && os.Getenv(approvalTokenEnv) != "" {
+		return fmt.Errorf("❌ release %s in %s records no commit, so no approval token can approve promoting its image. Please deploy %s with helm.metadata first", source.ReleaseName(), from, from)
+	}
+	target.pinImage(live)
+	image, err := target.ImageTag()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	log.Infof("📌 %s runs %s", from, live.digest)
+	if live.commit != "" {
+		log.Infof("   Deployed from commit %s", shortCommit(live.commit))
+	}
+	log.Infof("   Promoting it to %s as %s", to, image)
+
+	if err := checkContexts(target); err != nil {
+		return err
+	}
+	run := target.StartRun("promote")
+	run.record.ImageDigest = live.digest
+	if err := run.auditStart(cmd.Context()); err != nil {
+		return err
+	}
+	run.notify(cmd.Context(), NotifyStarted)
+	defer func() {
+		run.Finish(err)
+		run.auditFinish(cmd.Context())
+		run.notify(cmd.Context(), run.record.Result)
+	}()
+
+	ctx, cancel := target.deployContext(cmd.Context())
+	defer cancel()
+
+	// Step 3: Validation
+	logSection(3, "VALIDATION", "✓")
+
+	validator := NewValidator(target)
+	if err := runStage(ctx, target, run, "validation", func(ctx context.Context) error {
+		return logValidationResults(validator.WithContext(ctx).ValidateAll())
+	}); err != nil {
+		return err
+	}
+
+	// Step 4: Helm Workflow
+	logSection(4, "HELM WORKFLOW", "⎈")
+
+	if err := runStage(ctx, target, run, "helm", func(ctx context.Context) error {
+		if err := NewHelmRunner(target).Run(ctx); err != nil {
+			return fmt.Errorf("❌ helm workflow failed: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	logSection(0, "PROMOTION COMPLETE", "🎉")
+	return nil
+}
+
+func runApprove(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	generate, err := cmd.Flags().GetBool("generate-key")
+	if err != nil {
+		return err
+	}
+	if generate {
+		privateKey, publicKey, err := GenerateApprovalKey()
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		log.Infof("🔑 Keep the private key with the approvers, in %s. Deploys only need the public key, in %s", approvalKeyEnv, approvalPublicKeyEnv)
+		fmt.Printf("%s=%s\n%s=%s\n", approvalKeyEnv, privateKey, approvalPublicKeyEnv, publicKey)
+		return nil
+	}
+
+	rev, err := cmd.Flags().GetString("commit")
+	if err != nil {
+		return err
+	}
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+	if len(cfg.Env) == 0 {
+		return fmt.Errorf("❌ --env is required")
+	}
+	key, err := approvalPrivateKey()
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+	commit, err := gitResolveCommit(rev)
+	if err != nil {
+		return fmt.Errorf("❌ %w", err)
+	}
+
+	tokens := make([]string, 0, len(cfg.Env))
+	for _, env := range cfg.Env {
+		if !cfg.protectsEnv(env) {
+			log.Warnf("⚠️  Environment %s is not protected, its deploys need no approval token", env)
+		}
+		tokens = append(tokens, ApprovalToken(key, env, commit))
+	}
+	log.Infof("🔏 Approved commit %s in %s. Pass the token in %s to the deploy", shortCommit(commit), strings.Join(cfg.Env, ", "), approvalTokenEnv)
+	fmt.Println(strings.Join(tokens, ","))
+	return nil
+}
+
+func runPrune(cmd *cobra.Command, args []string) error {
+	log.SetTimeFormat("")
+
+	keep, err := cmd.Flags().GetInt("keep")
+	if err != nil {
+		return err
+	}
+	if keep < 0 {
+		return fmt.Errorf("❌ --keep must not be negative, got %d", keep)
+	}
+
+	cfg, err := LoadConfig(cmd)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load configuration: %w", err)
+	}
+
+	dockerRunner := NewDockerRunner(cfg)
+	images, err := dockerRunner.StaleImages(cmd.Context(), keep)
+	if err != nil {
+		return fmt.Errorf("❌ failed to list local images: %w", err)
+	}
+	if len(images) == 0 {
+		log.Infof("✅ Nothing to prune, at most %d image(s) found locally", keep)
+		return nil
+	}
+
+	log.Infof("🧹 %d stale image(s) found, keeping the latest %d:", len(images), keep)
+	for _, img := range images {
+		log.Infof("   %s  %-24s  %s", img.ID, img.Tag, img.CreatedAt)
+	}
+
+	if err := confirm(cfg, "Press Enter to remove the images above: "); err != nil {
+		return err
+	}
+
+	if err := dockerRunner.RemoveImages(cmd.Context(), images); err != nil {
+		return fmt.Errorf("❌ prune failed: %w", err)
+	}
+	return nil
+}
+
+// logValidationResults logs the outcome of every validation check as a
+// table and returns the validation error, if any.
+func logValidationResults(results []ValidationResult, err error) error {
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Name))
+	}
+
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			log.Infof("⏭️  %-*s   skipped (%v)", width, r.Name, r.Err)
+		case r.Err != nil && r.Severity == SeverityWarning:
+			detail, _, _ := strings.Cut(r.Err.Error(), "\n")
+			log.Warnf("⚠️  %-*s   warning: %s", width, r.Name, detail)
+		case r.Err != nil:
+			detail, _, _ := strings.Cut(r.Err.Error(), "\n")
+			log.Errorf("❌ %-*s   failed: %s", width, r.Name, detail)
+		default:
+			log.Infof("✅ %-*s   passed", width, r.Name)
+		}
+	}
+	return err
+}
+
+// confirm waits for the user to press Enter, unless auto-approve or dry-run is enabled.
+func confirm(cfg *Config, prompt string) error {
+	if cfg.AutoApprove || cfg.DryRun {
+		return nil
+	}
+
+	fmt.Print(prompt)
+	reader := bufio.NewReader(os.Stdin)
+	if _, err := reader.ReadString('\n'); err != nil {
+		return fmt.Errorf("failed to read user input: %w", err)
+	}
+	return nil
+}
+
 func logSection(num int, title, icon string) {
 	log.Info("")
 	log.Info("═══════════════════════════════════════════════════════════════")
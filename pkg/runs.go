@@ -0,0 +1,247 @@
+package pkg
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Results of a recorded run.
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// RunRecord is a run of deploy or build, as recorded in history.file. Unlike
+// the Helm release history, it links each run to the commit and the image it
+// deployed.
+type RunRecord struct {
+	ID          string    `json:"id"`
+	Command     string    `json:"command"`
+	StartedAt   time.Time `json:"startedAt"`
+	Seconds     float64   `json:"durationSeconds"`
+	User        string    `json:"user"`
+	Version     string    `json:"version"`
+	Commit      string    `json:"commit,omitempty"`
+	Dirty       bool      `json:"dirty,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	ImageDigest string    `json:"imageDigest,omitempty"`
+	Envs        []string  `json:"envs,omitempty"`
+	Context     string    `json:"context,omitempty"`
+	Namespace   string    `json:"namespace,omitempty"`
+	Release     string    `json:"release,omitempty"`
+	DryRun      bool      `json:"dryRun,omitempty"`
+	Digest      string    `json:"configDigest,omitempty"`
+	Completed   []string  `json:"completed,omitempty"`   // pipeline stages the run completed
+	ResumedFrom string    `json:"resumedFrom,omitempty"` // run whose completed stages were skipped
+	Result      string    `json:"result"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// Duration returns how long the run took.
+func (r RunRecord) Duration() time.Duration {
+	return time.Duration(r.Seconds * float64(time.Second)).Round(time.Second)
+}
+
+// runRecorder records a run in the history and writes its report once it
+// finished.
+type runRecorder struct {
+	cfg    *Config
+	record RunRecord
+
+	mu       sync.Mutex
+	stages   []StageReport
+	releases []ReleaseReport
+}
+
+// StartRun starts recording a run of command. Runs are not recorded if
+// history.file is empty, and not reported if report.file is empty.
+func (c *Config) StartRun(command string) *runRecorder {
+	metadata := newDeploymentMetadata(c)
+	if c.imageCommit != "" {
+		// A promotion deploys the image of the commit the source release was deployed from
+		metadata.Commit = c.imageCommit
+	}
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+
+	record := RunRecord{
+		ID:        metadata.DeployedAt.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Command:   command,
+		StartedAt: metadata.DeployedAt,
+		User:      metadata.DeployedBy,
+		Version:   metadata.Version,
+		Commit:    metadata.Commit,
+		Dirty:     metadata.Dirty,
+		Envs:      c.Env,
+		Context:   c.KubernetesContext,
+		Namespace: c.KubernetesNamespace,
+		Release:   c.ReleaseName(),
+		DryRun:    c.DryRun,
+	}
+	digest, err := c.runDigest()
+	if err != nil {
+		log.Warnf("⚠️  %v, the run can't be resumed", err)
+	}
+	record.Digest = digest
+	if c.ShouldRunDockerBuild() {
+		record.Image, _ = c.ImageTag()
+	}
+	c.run = &runRecorder{cfg: c, record: record}
+	return c.run
+}
+
+// Finish records the run with its result and writes its report. Failing to
+// do so is only logged, as the run itself is over.
+func (r *runRecorder) Finish(err error) {
+	r.record.Seconds = time.Since(r.record.StartedAt).Seconds()
+	r.record.Result = RunSucceeded
+	if err != nil {
+		r.record.Result = RunFailed
+		r.record.Error = strings.TrimSpace(strings.TrimPrefix(err.Error(), "❌"))
+	}
+	if r.cfg.HistoryFile == "" && r.cfg.ReportFile == "" && len(r.cfg.AuditBackends) == 0 {
+		return
+	}
+	if r.record.Image != "" && r.record.ImageDigest == "" && err == nil && !r.cfg.DryRun && r.cfg.DockerBuilder == BuilderDocker {
+		r.record.ImageDigest = imageDigest(r.record.Image)
+	}
+
+	if r.cfg.ReportFile != "" {
+		if err := r.writeReport(); err != nil {
+			log.Warnf("⚠️  Failed to write the run report to %s: %v", r.cfg.ReportFile, err)
+		} else {
+			log.Infof("📄 Wrote the run report to %s", r.cfg.ReportFile)
+		}
+	}
+	if r.cfg.HistoryFile == "" {
+		return
+	}
+	if err := appendRun(r.cfg.HistoryFile, r.record); err != nil {
+		log.Warnf("⚠️  Failed to record the run in %s: %v", r.cfg.HistoryFile, err)
+		return
+	}
+	log.Debugf("Recorded run %s in %s", r.record.ID, r.cfg.HistoryFile)
+}
+
+// complete records that the run completed a pipeline stage.
+func (r *runRecorder) complete(stage string) {
+	r.record.Completed = append(r.record.Completed, stage)
+}
+
+// completed reports whether the run, or the run it resumes, completed a
+// pipeline stage.
+func (r *runRecorder) completed(stage string) bool {
+	return slices.Contains(r.record.Completed, stage)
+}
+
+// imageDigest returns the registry digest of a pushed image, if the local
+// Docker store knows it.
+func imageDigest(image string) string {
+	out, err := exec.Command("docker", "image", "inspect", "--format", "{{join .RepoDigests \"\\n\"}}", image).Output()
+	if err != nil {
+		return ""
+	}
+	repo := image[:strings.LastIndex(image, ":")]
+	for _, digest := range strings.Fields(string(out)) {
+		if name, sum, ok := strings.Cut(digest, "@"); ok && name == repo {
+			return sum
+		}
+	}
+	return ""
+}
+
+// defaultHistoryFile returns the history file of the artifact in the user
+// cache directory. Recording runs in the working tree would leave it with
+// uncommitted changes after every run. Without a cache directory, runs are
+// not recorded.
+func defaultHistoryFile(artifact string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		log.Debugf("Runs are not recorded: %v", err)
+		return ""
+	}
+	if artifact = filepath.Base(artifact); artifact == "." || artifact == ".." || artifact == string(filepath.Separator) {
+		artifact = "default"
+	}
+	return filepath.Join(cacheDir, "dockwright", artifact, "history.jsonl")
+}
+
+// appendRun appends a run to the history file, one JSON object per line.
+func appendRun(path string, record RunRecord) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadRuns returns the runs recorded in the history file, most recent first.
+// A missing file has no runs.
+func ReadRuns(path string) ([]RunRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	defer f.Close()
+
+	var runs []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var record RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("invalid run in %s:%d: %w", path, line, err)
+		}
+		runs = append(runs, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	slices.Reverse(runs)
+	return runs, nil
+}
+
+// FindRun returns the run with the given id, or the most recent run whose id
+// starts with it.
+func FindRun(runs []RunRecord, id string) (RunRecord, error) {
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+	}
+	for _, run := range runs {
+		if strings.HasPrefix(run.ID, id) {
+			return run, nil
+		}
+	}
+	return RunRecord{}, fmt.Errorf("no run with id '%s' in the run history. Please list the runs with dockwright runs list", id)
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// envValuesFiles returns the paths of the plain and the SOPS-encrypted values
+// file of an environment. Either or both may exist.
+func envValuesFiles(env string) (string, string) {
+	dir := filepath.Join(".dockwright", "helm")
+	return filepath.Join(dir, fmt.Sprintf("%s.values.yaml", env)),
+		filepath.Join(dir, fmt.Sprintf("%s.values.enc.yaml", env))
+}
+
+// generatedValues holds values files generated for a deployment, such as
+// files decrypted with sops or rendered templates, in a private temporary
+// directory, which is removed by cleanup.
+type generatedValues struct {
+	dir       string
+	decrypted map[string]bool // paths of the files decrypted with sops
+}
+
+// write writes content to the file name in the temporary directory and returns its path.
+func (d *generatedValues) write(name string, content []byte) (string, error) {
+	if d.dir == "" {
+		dir, err := os.MkdirTemp("", "dockwright-values-")
+		if err != nil {
+			return "", fmt.Errorf("failed to create directory for generated values: %w", err)
+		}
+		d.dir = dir
+	}
+
+	target := filepath.Join(d.dir, name)
+	if err := os.WriteFile(target, content, 0o600); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// decrypt decrypts the SOPS-encrypted file at path and returns the path of
+// the plaintext copy.
+func (d *generatedValues) decrypt(path string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s with sops: %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+
+	target, err := d.write(filepath.Base(path), out)
+	if err != nil {
+		return "", fmt.Errorf("failed to write decrypted values of %s: %w", path, err)
+	}
+	if d.decrypted == nil {
+		d.decrypted = make(map[string]bool)
+	}
+	d.decrypted[target] = true
+	return target, nil
+}
+
+func (d *generatedValues) cleanup() {
+	if d.dir != "" {
+		_ = os.RemoveAll(d.dir)
+	}
+}
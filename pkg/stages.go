@@ -0,0 +1,190 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"gopkg.in/yaml.v3"
+)
+
+// pipelineStages are the built-in stages of a deploy, in order. Custom
+// stages run after one of them.
+var pipelineStages = []string{"validation", "docker", "helm"}
+
+// stageNamePattern matches the names of custom stages, which are also their
+// names in the run history and reports.
+var stageNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// CustomStage is a command run as a stage of its own in the deploy pipeline,
+// after one of the built-in stages, declared in the stages section of the
+// config file:
+//
+//	stages:
+//	  - name: integration-tests
+//	    after: docker
+//	    run: make integration-test
+//	    when: '{{ ne .Env "production" }}'
+//	    env:
+//	      BASE_URL: https://${CLUSTER_DOMAIN}
+//	    timeout: 10m
+type CustomStage struct {
+	Name      string            `yaml:"name"`
+	After     string            `yaml:"after"`     // validation, docker or helm
+	Run       string            `yaml:"run"`       // run with sh
+	When      string            `yaml:"when"`      // Go template rendering true or false, always run if empty
+	Env       map[string]string `yaml:"env"`       // environment variables are expanded
+	Timeout   time.Duration     `yaml:"timeout"`   // 0 for none
+	OnFailure string            `yaml:"onFailure"` // abort (default) or warn
+}
+
+// stageConditionData is what the when condition of a custom stage is
+// rendered with.
+type stageConditionData struct {
+	ArtifactName string
+	Env          string // empty when several environments are deployed
+	Envs         []string
+	Context      string
+	Namespace    string
+	Release      string
+	Image        string
+	DryRun       bool
+}
+
+// loadCustomStages reads the stages section of the config file.
+func loadCustomStages() ([]CustomStage, error) {
+	raw, ok := rawConfigValue("stages")
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	content, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var stages []CustomStage
+	if err := yaml.Unmarshal(content, &stages); err != nil {
+		return nil, fmt.Errorf("invalid stages section: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, s := range stages {
+		switch {
+		case !stageNamePattern.MatchString(s.Name):
+			return nil, fmt.Errorf("stages[%d]: invalid name '%s', expected lowercase letters, digits and dashes", i, s.Name)
+		case slices.Contains(pipelineStages, s.Name) || seen[s.Name]:
+			return nil, fmt.Errorf("stages[%d]: the name '%s' is already taken by another stage", i, s.Name)
+		case !slices.Contains(pipelineStages, s.After):
+			return nil, fmt.Errorf("stages[%d]: invalid after '%s', expected one of %s", i, s.After, strings.Join(pipelineStages, ", "))
+		case s.Run == "":
+			return nil, fmt.Errorf("stages[%d]: run is required", i)
+		case s.OnFailure != "" && s.OnFailure != "abort" && s.OnFailure != "warn":
+			return nil, fmt.Errorf("stages[%d]: invalid onFailure '%s', expected 'abort' or 'warn'", i, s.OnFailure)
+		case s.Timeout < 0:
+			return nil, fmt.Errorf("stages[%d]: timeout must not be negative, got %s", i, s.Timeout)
+		}
+		if _, err := template.New("").Parse(s.When); err != nil {
+			return nil, fmt.Errorf("stages[%d]: invalid when condition: %w", i, err)
+		}
+		seen[s.Name] = true
+	}
+	return stages, nil
+}
+
+// runCustomStages runs the custom stages following a built-in stage, in the
+// order they are declared. Like the built-in stages, they are recorded in the
+// run and skipped when resuming a run that completed them.
+func runCustomStages(ctx context.Context, cfg *Config, run *runRecorder, after string) error {
+	for _, s := range cfg.Stages {
+		if s.After != after {
+			continue
+		}
+		ok, err := s.enabled(cfg)
+		if err != nil {
+			return fmt.Errorf("❌ %w", err)
+		}
+		if !ok {
+			log.Infof("⏭️  Skipping the %s stage, its when condition is false", s.Name)
+			run.stage(s.Name, StageSkipped, "when condition is false", time.Now())
+			continue
+		}
+
+		logSection(0, strings.ToUpper(s.Name), "🧩")
+		if err := runStage(ctx, cfg, run, s.Name, func(ctx context.Context) error {
+			return s.run(ctx, cfg)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enabled renders the when condition of the stage.
+func (s CustomStage) enabled(cfg *Config) (bool, error) {
+	if s.When == "" {
+		return true, nil
+	}
+	tmpl, err := template.New(s.Name).Option("missingkey=error").Parse(s.When)
+	if err != nil {
+		return false, err
+	}
+	image, _ := cfg.ImageTag()
+	data := stageConditionData{
+		ArtifactName: cfg.ArtifactName,
+		Env:          cfg.EnvName(),
+		Envs:         cfg.Env,
+		Context:      cfg.KubernetesContext,
+		Namespace:    cfg.KubernetesNamespace,
+		Release:      cfg.ReleaseName(),
+		Image:        image,
+		DryRun:       cfg.DryRun,
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return false, fmt.Errorf("failed to render the when condition of stage %s: %w", s.Name, err)
+	}
+	enabled, err := strconv.ParseBool(strings.TrimSpace(out.String()))
+	if err != nil {
+		return false, fmt.Errorf("the when condition of stage %s rendered '%s', expected true or false", s.Name, strings.TrimSpace(out.String()))
+	}
+	return enabled, nil
+}
+
+// run runs the stage's command with sh, with the deployment in DOCKWRIGHT_*
+// environment variables as for hooks. Dry-runs only log the command.
+func (s CustomStage) run(ctx context.Context, cfg *Config) error {
+	log.Infof("🧩 Running stage %s: %s", s.Name, s.Run)
+	if cfg.DryRun {
+		log.Infof("   🧪 [DRY-RUN] Would run: sh -c %q", s.Run)
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.Timeout, "stage "+s.Name, fmt.Sprintf("stages[%s].timeout", s.Name))
+	defer cancel()
+
+	cmd := command(ctx, "sh", "-c", s.Run)
+	cmd.Env = append(os.Environ(), cfg.pipelineEnv()...)
+	cmd.Env = append(cmd.Env, "DOCKWRIGHT_STAGE="+s.Name)
+	for name, value := range s.Env {
+		cmd.Env = append(cmd.Env, name+"="+os.ExpandEnv(value))
+	}
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+
+	started := time.Now()
+	if err := cmd.Run(); err != nil {
+		err = stageError(ctx, err)
+		if s.OnFailure == "warn" {
+			log.Warnf("⚠️  Stage %s failed: %v", s.Name, err)
+			return nil
+		}
+		return fmt.Errorf("❌ stage %s failed: %w", s.Name, err)
+	}
+	log.Infof("✓  Stage %s passed in %s", s.Name, time.Since(started).Round(100*time.Millisecond))
+	return nil
+}
@@ -0,0 +1,33 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// withTimeout bounds ctx by timeout, if it is set. When the timeout expires,
+// the context's cause names the setting that imposed it.
+func withTimeout(ctx context.Context, timeout time.Duration, what, setting string) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%s timed out after %s (%s)", what, timeout, setting))
+}
+
+// deployContext bounds a deploy by deploy.timeout.
+func (c *Config) deployContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withTimeout(ctx, c.DeployTimeout, "the deploy", "deploy.timeout")
+}
+
+// stageContext bounds a pipeline stage by its timeout. The docker stage is
+// bounded by docker.buildTimeout in the DockerRunner itself.
+func (c *Config) stageContext(ctx context.Context, stage string) (context.Context, context.CancelFunc) {
+	switch stage {
+	case "validation":
+		return withTimeout(ctx, c.ValidationTimeout, "the validation", "validation.timeout")
+	case "helm":
+		return withTimeout(ctx, c.HelmWorkflowTimeout, "the helm workflow", "helm.workflowTimeout")
+	}
+	return context.WithCancel(ctx)
+}
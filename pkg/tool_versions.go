@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/charmbracelet/log"
+)
+
+// defaultToolMinVersions are the oldest tool versions Dockwright works with:
+// docker with BuildKit and buildx, buildah with --platform support, and helm
+// with OCI registry support for the helm-diff plugin. tools.minVersions can
+// raise them.
+var defaultToolMinVersions = map[string]string{
+	"docker":  "20.10",
+	"buildah": "1.23",
+	"helm":    "3.8",
+}
+
+// toolVersionArgs are the arguments that print a tool's version.
+var toolVersionArgs = map[string][]string{
+	"docker":  {"version", "--format", "{{.Client.Version}}"},
+	"buildah": {"--version"},
+	"helm":    {"version", "--template", "{{.Version}}"},
+}
+
+// toolUpgradeHints tell where to get a newer version of a tool.
+var toolUpgradeHints = map[string]string{
+	"docker":  "https://docs.docker.com/engine/install/",
+	"buildah": "https://github.com/containers/buildah/blob/main/install.md",
+	"helm":    "https://helm.sh/docs/intro/install/",
+}
+
+// versionPattern matches the first version number in a tool's output, such
+// as 24.0.7 in "Docker version 24.0.7, build afdd53b".
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// toolMinVersion returns the minimum version of tool, if it has one.
+func (c *Config) toolMinVersion(tool string) string {
+	if minimum, ok := c.ToolMinVersions[tool]; ok {
+		return minimum
+	}
+	return defaultToolMinVersions[tool]
+}
+
+// checkToolVersion fails if the installed version of tool is older than its
+// minimum version. A version that cannot be determined is not checked, as
+// the tool itself fails clearly enough if it is broken.
+func (c *Config) checkToolVersion(tool string) error {
+	minimum := c.toolMinVersion(tool)
+	if minimum == "" {
+		return nil
+	}
+	minVersion, err := semver.NewVersion(minimum)
+	if err != nil {
+		return fmt.Errorf("invalid minimum version '%s' of %s in tools.minVersions: %w", minimum, tool, err)
+	}
+
+	args, ok := toolVersionArgs[tool]
+	if !ok {
+		args = []string{"--version"}
+	}
+	out, err := command(context.Background(), tool, args...).Output()
+	if err != nil {
+		log.Debugf("Could not determine the version of %s: %v", tool, err)
+		return nil
+	}
+	installed := versionPattern.FindString(string(out))
+	version, err := semver.NewVersion(installed)
+	if err != nil {
+		log.Debugf("Could not parse the version of %s from %q", tool, strings.TrimSpace(string(out)))
+		return nil
+	}
+
+	if version.LessThan(minVersion) {
+		hint := ""
+		if url := toolUpgradeHints[tool]; url != "" {
+			hint = ", see " + url
+		}
+		return fmt.Errorf("%s %s is older than the required version %s. Please upgrade %s%s", tool, installed, minimum, tool, hint)
+	}
+	log.Debugf("%s %s satisfies the minimum version %s", tool, installed, minimum)
+	return nil
+}
+
+// validateToolMinVersions checks that tools.minVersions only lists valid
+// versions, so a typo doesn't go unnoticed until the tool is used.
+func (c *Config) validateToolMinVersions() error {
+	for tool, minimum := range c.ToolMinVersions {
+		if _, err := semver.NewVersion(minimum); err != nil {
+			return fmt.Errorf("invalid minimum version '%s' of %s in tools.minVersions. Please use a version such as 3.12 or 3.12.0", minimum, tool)
+		}
+	}
+	return nil
+}
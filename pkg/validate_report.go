@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// sarifSchema is the JSON schema of the SARIF 2.1.0 log format, which code
+// scanning tools such as GitHub's annotate pull requests from.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// CheckReport is the machine-readable outcome of a validation check.
+type CheckReport struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Status      string `json:"status"` // passed, failed, warning or skipped
+	Severity    string `json:"severity,omitempty"`
+	Message     string `json:"message,omitempty"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// ValidationReport is the machine-readable outcome of a validation run.
+type ValidationReport struct {
+	Passed bool          `json:"passed"`
+	Checks []CheckReport `json:"checks"`
+}
+
+// NewValidationReport converts validation results into a report.
+func NewValidationReport(results []ValidationResult, err error) ValidationReport {
+	report := ValidationReport{Passed: err == nil, Checks: []CheckReport{}}
+	for _, r := range results {
+		check := CheckReport{ID: r.ID, Name: r.Name, Status: "passed"}
+		switch {
+		case r.Skipped:
+			check.Status = "skipped"
+			check.Message = r.Err.Error()
+		case r.Err != nil:
+			check.Status, check.Severity = "failed", "error"
+			if r.Severity == SeverityWarning {
+				check.Status, check.Severity = "warning", "warning"
+			}
+			check.Message, check.Remediation = splitRemediation(r.Err.Error())
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r ValidationReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteSARIF writes the report as a SARIF log with a rule per check and a
+// result per failure or warning, located in the configuration file if one
+// was used.
+func (r ValidationReport) WriteSARIF(w io.Writer) error {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type rule struct {
+		ID               string  `json:"id"`
+		Name             string  `json:"name"`
+		ShortDescription message `json:"shortDescription"`
+	}
+	type location struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+		} `json:"physicalLocation"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations,omitempty"`
+	}
+
+	var locations []location
+	if file := viper.ConfigFileUsed(); file != "" {
+		var loc location
+		loc.PhysicalLocation.ArtifactLocation.URI = filepath.ToSlash(relativePath(file))
+		locations = []location{loc}
+	}
+
+	rules := []rule{}
+	results := []result{}
+	for _, check := range r.Checks {
+		rules = append(rules, rule{ID: check.ID, Name: check.Name, ShortDescription: message{Text: check.Name + " validation"}})
+		if check.Severity == "" {
+			continue
+		}
+		text := check.Message
+		if check.Remediation != "" {
+			text += ". " + check.Remediation
+		}
+		results = append(results, result{RuleID: check.ID, Level: check.Severity, Message: message{Text: text}, Locations: locations})
+	}
+
+	sarif := map[string]any{
+		"$schema": sarifSchema,
+		"version": "2.1.0",
+		"runs": []any{map[string]any{
+			"tool": map[string]any{"driver": map[string]any{
+				"name":    "dockwright",
+				"version": Version,
+				"rules":   rules,
+			}},
+			"results": results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarif)
+}
+
+// splitRemediation splits an error message into the problem and the advice
+// to fix it, which the messages give in a trailing "Please ..." sentence.
+// Messages listing several problems are kept whole.
+func splitRemediation(msg string) (string, string) {
+	if strings.Contains(msg, "\n") {
+		return msg, ""
+	}
+	if i := strings.Index(msg, ". Please "); i >= 0 {
+		return msg[:i], msg[i+2:]
+	}
+	return msg, ""
+}
+
+// relativePath returns path relative to the working directory if it is
+// inside it.
+func relativePath(path string) string {
+	wd, err := filepath.Abs(".")
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return rel
+}
@@ -1,67 +1,362 @@
 package pkg
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
-	"gopkg.in/yaml.v3"
+	"github.com/charmbracelet/log"
+	"helm.sh/helm/v3/pkg/registry"
 )
 
 // Validator handles all pre-deployment validation checks.
 type Validator struct {
 	cfg *Config
+	ctx context.Context
 }
 
 // NewValidator creates a new Validator with the given configuration.
 func NewValidator(cfg *Config) *Validator {
-	return &Validator{cfg: cfg}
+	return &Validator{cfg: cfg, ctx: context.Background()}
 }
 
+// WithContext makes the validator stop running checks once ctx is done,
+// failing the running checks and skipping the others.
+func (v *Validator) WithContext(ctx context.Context) *Validator {
+	v.ctx = ctx
+	return v
+}
+
+// Severity is how a problem found by a validation check affects the run.
+type Severity int
+
+const (
+	// SeverityError fails the validation.
+	SeverityError Severity = iota
+	// SeverityWarning is reported, but only fails the validation with --strict.
+	SeverityWarning
+)
+
 // ValidationResult represents the outcome of a validation check.
 type ValidationResult struct {
-	Name    string
-	Icon    string
-	Message string
-	Err     error
+	ID       string
+	Name     string
+	Icon     string
+	Message  string
+	Err      error
+	Severity Severity // of Err
+	Skipped  bool     // by --skip-checks, or as a check it needs failed
+}
+
+// validationWarning is a problem that does not block the run by itself.
+type validationWarning struct {
+	err error
+}
+
+func (w *validationWarning) Error() string { return w.err.Error() }
+func (w *validationWarning) Unwrap() error { return w.err }
+
+// isWarning reports whether err is a validation problem with warning
+// severity.
+func isWarning(err error) bool {
+	var warning *validationWarning
+	return errors.As(err, &warning)
+}
+
+// warningf returns a validation problem with warning severity.
+func warningf(format string, args ...any) error {
+	return &validationWarning{err: fmt.Errorf(format, args...)}
+}
+
+// validationCheck is a single named validation step. Its id is stable, for
+// --skip-checks and the machine-readable output. Checks that wait on the
+// cluster, the registry or a command stop once ctx is done.
+type validationCheck struct {
+	id   string
+	name string
+	icon string
+	fn   func(ctx context.Context) error
 }
 
-// ValidateAll runs all validation checks and returns the first error encountered.
+// withoutContext adapts a check that only reads local state, and so returns
+// quickly, to the check signature.
+func withoutContext(fn func() error) func(context.Context) error {
+	return func(context.Context) error { return fn() }
+}
+
+// validationNeeds maps checks to the check they build on. A check is skipped
+// when the check it needs failed, as it would only fail the same way.
+var validationNeeds = map[string]string{
+	"registry-credentials": "env-vars",
+	"chart-lint":           "values-files",
+	"values-schema":        "values-files",
+	"flavour-contract":     "values-files",
+	"image-values":         "values-files",
+	"kubeconfig":           "kube-context",
+	"kube-permissions":     "kube-context",
+	"kube-version":         "kube-context",
+	"capacity":             "kube-context",
+	"node-platforms":       "kube-context",
+	"blue-green-service":   "kube-context",
+}
+
+// offlineChecks need the network, the cluster or the Docker daemon, and are
+// skipped with --offline.
+var offlineChecks = map[string]bool{
+	"registry-settings":    true,
+	"registry-credentials": true,
+	"kube-permissions":     true,
+	"kube-version":         true,
+	"capacity":             true,
+	"node-platforms":       true,
+	"blue-green-service":   true,
+}
+
+// stageChecks are only relevant to a stage of the pipeline, and are skipped
+// when --skip-docker or --skip-helm skips it.
+var stageChecks = map[string]string{
+	"builder":            "docker",
+	"registry-settings":  "docker",
+	"disk-space":         "docker",
+	"git":                "helm",
+	"kube-context":       "helm",
+	"kubeconfig":         "helm",
+	"chart-lint":         "helm",
+	"values-schema":      "helm",
+	"flavour-contract":   "helm",
+	"image-values":       "helm",
+	"kube-permissions":   "helm",
+	"kube-version":       "helm",
+	"capacity":           "helm",
+	"node-platforms":     "helm",
+	"blue-green-service": "helm",
+}
+
+// validationWorkers is the number of validation checks run concurrently. Most
+// checks wait on the cluster, the registry or local tools.
+const validationWorkers = 4
+
+// chartChecks render the releases' charts or resolve their flavour, which
+// may download flavours and chart dependencies into shared directories, so
+// they run one at a time.
+var chartChecks = map[string]bool{
+	"helm-flavour":     true,
+	"chart-lint":       true,
+	"values-schema":    true,
+	"flavour-contract": true,
+	"image-values":     true,
+	"kube-permissions": true,
+	"kube-version":     true,
+	"capacity":         true,
+}
+
+// ValidateAll runs all validation checks and returns an error combining all
+// failures.
 func (v *Validator) ValidateAll() ([]ValidationResult, error) {
-	checks := []struct {
-		name string
-		icon string
-		fn   func() error
-	}{
-		{"Configuration", "✅", v.validateConfig},
-		{"Helm flavour", "⎈ ", v.validateHelmFlavour},
-		{"Environment variables", "🔐", v.validateEnvVars},
-		{"Environment values files", "📄", v.validateEnvValueFiles},
-		{"Kubernetes context", "☸️ ", v.validateKubeContext},
-		{"System tools", "🛠️ ", v.validateTools},
-	}
-
-	var results []ValidationResult
-
-	for _, check := range checks {
-		err := check.fn()
-		result := ValidationResult{
-			Name:    check.name,
-			Icon:    check.icon,
-			Message: fmt.Sprintf("%s Validated - %s", check.icon, check.name),
-			Err:     err,
-		}
-		results = append(results, result)
+	return v.run(v.allChecks())
+}
 
-		if err != nil {
-			return results, err
+// ValidateBuild runs only the checks relevant to building images.
+func (v *Validator) ValidateBuild() ([]ValidationResult, error) {
+	return v.run(v.buildChecks())
+}
+
+// allChecks returns the checks of ValidateAll. Checks whose id is taken by
+// an earlier check are left out, and reported by the config check.
+func (v *Validator) allChecks() []validationCheck {
+	seen := make(map[string]bool)
+	return slices.DeleteFunc(v.declaredChecks(), func(check validationCheck) bool {
+		duplicate := seen[check.id]
+		seen[check.id] = true
+		return duplicate
+	})
+}
+
+// declaredChecks returns the built-in checks followed by the custom and
+// plugin checks.
+func (v *Validator) declaredChecks() []validationCheck {
+	checks := []validationCheck{
+		{"config", "Configuration", "✅", withoutContext(v.validateConfig)},
+		{"helm-flavour", "Helm flavour", "⎈ ", withoutContext(v.validateHelmFlavour)},
+		{"strategy", "Deployment strategy", "🔀", withoutContext(v.validateStrategy)},
+		{"builder", "Image builder", "🔨", withoutContext(v.validateBuilder)},
+		{"compose", "Compose project", "🐙", withoutContext(v.validateCompose)},
+		{"registry-settings", "Registry settings", "🪞", v.validateRegistrySettings},
+		{"env-vars", "Environment variables", "🔐", withoutContext(v.validateEnvVars)},
+		{"git", "Git working tree", "🌿", withoutContext(v.cfg.CheckGitTree)},
+		{"registry-credentials", "Registry credentials", "🔏", withoutContext(v.validateRegistryCredentials)},
+		{"values-files", "Environment values files", "📄", withoutContext(v.validateEnvValueFiles)},
+		{"kube-context", "Kubernetes context", "☸️ ", withoutContext(v.validateKubeContext)},
+		{"kubeconfig", "Kubeconfig health", "🔒", withoutContext(v.cfg.CheckKubeconfigHealth)},
+		{"tools", "System tools", "🛠️ ", v.validateTools},
+		{"disk-space", "Disk space", "💾", v.cfg.CheckDiskSpace},
+		{"chart-lint", "Helm chart lint", "🔎", withoutContext(v.validateChartLint)},
+		{"values-schema", "Values schema", "📐", withoutContext(v.validateValuesSchema)},
+		{"flavour-contract", "Flavour contract", "📜", withoutContext(v.validateFlavourContract)},
+		{"image-values", "Image values", "🖼️ ", withoutContext(v.validateImageValues)},
+		{"kube-permissions", "Kubernetes permissions", "🔑", v.validatePermissions},
+		{"kube-version", "Kubernetes version", "🏷️ ", withoutContext(v.validateKubeVersion)},
+		{"capacity", "Cluster capacity", "📦", v.validateCapacity},
+		{"node-platforms", "Node platforms", "🖥️ ", v.validateNodePlatforms},
+		{"blue-green-service", "Blue/green Service", "🔀", v.validateBlueGreenService},
+	}
+	checks = append(checks, v.customChecks()...)
+	return append(checks, v.pluginChecks()...)
+}
+
+func (v *Validator) buildChecks() []validationCheck {
+	return []validationCheck{
+		{"builder", "Image builder", "🔨", withoutContext(v.validateBuilder)},
+		{"compose", "Compose project", "🐙", withoutContext(v.validateCompose)},
+		{"registry-settings", "Registry settings", "🪞", v.validateRegistrySettings},
+		{"registry-credentials", "Registry credentials", "🔏", withoutContext(v.validateRegistryCredentials)},
+		{"build-tools", "Build tools", "🛠️ ", v.validateBuildTools},
+		{"disk-space", "Disk space", "💾", v.cfg.CheckDiskSpace},
+	}
+}
+
+// validateSkipChecks fails if validation.skipChecks lists an unknown check,
+// as a typo would silently run the check it was meant to skip.
+func (v *Validator) validateSkipChecks() error {
+	var ids []string
+	for _, check := range append(v.allChecks(), v.buildChecks()...) {
+		ids = append(ids, check.id)
+	}
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	for _, id := range v.cfg.ValidationSkipChecks {
+		if !slices.Contains(ids, id) {
+			return fmt.Errorf("unknown validation check '%s' in --skip-checks. Available checks: %s", id, strings.Join(ids, ", "))
 		}
 	}
+	return nil
+}
+
+// run executes all of the given checks, so that every problem is reported at
+// once, and returns an error combining their failures. Up to
+// validationWorkers checks run at a time, each after the check it needs, and
+// the results keep the order of the checks. Warnings only count as failures
+// with --strict, and never skip the checks after them.
+func (v *Validator) run(checks []validationCheck) ([]ValidationResult, error) {
+	if err := v.validateSkipChecks(); err != nil {
+		return nil, err
+	}
 
+	results := make([]ValidationResult, len(checks))
+	done := make(map[string]chan struct{}, len(checks))
+	index := make(map[string]int, len(checks))
+	for i, check := range checks {
+		done[check.id] = make(chan struct{})
+		index[check.id] = i
+	}
+
+	workers := make(chan struct{}, validationWorkers)
+	var chartMu sync.Mutex
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[check.id])
+
+			if slices.Contains(v.cfg.ValidationSkipChecks, check.id) {
+				results[i] = v.result(check, errors.New("--skip-checks"))
+				results[i].Skipped = true
+				return
+			}
+			if stage := stageChecks[check.id]; v.cfg.SkipsStage(stage) {
+				results[i] = v.result(check, errors.New("--skip-"+stage))
+				results[i].Skipped = true
+				return
+			}
+			if v.cfg.Offline && (offlineChecks[check.id] || chartChecks[check.id] && v.cfg.downloadsChart()) {
+				results[i] = v.result(check, errors.New("--offline"))
+				results[i].Skipped = true
+				return
+			}
+			if needs := validationNeeds[check.id]; done[needs] != nil {
+				<-done[needs]
+				if needed := results[index[needs]]; needed.failed() {
+					results[i] = v.result(check, fmt.Errorf("needs %s", needed.Name))
+					results[i].Skipped = true
+					return
+				}
+			}
+
+			select {
+			case workers <- struct{}{}:
+				defer func() { <-workers }()
+			case <-v.ctx.Done():
+				results[i] = v.result(check, context.Cause(v.ctx))
+				results[i].Skipped = true
+				return
+			}
+			if chartChecks[check.id] {
+				chartMu.Lock()
+				defer chartMu.Unlock()
+			}
+			results[i] = v.result(check, v.runCheck(check))
+		}()
+	}
+	wg.Wait()
+
+	var failures []string
+	for _, result := range results {
+		if result.failed() {
+			failures = append(failures, fmt.Sprintf("%s: %v", result.Name, result.Err))
+		}
+	}
+	if len(failures) > 0 {
+		return results, fmt.Errorf("%d validation check(s) failed:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
 	return results, nil
 }
 
+// runCheck runs a check with the validator's context, failing it with the
+// context's cause when the context ended it. The check is waited for, so that
+// it never outlives its worker slot or the chart lock.
+func (v *Validator) runCheck(check validationCheck) error {
+	if v.ctx.Err() != nil {
+		return context.Cause(v.ctx)
+	}
+	err := check.fn(v.ctx)
+	if err != nil && v.ctx.Err() != nil {
+		return context.Cause(v.ctx)
+	}
+	return err
+}
+
+// result returns the result of a check that returned err.
+func (v *Validator) result(check validationCheck, err error) ValidationResult {
+	result := ValidationResult{
+		ID:      check.id,
+		Name:    check.name,
+		Icon:    check.icon,
+		Message: fmt.Sprintf("%s Validated - %s", check.icon, check.name),
+		Err:     err,
+	}
+	if isWarning(err) && !v.cfg.Strict {
+		result.Severity = SeverityWarning
+	}
+	return result
+}
+
+// failed reports whether the check ran and failed.
+func (r ValidationResult) failed() bool {
+	return r.Err != nil && !r.Skipped && r.Severity == SeverityError
+}
+
 func (v *Validator) validateConfig() error {
 	fields := ConfigFields()
 
@@ -69,6 +364,10 @@ func (v *Validator) validateConfig() error {
 		if !field.Required {
 			continue
 		}
+		// A project-local or remote chart replaces the flavour chart
+		if field.Name == "helmFlavour" && !v.cfg.UsesFlavourChart() {
+			continue
+		}
 
 		value := v.getFieldValue(field.Name)
 		if value == "" {
@@ -76,6 +375,23 @@ func (v *Validator) validateConfig() error {
 		}
 	}
 
+	if v.cfg.RetryAttempts < 1 {
+		return fmt.Errorf("retry.attempts must be at least 1, got %d", v.cfg.RetryAttempts)
+	}
+	if err := (RetryPolicy{Backoff: v.cfg.RetryBackoff, Errors: v.cfg.RetryErrors}).validate(); err != nil {
+		return fmt.Errorf("retry: %w", err)
+	}
+	if v.cfg.DeployEnvConcurrency < 1 {
+		return fmt.Errorf("deploy.envConcurrency must be at least 1, got %d", v.cfg.DeployEnvConcurrency)
+	}
+
+	seen := make(map[string]string)
+	for _, check := range v.declaredChecks() {
+		if name, ok := seen[check.id]; ok {
+			return fmt.Errorf("the checks '%s' and '%s' share the id '%s'. Please rename the check script or plugin check", name, check.name, check.id)
+		}
+		seen[check.id] = check.name
+	}
 	return nil
 }
 
@@ -103,10 +419,189 @@ func (v *Validator) getFieldValue(name string) string {
 	}
 }
 
+func (v *Validator) validateStrategy() error {
+	switch v.cfg.HelmStrategy {
+	case StrategyRolling, StrategyBlueGreen:
+	default:
+		return fmt.Errorf("unknown helm.strategy '%s'. Please use %s or %s", v.cfg.HelmStrategy, StrategyRolling, StrategyBlueGreen)
+	}
+	if v.cfg.HelmBlueGreenGracePeriod < 0 {
+		return fmt.Errorf("helm.blueGreen.gracePeriod must not be negative")
+	}
+	if v.cfg.DeploySmokeTestPath != "" {
+		switch v.cfg.DeploySmokeTestTarget {
+		case SmokeTestService, SmokeTestIngress:
+		default:
+			return fmt.Errorf("unknown deploy.smokeTest.target '%s'. Please use %s or %s", v.cfg.DeploySmokeTestTarget, SmokeTestService, SmokeTestIngress)
+		}
+		if !strings.HasPrefix(v.cfg.DeploySmokeTestPath, "/") {
+			return fmt.Errorf("deploy.smokeTest.path '%s' must start with /", v.cfg.DeploySmokeTestPath)
+		}
+		if v.cfg.DeploySmokeTestStatus < 100 || v.cfg.DeploySmokeTestStatus > 599 {
+			return fmt.Errorf("deploy.smokeTest.status %d is not an HTTP status", v.cfg.DeploySmokeTestStatus)
+		}
+		if v.cfg.DeploySmokeTestRetries < 0 || v.cfg.DeploySmokeTestTimeout <= 0 {
+			return fmt.Errorf("deploy.smokeTest.retries must not be negative and deploy.smokeTest.timeout must be positive")
+		}
+	}
+	return nil
+}
+
 func (v *Validator) validateHelmFlavour() error {
+	if v.cfg.HelmChartPath != "" && v.cfg.HelmChart != "" {
+		return fmt.Errorf("helm.chartPath and helm.chart are mutually exclusive. Please configure either a project-local or a remote chart")
+	}
+
+	if v.cfg.HelmChart != "" {
+		if v.cfg.IsOCIChart() {
+			if v.cfg.HelmRepository != "" {
+				return fmt.Errorf("helm.repository must not be set for OCI chart '%s'", v.cfg.HelmChart)
+			}
+			return nil
+		}
+		if v.cfg.HelmRepository == "" {
+			return fmt.Errorf("remote helm chart '%s' requires helm.repository to be set", v.cfg.HelmChart)
+		}
+		return nil
+	}
+
+	if v.cfg.HelmChartPath != "" {
+		chartFile := filepath.Join(v.cfg.HelmChartPath, "Chart.yaml")
+		if _, err := os.Stat(chartFile); err != nil {
+			return fmt.Errorf("project-local helm chart not found: %s does not exist. Please check helm.chartPath", chartFile)
+		}
+		return nil
+	}
+
 	flavour := v.cfg.HelmFlavour
-	if flavour != "stateful" && flavour != "stateless" {
-		return fmt.Errorf("invalid helm flavour: expected 'stateful' or 'stateless', but got '%s'", flavour)
+	if err := checkFlavourName(flavour); err != nil {
+		return err
+	}
+
+	// Flavours of an OCI source can't be listed and are checked when pulled
+	if registry.IsOCI(v.cfg.HelmFlavourSource) && !slices.Contains(builtinFlavours, flavour) {
+		return nil
+	}
+
+	flavours, err := v.cfg.Flavours()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(flavours, flavour) {
+		return fmt.Errorf("invalid helm flavour: expected one of '%s', but got '%s'", strings.Join(flavours, "', '"), flavour)
+	}
+	return nil
+}
+
+func (v *Validator) validateBuilder() error {
+	builder := v.cfg.DockerBuilder
+	if builder != BuilderDocker && builder != BuilderBuildah && builder != BuilderKaniko {
+		return fmt.Errorf("invalid docker builder: expected '%s', '%s' or '%s', but got '%s'", BuilderDocker, BuilderBuildah, BuilderKaniko, builder)
+	}
+	if v.cfg.IsMultiPlatform() && builder != BuilderDocker {
+		return fmt.Errorf("multi-platform builds (--docker-platforms) require the '%s' builder, but got '%s'", BuilderDocker, builder)
+	}
+	if v.cfg.IsMultiPlatform() && v.cfg.DockerMaxSizeMB > 0 {
+		// The platform images are pushed by buildx without being loaded, so their size can't be checked
+		return fmt.Errorf("docker.maxSizeMB can't be enforced for multi-platform builds (--docker-platforms). Please unset it or build a single platform")
+	}
+
+	switch v.cfg.DockerProvenance {
+	case "":
+	case "min", "max":
+		if builder != BuilderDocker {
+			return fmt.Errorf("provenance attestations (--docker-provenance) require the '%s' builder, but got '%s'", BuilderDocker, builder)
+		}
+	default:
+		return fmt.Errorf("invalid docker provenance mode: expected 'min' or 'max', but got '%s'", v.cfg.DockerProvenance)
+	}
+
+	if v.cfg.RunDockerBuild && !v.cfg.DockerCompose {
+		if _, err := os.Stat("Dockerfile"); err != nil {
+			return warningf("docker.build is enabled, but there is no Dockerfile in the working directory, so no image will be built")
+		}
+	}
+	return nil
+}
+
+// validateRegistrySettings verifies that the Docker daemon is configured for the
+// requested insecure registry and mirror, since the docker CLI cannot set these per command.
+func (v *Validator) validateRegistrySettings(ctx context.Context) error {
+	if v.cfg.DockerBuilder != BuilderDocker || (!v.cfg.DockerInsecure && v.cfg.DockerMirror == "") {
+		return nil
+	}
+	if !v.cfg.ShouldRunDockerBuild() {
+		return nil
+	}
+
+	if v.cfg.DockerInsecure && v.cfg.IsMultiPlatform() {
+		return fmt.Errorf("insecure registries (--docker-insecure) are not supported for multi-platform builds")
+	}
+
+	out, err := command(ctx, "docker", "info", "--format", "{{json .RegistryConfig}}").Output()
+	if err != nil {
+		return fmt.Errorf("failed to read docker daemon registry configuration: %w", err)
+	}
+
+	var registryConfig struct {
+		IndexConfigs map[string]struct {
+			Secure bool `json:"Secure"`
+		} `json:"IndexConfigs"`
+		InsecureRegistryCIDRs []string `json:"InsecureRegistryCIDRs"`
+		Mirrors               []string `json:"Mirrors"`
+	}
+	if err := json.Unmarshal(out, &registryConfig); err != nil {
+		return fmt.Errorf("failed to parse docker daemon registry configuration: %w", err)
+	}
+
+	if v.cfg.DockerInsecure {
+		host := v.cfg.DockerHost
+		insecure := false
+		if index, ok := registryConfig.IndexConfigs[host]; ok && !index.Secure {
+			insecure = true
+		}
+		hostname := host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			hostname = h
+		}
+		if ip := net.ParseIP(hostname); ip != nil {
+			for _, cidr := range registryConfig.InsecureRegistryCIDRs {
+				if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+					insecure = true
+				}
+			}
+		}
+		if !insecure {
+			return fmt.Errorf("registry '%s' is not listed in the docker daemon's insecure-registries. Add it to /etc/docker/daemon.json and restart the daemon", host)
+		}
+	}
+
+	if v.cfg.DockerMirror != "" {
+		found := false
+		for _, mirror := range registryConfig.Mirrors {
+			if registryHost(mirror) == registryHost(v.cfg.DockerMirror) {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("mirror '%s' is not listed in the docker daemon's registry-mirrors. Add it to /etc/docker/daemon.json and restart the daemon", v.cfg.DockerMirror)
+		}
+	}
+
+	return nil
+}
+
+func (v *Validator) validateCompose() error {
+	if !v.cfg.DockerCompose {
+		return nil
+	}
+
+	services, err := v.cfg.ComposeServices()
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return fmt.Errorf("compose mode is enabled but no service in the compose file declares a build section")
 	}
 	return nil
 }
@@ -123,21 +618,131 @@ func (v *Validator) validateEnvVars() error {
 	return nil
 }
 
-func (v *Validator) validateTools() error {
-	tools := []string{"docker", "helm"}
+func (v *Validator) validateRegistryCredentials() error {
+	// The credentials are used to push the image and for the image pull secret
+	if !v.cfg.ShouldRunDockerBuild() && v.cfg.KubernetesImagePullSecret == "" {
+		return nil
+	}
+	return v.cfg.ProbeRegistry()
+}
+
+func (v *Validator) validateTools(ctx context.Context) error {
+	if err := v.cfg.validateToolMinVersions(); err != nil {
+		return err
+	}
+
+	if v.cfg.HelmPostRenderer != "" {
+		if _, err := exec.LookPath(v.cfg.HelmPostRenderer); err != nil {
+			return fmt.Errorf("helm post-renderer '%s' is not executable or not found in PATH. Please check helm.postRenderer", v.cfg.HelmPostRenderer)
+		}
+	}
+
+	// Helm itself is embedded, only the helm-diff plugin needs the helm CLI
+	if v.cfg.HelmDiff {
+		if _, err := exec.LookPath("helm"); err != nil {
+			return fmt.Errorf("helm.diff requires the 'helm' CLI with the helm-diff plugin, but helm is not installed or not found in PATH")
+		}
+		if err := v.cfg.checkToolVersion("helm"); err != nil {
+			return err
+		}
+		out, err := command(ctx, "helm", "plugin", "list").Output()
+		if err != nil || !strings.Contains(string(out), "diff") {
+			return fmt.Errorf("the helm-diff plugin is required for helm.diff. Install it with 'helm plugin install https://github.com/databus23/helm-diff'")
+		}
+	}
+
+	if v.cfg.SkipDocker {
+		return nil
+	}
+	return v.validateBuildTools(ctx)
+}
+
+func (v *Validator) validateChartLint() error {
+	return NewHelmRunner(v.cfg).Lint()
+}
+
+func (v *Validator) validateValuesSchema() error {
+	return NewHelmRunner(v.cfg).ValidateValuesSchema()
+}
+
+func (v *Validator) validateFlavourContract() error {
+	return NewHelmRunner(v.cfg).CheckFlavourContract()
+}
+
+func (v *Validator) validateImageValues() error {
+	return NewHelmRunner(v.cfg).CheckImageValues()
+}
+
+func (v *Validator) validatePermissions(ctx context.Context) error {
+	return NewHelmRunner(v.cfg).CheckPermissions(ctx)
+}
+
+func (v *Validator) validateKubeVersion() error {
+	return NewHelmRunner(v.cfg).CheckKubeVersion()
+}
+
+func (v *Validator) validateCapacity(ctx context.Context) error {
+	return NewHelmRunner(v.cfg).CheckCapacity(ctx)
+}
+
+func (v *Validator) validateNodePlatforms(ctx context.Context) error {
+	return NewHelmRunner(v.cfg).CheckNodePlatforms(ctx)
+}
+
+func (v *Validator) validateBlueGreenService(ctx context.Context) error {
+	return NewHelmRunner(v.cfg).CheckBlueGreenService(ctx)
+}
+
+func (v *Validator) validateBuildTools(ctx context.Context) error {
+	var tools []string
+	switch v.cfg.DockerBuilder {
+	case BuilderDocker:
+		tools = append(tools, "docker")
+	case BuilderBuildah:
+		tools = append(tools, "buildah")
+	}
 
 	for _, tool := range tools {
 		if _, err := exec.LookPath(tool); err != nil {
 			return fmt.Errorf("required tool '%s' is not installed or not found in PATH. Please install %s to proceed", tool, tool)
 		}
+		if err := v.cfg.checkToolVersion(tool); err != nil {
+			return err
+		}
+	}
+
+	if v.cfg.DockerBuilder == BuilderKaniko {
+		if !v.cfg.ShouldRunDockerBuild() {
+			return nil
+		}
+		if _, err := kanikoExecutor(); err != nil {
+			return fmt.Errorf("%w. Please run Dockwright inside the kaniko executor image to proceed", err)
+		}
+		return nil
+	}
+
+	if v.cfg.DockerBuilder != BuilderDocker {
+		return nil
+	}
+
+	if v.cfg.Offline {
+		log.Debug("Not checking the Docker daemon, --offline is set")
+		return nil
 	}
 
 	// Verify Docker daemon is running
-	cmd := exec.Command("docker", "info")
+	cmd := command(ctx, "docker", "info")
 	cmd.Stdout = nil
 	cmd.Stderr = nil
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker daemon is not running. Please start Docker Desktop or the Docker daemon and try again")
+		return fmt.Errorf("docker daemon is not running. Please start Docker Desktop or the Docker daemon and try again, or select a daemonless builder with --docker-builder")
+	}
+
+	// Multi-platform builds, manifest lists and attestations rely on buildx
+	if v.cfg.IsMultiPlatform() || v.cfg.DockerProvenance != "" {
+		if err := command(ctx, "docker", "buildx", "version").Run(); err != nil {
+			return fmt.Errorf("docker buildx is required for multi-platform builds (--docker-platforms) and provenance attestations (--docker-provenance). Please install the buildx plugin to proceed")
+		}
 	}
 
 	return nil
@@ -145,39 +750,118 @@ func (v *Validator) validateTools() error {
 
 func (v *Validator) validateEnvValueFiles() error {
 	for _, env := range v.cfg.Env {
-		path := filepath.Join(".dockwright", "helm", fmt.Sprintf("%s.values.yaml", env))
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			return fmt.Errorf("environment values file not found at path: %s. Please ensure the file exists in the .dockwright/helm directory", path)
+		path, encPath := envValuesFiles(env)
+		_, plainErr := os.Stat(path)
+		_, encErr := os.Stat(encPath)
+		if os.IsNotExist(plainErr) && os.IsNotExist(encErr) {
+			return fmt.Errorf("environment values file not found at path: %s (or encrypted %s). Please ensure the file exists in the .dockwright/helm directory", path, encPath)
+		}
+		if encErr == nil {
+			if _, err := exec.LookPath("sops"); err != nil {
+				return fmt.Errorf("encrypted values file %s requires 'sops', which is not installed or not found in PATH. Please install sops to proceed", encPath)
+			}
 		}
 	}
-	return nil
+	for _, path := range v.cfg.HelmExtraValuesFiles {
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("extra values file not found at path: %s. Please check helm.extraValuesFiles and --values", path)
+		}
+	}
+	return v.lintValuesFiles()
 }
 
-func (v *Validator) validateKubeContext() error {
-	if v.cfg.KubernetesContext == "" {
-		return nil // Optional field
+// lintValuesFiles parses the plain values files of the artifact, failing on
+// invalid YAML and warning about duplicate keys and tab indentation.
+// Templated values files are only valid YAML once rendered, so they are left
+// to the chart lint.
+func (v *Validator) lintValuesFiles() error {
+	files := []string{filepath.Join(".dockwright", "helm", "values.yaml")}
+	for _, env := range v.cfg.Env {
+		path, _ := envValuesFiles(env)
+		files = append(files, path)
 	}
+	files = append(files, v.cfg.HelmExtraValuesFiles...)
 
-	content, err := os.ReadFile(v.cfg.KubernetesConfig)
-	if err != nil {
-		return fmt.Errorf("failed to read kubeconfig file at path '%s': %w", v.cfg.KubernetesConfig, err)
+	var problems []string
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil || (v.cfg.HelmTemplateValues && bytes.Contains(content, []byte("{{"))) {
+			continue
+		}
+		found, err := lintValuesFile(path)
+		if err != nil {
+			return err
+		}
+		for _, problem := range found {
+			log.Warnf("⚠️  %s", problem)
+		}
+		problems = append(problems, found...)
 	}
-
-	var kubeconfig struct {
-		Contexts []struct {
-			Name string `yaml:"name"`
-		} `yaml:"contexts"`
+	if len(problems) > 0 {
+		return warningf("%s", strings.Join(problems, "\n"))
 	}
+	return nil
+}
 
-	if err := yaml.Unmarshal(content, &kubeconfig); err != nil {
-		return fmt.Errorf("failed to parse kubeconfig file at '%s': %w. The file may be corrupted or not in valid YAML format", v.cfg.KubernetesConfig, err)
+func (v *Validator) validateKubeContext() error {
+	targets := make(map[string]string)
+	var warning error // the first one, errors of later environments take precedence
+	for _, cfg := range v.cfg.PerEnvironment() {
+		if err := validateKubeContext(cfg); err != nil {
+			if env := cfg.EnvName(); env != "" {
+				err = fmt.Errorf("environment %s: %w", env, err)
+			}
+			if !isWarning(err) {
+				return err
+			}
+			warning = cmp.Or(warning, err)
+		}
+
+		for _, rel := range cfg.Releases {
+			if rel.Context == "" {
+				continue
+			}
+			if err := validateKubeContext(cfg.ForRelease(rel)); err != nil {
+				err = fmt.Errorf("release %s: %w", cfg.ForRelease(rel).ReleaseName(), err)
+				if !isWarning(err) {
+					return err
+				}
+				warning = cmp.Or(warning, err)
+			}
+		}
+
+		// Environments deployed to the same place would overwrite each other's release
+		target := strings.Join([]string{cfg.KubernetesConfig, cfg.KubernetesContext, cfg.KubernetesNamespace, cfg.ReleaseName()}, "|")
+		if other, ok := targets[target]; ok {
+			return fmt.Errorf("environments %s and %s deploy release %s to the same context and namespace. Please set environments.<env>.kubernetes.context or namespace, or include {{.Env}} in helm.releaseName", other, cfg.EnvName(), cfg.ReleaseName())
+		}
+		targets[target] = cfg.EnvName()
 	}
+	return warning
+}
 
-	for _, ctx := range kubeconfig.Contexts {
-		if ctx.Name == v.cfg.KubernetesContext {
+func validateKubeContext(cfg *Config) error {
+	if cfg.KubernetesContext == "" {
+		// Optional field, but the current context's auth plugin must be installed
+		kubeconfig, err := loadKubeConfig(cfg.KubernetesConfig)
+		if err != nil {
 			return nil
 		}
+		return checkAuthPlugin(kubeconfig, kubeconfig.CurrentContext)
+	}
+
+	kubeconfig, err := loadKubeConfig(cfg.KubernetesConfig)
+	if err != nil {
+		return err
+	}
+	if _, ok := kubeconfig.Contexts[cfg.KubernetesContext]; ok {
+		return checkAuthPlugin(kubeconfig, cfg.KubernetesContext)
 	}
 
-	return fmt.Errorf("kubernetes context '%s' not found in kubeconfig at '%s'. Use 'kubectl config get-contexts' to see available contexts", v.cfg.KubernetesContext, v.cfg.KubernetesConfig)
+	// Contexts of all files are listed, as they may be split across them
+	available := slices.Sorted(maps.Keys(kubeconfig.Contexts))
+	if len(available) == 0 {
+		return fmt.Errorf("kubernetes context '%s' not found: kubeconfig at '%s' has no contexts", cfg.KubernetesContext, cfg.KubernetesConfig)
+	}
+	return fmt.Errorf("kubernetes context '%s' not found in kubeconfig at '%s'. Available contexts: %s", cfg.KubernetesContext, cfg.KubernetesConfig, strings.Join(available, ", "))
 }
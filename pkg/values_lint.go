@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lintValuesFile parses a values file. Invalid YAML is an error, while
+// duplicate keys and tab indentation, which Helm silently accepts in ways
+// that surface later as confusing chart errors, are returned as problems.
+func lintValuesFile(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	tabLine := 0
+	for i, line := range strings.Split(string(content), "\n") {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if strings.Contains(indent, "\t") {
+			tabLine = i + 1
+			problems = append(problems, fmt.Sprintf("%s:%d: indented with a tab", path, tabLine))
+			break
+		}
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		if tabLine > 0 {
+			return nil, fmt.Errorf("invalid YAML in %s: %w. Please indent with spaces instead of the tab on line %d", path, err, tabLine)
+		}
+		return nil, fmt.Errorf("invalid YAML in %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || len(bytes.TrimSpace(content)) == 0 {
+		return problems, nil
+	}
+	if root := doc.Content[0]; root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid values file %s: expected a map of values at line %d", path, root.Line)
+	}
+	return append(problems, duplicateKeys(path, doc.Content[0])...), nil
+}
+
+// duplicateKeys returns the keys defined twice in the same map, of which Helm
+// only keeps the last.
+func duplicateKeys(path string, node *yaml.Node) []string {
+	var problems []string
+	if node.Kind == yaml.MappingNode {
+		seen := map[string]int{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if first, ok := seen[key.Value]; ok {
+				problems = append(problems, fmt.Sprintf("%s:%d: duplicate key '%s', first defined on line %d, the last one wins", path, key.Line, key.Value, first))
+			} else {
+				seen[key.Value] = key.Line
+			}
+		}
+	}
+	for _, child := range node.Content {
+		problems = append(problems, duplicateKeys(path, child)...)
+	}
+	return problems
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// valuesTemplateData is the data available to values file templates when
+// helm.templateValues is enabled:
+//
+//	image:
+//	  repository: {{ .Image.Repository }}
+//	  tag: {{ .Image.Tag }}
+//	podAnnotations:
+//	  commit: {{ .Git.ShortCommit }}
+//	  environment: {{ .Env }}
+type valuesTemplateData struct {
+	ArtifactName string
+	ReleaseName  string
+	Env          string
+	Namespace    string
+	Image        struct {
+		Repository string
+		Tag        string
+	}
+	Git    valuesTemplateGit
+	Config *Config
+}
+
+// valuesTemplateGit resolves git information only when a template uses it,
+// so projects that don't reference it can deploy outside a git checkout.
+type valuesTemplateGit struct{}
+
+// Commit returns the commit SHA of HEAD.
+func (valuesTemplateGit) Commit() (string, error) {
+	return gitCommit()
+}
+
+// ShortCommit returns the abbreviated commit SHA of HEAD.
+func (valuesTemplateGit) ShortCommit() (string, error) {
+	commit, err := gitCommit()
+	if err != nil {
+		return "", err
+	}
+	return commit[:7], nil
+}
+
+// Tag returns the tag pointing at HEAD, or an empty string if there is none.
+func (valuesTemplateGit) Tag() string {
+	return gitExactTag()
+}
+
+// valuesTemplateData returns the pipeline variables of the release.
+func (h *HelmRunner) valuesTemplateData() valuesTemplateData {
+	data := valuesTemplateData{
+		ArtifactName: h.cfg.ArtifactName,
+		ReleaseName:  h.cfg.ReleaseName(),
+		Env:          h.cfg.EnvName(),
+		Namespace:    h.namespace(),
+		Config:       h.cfg,
+	}
+	if repo, err := h.cfg.ImageRepository(); err == nil {
+		data.Image.Repository = repo
+		data.Image.Tag = h.cfg.tag()
+	}
+	return data
+}
+
+// render renders the values file at path as a Go template and writes the
+// result to the file name. Referencing an unknown variable is an error.
+func (d *generatedValues) render(path, name string, data valuesTemplateData) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read values file %s: %w", path, err)
+	}
+
+	tmpl, err := template.New(path).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return "", fmt.Errorf("invalid template in values file %s: %w", path, err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("failed to render values file %s: %w", path, err)
+	}
+
+	target, err := d.write(name, out.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to write rendered values of %s: %w", path, err)
+	}
+	return target, nil
+}